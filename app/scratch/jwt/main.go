@@ -5,11 +5,12 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"time"
 
+	"github.com/ardanlabs/service/business/web/auth"
+	"github.com/ardanlabs/service/business/web/keystore"
 	"github.com/golang-jwt/jwt/v4"
 )
 
@@ -22,23 +23,31 @@ func main() {
 func run() error {
 
 	// =========================================================================
-	// Generate Private / Public RSA Key
+	// Load the Private / Public Key, the key can be RSA, ECDSA P-256 or Ed25519
 
-	const keyFile = "zarf/keys/54bb2165-71e1-41a6-af3e-7da4a0e1e2c1.pem"
-	file, err := os.Open(keyFile)
+	ks, err := keystore.New(keystore.Config{
+		Dir: "zarf/keys/",
+	})
 	if err != nil {
-		return fmt.Errorf("opening key file: %w", err)
+		return fmt.Errorf("constructing keystore: %w", err)
 	}
-	defer file.Close()
 
-	privatePEM, err := io.ReadAll(io.LimitReader(file, 1024*1024))
+	kid := ks.ActiveKID()
+
+	privatePEM, err := ks.PrivateKeyPEM(kid)
+	if err != nil {
+		return fmt.Errorf("private key: %w", err)
+	}
+
+	privateKey, err := auth.ParsePrivateKeyPEM(privatePEM)
 	if err != nil {
-		return fmt.Errorf("reading auth private key: %w", err)
+		return fmt.Errorf("parsing private pem: %w", err)
 	}
 
-	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(privatePEM))
+	// The signing algorithm follows the type of the key.
+	alg, err := auth.Algorithm(privateKey)
 	if err != nil {
-		return fmt.Errorf("parsing public pem: %w", err)
+		return fmt.Errorf("algorithm: %w", err)
 	}
 
 	// Create a file for the private key information in PEM form.
@@ -60,7 +69,7 @@ func run() error {
 	// }
 
 	// Marshal the public key from the private key to PKIX.
-	asn1Bytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	asn1Bytes, err := x509.MarshalPKIXPublicKey(privateKey.Public())
 	if err != nil {
 		return fmt.Errorf("marshaling public key: %w", err)
 	}
@@ -107,8 +116,8 @@ func run() error {
 		Roles: []string{"USER"},
 	}
 
-	token := jwt.NewWithClaims(jwt.GetSigningMethod("RS256"), claims)
-	token.Header["kid"] = "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(alg), claims)
+	token.Header["kid"] = kid
 
 	tokenString, err := token.SignedString(privateKey)
 	if err != nil {
@@ -122,15 +131,13 @@ func run() error {
 
 	fmt.Print("\n========================================\n\n")
 
-	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256"}))
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{alg}))
 
 	keyFunc := func(token *jwt.Token) (interface{}, error) {
-		switch token.Header["kid"] {
-		case "kid1":
-			return &privateKey.PublicKey, nil
-		default:
+		if token.Header["kid"] != kid {
 			return nil, errors.New("unknown key")
 		}
+		return privateKey.Public(), nil
 	}
 
 	var clm struct {
@@ -5,33 +5,95 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/ardanlabs/service/app/services/sales-api/handlers/v1/apikeygrp"
+	"github.com/ardanlabs/service/app/services/sales-api/handlers/v1/auditgrp"
+	"github.com/ardanlabs/service/app/services/sales-api/handlers/v1/jwksgrp"
+	"github.com/ardanlabs/service/app/services/sales-api/handlers/v1/oauthgrp"
+	"github.com/ardanlabs/service/app/services/sales-api/handlers/v1/productgrp"
+	"github.com/ardanlabs/service/app/services/sales-api/handlers/v1/reportgrp"
+	"github.com/ardanlabs/service/app/services/sales-api/handlers/v1/rolegrp"
+	"github.com/ardanlabs/service/app/services/sales-api/handlers/v1/salegrp"
 	"github.com/ardanlabs/service/app/services/sales-api/handlers/v1/testgrp"
 	"github.com/ardanlabs/service/app/services/sales-api/handlers/v1/usergrp"
+	"github.com/ardanlabs/service/business/core/apikey"
+	"github.com/ardanlabs/service/business/core/apikey/stores/apikeydb"
+	"github.com/ardanlabs/service/business/core/audit"
+	"github.com/ardanlabs/service/business/core/audit/stores/auditdb"
+	"github.com/ardanlabs/service/business/core/oauthclient"
+	"github.com/ardanlabs/service/business/core/oauthclient/stores/oauthclientdb"
+	"github.com/ardanlabs/service/business/core/product"
+	"github.com/ardanlabs/service/business/core/product/stores/productdb"
+	"github.com/ardanlabs/service/business/core/report"
+	"github.com/ardanlabs/service/business/core/report/stores/reportdb"
+	"github.com/ardanlabs/service/business/core/role"
+	"github.com/ardanlabs/service/business/core/role/stores/roledb"
+	"github.com/ardanlabs/service/business/core/sale"
+	"github.com/ardanlabs/service/business/core/sale/stores/saledb"
+	"github.com/ardanlabs/service/business/core/token"
+	"github.com/ardanlabs/service/business/core/token/stores/tokendb"
 	"github.com/ardanlabs/service/business/core/user"
 	"github.com/ardanlabs/service/business/core/user/stores/userdb"
 	"github.com/ardanlabs/service/business/web/auth"
+	"github.com/ardanlabs/service/business/web/jwks"
 	"github.com/ardanlabs/service/business/web/v1/mid"
 	"github.com/ardanlabs/service/foundation/web"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
 
-// APIMuxConfig contains all the mandatory systems required by handlers.
+// APIMuxConfig contains all the mandatory systems required by handlers. The
+// ActiveKID function returns the kid of the key tokens are signed with.
 type APIMuxConfig struct {
-	Shutdown chan os.Signal
-	Log      *zap.SugaredLogger
-	Auth     *auth.Auth
-	DB       *sqlx.DB
+	Shutdown  chan os.Signal
+	Log       *zap.SugaredLogger
+	Auth      *auth.Auth
+	Keys      jwks.PublicKeys
+	ActiveKID func() string
+	DB        *sqlx.DB
 }
 
 // APIMux constructs a http.Handler with all application routes defined.
 func APIMux(cfg APIMuxConfig) *web.App {
 	app := web.NewApp(cfg.Shutdown, mid.Logger(cfg.Log), mid.Errors(cfg.Log), mid.Metrics(), mid.Panics())
 
-	authen := mid.Authenticate(cfg.Auth)
-	ruleAdmin := mid.Authorize(cfg.Auth, auth.RuleAdminOnly)
+	// The user core is shared so the enabled status cache used during
+	// authentication is evicted when a user is updated.
+	usrCore := user.NewCore(userdb.NewStore(cfg.Log, cfg.DB))
+	tknCore := token.NewCore(tokendb.NewStore(cfg.Log, cfg.DB))
+	rolCore := role.NewCore(roledb.NewStore(cfg.Log, cfg.DB))
+	audCore := audit.NewCore(auditdb.NewStore(cfg.Log, cfg.DB))
+	keyCore := apikey.NewCore(apikeydb.NewStore(cfg.Log, cfg.DB))
+
+	authen := mid.Authenticate(mid.AuthenticateConfig{
+		Log:    cfg.Log,
+		Auth:   cfg.Auth,
+		User:   usrCore,
+		Role:   rolCore,
+		Token:  tknCore,
+		APIKey: keyCore,
+		Audit:  audCore,
+	})
 	ruleAny := mid.Authorize(cfg.Auth, auth.RuleAny)
 
+	// Routes that manage data are authorized by the permissions carried by
+	// the roles of the user, the roles themselves are managed at runtime.
+	permUsersRead := mid.AuthorizePermission(cfg.Auth, role.PermUsersRead)
+	permUsersWrite := mid.AuthorizePermission(cfg.Auth, role.PermUsersWrite)
+	permUsersReadOrSelf := mid.AuthorizePermissionOwner(cfg.Auth, role.PermUsersRead, mid.ParamOwner("id"))
+	permUsersWriteOrSelf := mid.AuthorizePermissionOwner(cfg.Auth, role.PermUsersWrite, mid.ParamOwner("id"))
+	permReportsRead := mid.AuthorizePermission(cfg.Auth, role.PermReportsRead)
+	permRolesRead := mid.AuthorizePermission(cfg.Auth, role.PermRolesRead)
+	permRolesWrite := mid.AuthorizePermission(cfg.Auth, role.PermRolesWrite)
+	permUsersImpersonate := mid.AuthorizePermission(cfg.Auth, role.PermUsersImpersonate)
+	permAuditRead := mid.AuthorizePermission(cfg.Auth, role.PermAuditRead)
+
+	// Impersonated tokens can't be used to change who can do what.
+	denyImpersonation := mid.DenyImpersonation()
+
+	// API keys can't be used to create more API keys or to change the users,
+	// roles and sessions that decide what a key can do.
+	denyAPIKey := mid.DenyAPIKey()
+
 	// =========================================================================
 
 	tg := testgrp.Handlers{
@@ -39,20 +101,108 @@ func APIMux(cfg APIMuxConfig) *web.App {
 	}
 
 	app.Handle(http.MethodGet, "/status", tg.Status)
-	app.Handle(http.MethodGet, "/auth", tg.Status, authen, ruleAdmin)
+	app.Handle(http.MethodGet, "/auth", tg.Status, authen, permUsersRead)
+
+	// =========================================================================
+
+	kgh := jwksgrp.Handlers{
+		Keys:   cfg.Keys,
+		Issuer: cfg.Auth.Issuer(),
+	}
+	app.Handle(http.MethodGet, "/.well-known/jwks.json", kgh.JWKS)
+	app.Handle(http.MethodGet, "/.well-known/openid-configuration", kgh.OpenIDConfiguration)
 
 	// =========================================================================
 
 	ugh := usergrp.Handlers{
-		User: user.NewCore(userdb.NewStore(cfg.Log, cfg.DB)),
-		Auth: cfg.Auth,
+		User:   usrCore,
+		Role:   rolCore,
+		Tokens: tknCore,
+		Auth:   cfg.Auth,
 	}
 	app.Handle(http.MethodGet, "/users/token/:kid", ugh.Token)
-	app.Handle(http.MethodGet, "/users/:page/:rows", ugh.Query, authen, ruleAdmin)
-	app.Handle(http.MethodGet, "/users/:id", ugh.QueryByID, ruleAny)
-	app.Handle(http.MethodPost, "/users", ugh.Create, authen, ruleAdmin)
-	app.Handle(http.MethodPut, "/users/:id", ugh.Update, authen, ruleAny)
-	app.Handle(http.MethodDelete, "/users/:id", ugh.Delete, authen, ruleAny)
+	app.Handle(http.MethodPost, "/users/token/:kid/refresh", ugh.Refresh)
+	app.Handle(http.MethodPost, "/users/logout", ugh.Logout, authen)
+	app.Handle(http.MethodDelete, "/users/:id/sessions", ugh.RevokeSessions, authen, denyAPIKey, permUsersWrite)
+	app.Handle(http.MethodPut, "/users/:id/roles", ugh.AssignRoles, authen, denyAPIKey, denyImpersonation, permUsersWrite)
+	app.Handle(http.MethodPost, "/users/:id/impersonate/:kid", ugh.Impersonate, authen, denyImpersonation, permUsersImpersonate)
+	app.Handle(http.MethodGet, "/users", ugh.Query, authen, permUsersRead)
+	app.Handle(http.MethodGet, "/users/:id", ugh.QueryByID, authen, permUsersReadOrSelf)
+	app.Handle(http.MethodPost, "/users", ugh.Create, authen, denyAPIKey, denyImpersonation, permUsersWrite)
+	app.Handle(http.MethodPut, "/users/:id", ugh.Update, authen, denyAPIKey, permUsersWriteOrSelf)
+	app.Handle(http.MethodDelete, "/users/:id", ugh.Delete, authen, denyAPIKey, permUsersWriteOrSelf)
+
+	// =========================================================================
+
+	ogh := oauthgrp.Handlers{
+		User:      usrCore,
+		Role:      rolCore,
+		Tokens:    tknCore,
+		Client:    oauthclient.NewCore(oauthclientdb.NewStore(cfg.Log, cfg.DB)),
+		Auth:      cfg.Auth,
+		ActiveKID: cfg.ActiveKID,
+	}
+	app.Handle(http.MethodPost, "/oauth/token", ogh.Token)
+	app.Handle(http.MethodPost, "/oauth/clients", ogh.CreateClient, authen, denyAPIKey, denyImpersonation, permUsersWrite)
+	app.Handle(http.MethodDelete, "/oauth/clients/:id", ogh.DeleteClient, authen, denyAPIKey, denyImpersonation, permUsersWrite)
+
+	// =========================================================================
+
+	akgh := apikeygrp.Handlers{
+		APIKey: keyCore,
+		User:   usrCore,
+		Role:   rolCore,
+	}
+	app.Handle(http.MethodGet, "/users/:id/apikeys", akgh.Query, authen, permUsersReadOrSelf)
+	app.Handle(http.MethodPost, "/users/:id/apikeys", akgh.Create, authen, denyAPIKey, denyImpersonation, permUsersWriteOrSelf)
+	app.Handle(http.MethodDelete, "/users/:id/apikeys/:keyid", akgh.Revoke, authen, permUsersWriteOrSelf)
+
+	// =========================================================================
+
+	rgh := rolegrp.Handlers{
+		Role: rolCore,
+	}
+	app.Handle(http.MethodGet, "/permissions", rgh.QueryPermissions, authen, permRolesRead)
+	app.Handle(http.MethodGet, "/roles", rgh.Query, authen, permRolesRead)
+	app.Handle(http.MethodGet, "/roles/:name", rgh.QueryByName, authen, permRolesRead)
+	app.Handle(http.MethodPost, "/roles", rgh.Create, authen, denyAPIKey, denyImpersonation, permRolesWrite)
+	app.Handle(http.MethodDelete, "/roles/:name", rgh.Delete, authen, denyAPIKey, denyImpersonation, permRolesWrite)
+	app.Handle(http.MethodPut, "/roles/:name/permissions/:permission", rgh.AddPermission, authen, denyAPIKey, denyImpersonation, permRolesWrite)
+	app.Handle(http.MethodDelete, "/roles/:name/permissions/:permission", rgh.RemovePermission, authen, denyAPIKey, denyImpersonation, permRolesWrite)
+
+	// =========================================================================
+
+	pgh := productgrp.Handlers{
+		Product: product.NewCore(productdb.NewStore(cfg.Log, cfg.DB)),
+	}
+	permProductsWriteOrOwner := mid.AuthorizePermissionOwner(cfg.Auth, role.PermProductsWrite, pgh.Owner)
+
+	app.Handle(http.MethodGet, "/products", pgh.Query, authen, ruleAny)
+	app.Handle(http.MethodGet, "/products/:id", pgh.QueryByID, authen, ruleAny)
+	app.Handle(http.MethodPost, "/products", pgh.Create, authen, ruleAny)
+	app.Handle(http.MethodPut, "/products/:id", pgh.Update, authen, permProductsWriteOrOwner)
+	app.Handle(http.MethodDelete, "/products/:id", pgh.Delete, authen, permProductsWriteOrOwner)
+
+	// =========================================================================
+
+	sgh := salegrp.Handlers{
+		Sale: sale.NewCore(saledb.NewStore(cfg.Log, cfg.DB)),
+	}
+	app.Handle(http.MethodPost, "/sales", sgh.Create, authen, ruleAny)
+
+	// =========================================================================
+
+	rpgh := reportgrp.Handlers{
+		Report: report.NewCore(reportdb.NewStore(cfg.Log, cfg.DB)),
+	}
+	app.Handle(http.MethodGet, "/reports/sales", rpgh.Sales, authen, permReportsRead)
+
+	// =========================================================================
+
+	agh := auditgrp.Handlers{
+		Audit: audCore,
+	}
+	app.Handle(http.MethodGet, "/audit", agh.Query, authen, permAuditRead)
 
 	return app
 }
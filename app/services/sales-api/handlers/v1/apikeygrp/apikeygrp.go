@@ -0,0 +1,143 @@
+// Package apikeygrp maintains the group of handlers for API key access.
+package apikeygrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ardanlabs/service/business/core/apikey"
+	"github.com/ardanlabs/service/business/core/role"
+	"github.com/ardanlabs/service/business/core/user"
+	"github.com/ardanlabs/service/business/web/auth"
+	v1Web "github.com/ardanlabs/service/business/web/v1"
+	"github.com/ardanlabs/service/foundation/web"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidID = errors.New("ID is not in its proper form")
+
+// Handlers manages the set of API key endpoints.
+type Handlers struct {
+	APIKey *apikey.Core
+	User   *user.Core
+	Role   *role.Core
+}
+
+// Create generates a new API key for the user. The key can only carry the
+// permissions held by both the user and the caller. The response is the only
+// time the key is available.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var nk apikey.NewKey
+	if err := web.Decode(r, &nk); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	usr, err := h.queryUser(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	perms, err := h.Role.Permissions(ctx, usr.Roles)
+	if err != nil {
+		return fmt.Errorf("resolving permissions: %w", err)
+	}
+
+	claims := auth.GetClaims(ctx)
+	held := intersect(perms, claims.Permissions)
+
+	nk.TenantID = usr.TenantID
+	nk.UserID = usr.ID
+
+	created, err := h.APIKey.Create(ctx, nk, held)
+	if err != nil {
+		switch {
+		case errors.Is(err, apikey.ErrNotGrantable):
+			return v1Web.NewRequestError(err, http.StatusForbidden)
+		case errors.Is(err, apikey.ErrInvalidExpiry):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		}
+		return fmt.Errorf("userID[%s]: %w", usr.ID, err)
+	}
+
+	return web.Respond(ctx, w, created, http.StatusCreated)
+}
+
+// Query returns the API keys of the user, including the ones that are
+// revoked or expired.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	usr, err := h.queryUser(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	keys, err := h.APIKey.QueryByUserID(ctx, usr.ID)
+	if err != nil {
+		return fmt.Errorf("unable to query for api keys: %w", err)
+	}
+
+	return web.Respond(ctx, w, keys, http.StatusOK)
+}
+
+// Revoke revokes the specified API key of the user.
+func (h Handlers) Revoke(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	usr, err := h.queryUser(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	keyID, err := uuid.Parse(web.Param(r, "keyid"))
+	if err != nil {
+		return v1Web.NewRequestError(ErrInvalidID, http.StatusBadRequest)
+	}
+
+	if err := h.APIKey.Revoke(ctx, usr.ID, keyID); err != nil {
+		switch {
+		case errors.Is(err, apikey.ErrNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("keyID[%s]: %w", keyID, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// queryUser returns the user specified in the path. The user must belong to
+// the tenant of the caller.
+func (h Handlers) queryUser(ctx context.Context, r *http.Request) (user.User, error) {
+	userID, err := uuid.Parse(web.Param(r, "id"))
+	if err != nil {
+		return user.User{}, v1Web.NewRequestError(ErrInvalidID, http.StatusBadRequest)
+	}
+
+	usr, err := h.User.QueryByID(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNotFound):
+			return user.User{}, v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return user.User{}, fmt.Errorf("ID[%s]: %w", userID, err)
+		}
+	}
+
+	return usr, nil
+}
+
+// intersect returns the values of a that are also in b.
+func intersect(a []string, b []string) []string {
+	set := make(map[string]bool, len(b))
+	for _, v := range b {
+		set[v] = true
+	}
+
+	values := make([]string, 0, len(a))
+	for _, v := range a {
+		if set[v] {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
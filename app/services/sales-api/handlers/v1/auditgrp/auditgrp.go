@@ -0,0 +1,38 @@
+// Package auditgrp maintains the group of handlers for audit access.
+package auditgrp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/ardanlabs/service/business/core/audit"
+	v1Web "github.com/ardanlabs/service/business/web/v1"
+	"github.com/ardanlabs/service/foundation/web"
+)
+
+// Handlers manages the set of audit endpoints.
+type Handlers struct {
+	Audit *audit.Core
+}
+
+// Query returns a page of the changes made to the tenant, the most recent
+// first.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	page, err := v1Web.ParsePageRequest(r)
+	if err != nil {
+		return err
+	}
+
+	recs, err := h.Audit.Query(ctx, page.Number, page.RowsPerPage)
+	if err != nil {
+		return fmt.Errorf("unable to query for audit records: %w", err)
+	}
+
+	total, err := h.Audit.Count(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to count audit records: %w", err)
+	}
+
+	return web.Respond(ctx, w, v1Web.NewPageDocument(recs, total, page.Number, page.RowsPerPage), http.StatusOK)
+}
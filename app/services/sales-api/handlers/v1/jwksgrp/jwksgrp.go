@@ -0,0 +1,88 @@
+// Package jwksgrp maintains the group of handlers that publish the keys used
+// to sign tokens so other services can verify them.
+package jwksgrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ardanlabs/service/business/web/jwks"
+	v1Web "github.com/ardanlabs/service/business/web/v1"
+	"github.com/ardanlabs/service/foundation/web"
+)
+
+// cacheMaxAge is how long clients are allowed to cache the published
+// documents. Keeping this short lets new keys be discovered quickly.
+const cacheMaxAge = 5 * time.Minute
+
+// Handlers manages the set of key discovery endpoints.
+type Handlers struct {
+	Keys   jwks.PublicKeys
+	Issuer string
+}
+
+// JWKS returns the public keys used to sign tokens as a JSON Web Key Set.
+func (h Handlers) JWKS(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	set, err := jwks.NewSet(h.Keys)
+	if err != nil {
+		return fmt.Errorf("building key set: %w", err)
+	}
+
+	setCacheHeaders(w)
+
+	return web.Respond(ctx, w, set, http.StatusOK)
+}
+
+// OpenIDConfiguration returns the discovery document that points clients to
+// the token endpoint and the key set used to verify tokens. The URLs are
+// built from the issuer, never from the request, since the document can be
+// cached by shared caches. The issuer must be the public URL of the service
+// for the document to be provided.
+func (h Handlers) OpenIDConfiguration(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	base, err := issuerURL(h.Issuer)
+	if err != nil {
+		return v1Web.NewRequestError(err, http.StatusNotFound)
+	}
+
+	doc := struct {
+		Issuer           string   `json:"issuer"`
+		JWKSURI          string   `json:"jwks_uri"`
+		TokenEndpoint    string   `json:"token_endpoint"`
+		TokenAuthMethods []string `json:"token_endpoint_auth_methods_supported"`
+		GrantTypes       []string `json:"grant_types_supported"`
+		SubjectTypes     []string `json:"subject_types_supported"`
+	}{
+		Issuer:           h.Issuer,
+		JWKSURI:          base + "/.well-known/jwks.json",
+		TokenEndpoint:    base + "/oauth/token",
+		TokenAuthMethods: []string{"client_secret_basic", "client_secret_post"},
+		GrantTypes:       []string{"client_credentials", "password", "refresh_token"},
+		SubjectTypes:     []string{"public"},
+	}
+
+	setCacheHeaders(w)
+
+	return web.Respond(ctx, w, doc, http.StatusOK)
+}
+
+// =============================================================================
+
+func setCacheHeaders(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(cacheMaxAge.Seconds())))
+}
+
+// issuerURL returns the issuer as the base for the URLs of the service. The
+// issuer must be an absolute http or https URL.
+func issuerURL(issuer string) (string, error) {
+	u, err := url.Parse(issuer)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("discovery requires the issuer to be the URL of the service")
+	}
+
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
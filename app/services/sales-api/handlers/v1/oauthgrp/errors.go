@@ -0,0 +1,46 @@
+package oauthgrp
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Set of error codes defined by RFC 6749 section 5.2 for the token endpoint.
+const (
+	errInvalidRequest       = "invalid_request"
+	errInvalidClient        = "invalid_client"
+	errInvalidGrant         = "invalid_grant"
+	errUnauthorizedClient   = "unauthorized_client"
+	errUnsupportedGrantType = "unsupported_grant_type"
+	errInvalidScope         = "invalid_scope"
+)
+
+// tokenError is the error response of the token endpoint. Clients rely on
+// the format defined by the RFC, so these errors are sent by the handler
+// instead of the error middleware.
+type tokenError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	status      int
+}
+
+// newTokenError constructs an error response with the specified code. An
+// invalid client is reported with a 401 so the client knows to authenticate,
+// every other error is a 400.
+func newTokenError(code string, format string, args ...any) *tokenError {
+	status := http.StatusBadRequest
+	if code == errInvalidClient {
+		status = http.StatusUnauthorized
+	}
+
+	return &tokenError{
+		Code:        code,
+		Description: fmt.Sprintf(format, args...),
+		status:      status,
+	}
+}
+
+// Error implements the error interface.
+func (te *tokenError) Error() string {
+	return te.Code + ": " + te.Description
+}
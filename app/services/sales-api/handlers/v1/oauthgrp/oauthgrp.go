@@ -0,0 +1,475 @@
+// Package oauthgrp maintains the group of handlers for the OAuth2 token
+// endpoint and the clients registered to use it.
+package oauthgrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/oauthclient"
+	"github.com/ardanlabs/service/business/core/role"
+	"github.com/ardanlabs/service/business/core/token"
+	"github.com/ardanlabs/service/business/core/user"
+	"github.com/ardanlabs/service/business/sys/tenant"
+	"github.com/ardanlabs/service/business/web/auth"
+	v1Web "github.com/ardanlabs/service/business/web/v1"
+	"github.com/ardanlabs/service/foundation/web"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidID = errors.New("ID is not in its proper form")
+
+// Set of grant types the token endpoint supports.
+const (
+	GrantClientCredentials = "client_credentials"
+	GrantPassword          = "password"
+	GrantRefreshToken      = "refresh_token"
+)
+
+// Handlers manages the set of OAuth2 endpoints. Tokens are signed with the
+// key identified by ActiveKID.
+type Handlers struct {
+	User      *user.Core
+	Role      *role.Core
+	Tokens    *token.Core
+	Client    *oauthclient.Core
+	Auth      *auth.Auth
+	ActiveKID func() string
+}
+
+// Token implements the token endpoint of RFC 6749. It supports the client
+// credentials grant for registered clients and the password and refresh token
+// grants for users. Clients authenticate with HTTP Basic or with the
+// client_id and client_secret parameters, or only identify themselves with
+// the client_id parameter. The password and refresh token grants can be used
+// without a client.
+func (h Handlers) Token(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	resp, err := h.grant(ctx, r)
+	if err != nil {
+		var te *tokenError
+		if !errors.As(err, &te) {
+			return err
+		}
+
+		if te.Code == errInvalidClient {
+			w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+		}
+
+		return web.Respond(ctx, w, te, te.status)
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
+
+// CreateClient registers a new client that acts for a user of the tenant. The
+// client can only carry the permissions held by both the user and the caller.
+// The response is the only time the secret is available.
+func (h Handlers) CreateClient(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var nc oauthclient.NewClient
+	if err := web.Decode(r, &nc); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	usr, err := h.User.QueryByID(ctx, nc.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNotFound):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("ID[%s]: %w", nc.UserID, err)
+		}
+	}
+
+	perms, err := h.Role.Permissions(ctx, usr.Roles)
+	if err != nil {
+		return fmt.Errorf("resolving permissions: %w", err)
+	}
+
+	claims := auth.GetClaims(ctx)
+	nc.TenantID = usr.TenantID
+
+	created, err := h.Client.Create(ctx, nc, intersect(perms, claims.Permissions))
+	if err != nil {
+		switch {
+		case errors.Is(err, oauthclient.ErrNotGrantable):
+			return v1Web.NewRequestError(err, http.StatusForbidden)
+		}
+		return fmt.Errorf("client[%+v]: %w", &nc, err)
+	}
+
+	return web.Respond(ctx, w, created, http.StatusCreated)
+}
+
+// DeleteClient removes a client and revokes the sessions of the refresh
+// tokens issued to it. Tokens issued with the client credentials grant remain
+// valid until they expire.
+func (h Handlers) DeleteClient(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	clientID, err := uuid.Parse(web.Param(r, "id"))
+	if err != nil {
+		return v1Web.NewRequestError(ErrInvalidID, http.StatusBadRequest)
+	}
+
+	// The client is looked up first so only the sessions of a client of the
+	// tenant can be revoked.
+	if _, err := h.Client.QueryByID(ctx, clientID); err != nil {
+		switch {
+		case errors.Is(err, oauthclient.ErrNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", clientID, err)
+		}
+	}
+
+	if err := h.Tokens.RevokeClient(ctx, clientID); err != nil {
+		return fmt.Errorf("revoking sessions: ID[%s]: %w", clientID, err)
+	}
+
+	if err := h.Client.Delete(ctx, clientID); err != nil {
+		switch {
+		case errors.Is(err, oauthclient.ErrNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", clientID, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// =============================================================================
+
+// tokenResponse is the successful response of the token endpoint as defined
+// by RFC 6749 section 5.1. The scope lists the permissions the token carries.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// grant validates the request and issues the tokens for the requested grant.
+func (h Handlers) grant(ctx context.Context, r *http.Request) (tokenResponse, error) {
+	if err := r.ParseForm(); err != nil {
+		return tokenResponse{}, newTokenError(errInvalidRequest, "malformed request body")
+	}
+
+	for name, values := range r.PostForm {
+		if len(values) > 1 {
+			return tokenResponse{}, newTokenError(errInvalidRequest, "parameter %s is repeated", name)
+		}
+	}
+
+	clt, authenticated, err := h.authenticateClient(ctx, r)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	switch grantType := r.PostForm.Get("grant_type"); grantType {
+	case GrantClientCredentials:
+		// A client that only identified itself can't act for its user.
+		if !authenticated {
+			clt = nil
+		}
+		return h.clientCredentials(ctx, r, clt)
+	case GrantPassword:
+		return h.password(ctx, r, clt)
+	case GrantRefreshToken:
+		return h.refreshToken(ctx, r, clt)
+	case "":
+		return tokenResponse{}, newTokenError(errInvalidRequest, "missing grant_type")
+	default:
+		return tokenResponse{}, newTokenError(errUnsupportedGrantType, "grant type %s is not supported", grantType)
+	}
+}
+
+// clientCredentials issues a token to the client for the user the client
+// acts for. The token carries the permissions of the client the user still
+// holds, narrowed to the requested scope. No refresh token is issued, the
+// client can request a new token at any time.
+func (h Handlers) clientCredentials(ctx context.Context, r *http.Request, clt *oauthclient.Client) (tokenResponse, error) {
+	if clt == nil {
+		return tokenResponse{}, newTokenError(errInvalidClient, "client authentication is required")
+	}
+
+	usr, err := h.User.QueryByID(tenant.Set(ctx, clt.TenantID), clt.UserID)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return tokenResponse{}, newTokenError(errUnauthorizedClient, "client user doesn't exist")
+		}
+		return tokenResponse{}, fmt.Errorf("ID[%s]: %w", clt.UserID, err)
+	}
+
+	if !usr.Enabled {
+		return tokenResponse{}, newTokenError(errUnauthorizedClient, "client user is disabled")
+	}
+
+	perms, err := h.Role.Permissions(ctx, usr.Roles)
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("resolving permissions: %w", err)
+	}
+
+	perms, err = narrow(intersect(clt.Permissions, perms), r.PostForm.Get("scope"))
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	return h.issue(usr, perms, clt, nil)
+}
+
+// password issues a token and a refresh token to the user that owns the
+// credentials. The token carries every permission of the user, a requested
+// scope is ignored.
+func (h Handlers) password(ctx context.Context, r *http.Request, clt *oauthclient.Client) (tokenResponse, error) {
+	username := r.PostForm.Get("username")
+	password := r.PostForm.Get("password")
+	if username == "" || password == "" {
+		return tokenResponse{}, newTokenError(errInvalidRequest, "missing username or password")
+	}
+
+	addr, err := mail.ParseAddress(username)
+	if err != nil {
+		return tokenResponse{}, newTokenError(errInvalidGrant, "invalid username or password")
+	}
+
+	// The tenant isn't known until the user is found, emails are unique
+	// across every tenant.
+	usr, err := h.User.Authenticate(tenant.SetAll(ctx), *addr, password)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNotFound), errors.Is(err, user.ErrAuthenticationFailure):
+			return tokenResponse{}, newTokenError(errInvalidGrant, "invalid username or password")
+		case errors.Is(err, user.ErrUserDisabled):
+			return tokenResponse{}, newTokenError(errInvalidGrant, "user is disabled")
+		default:
+			return tokenResponse{}, fmt.Errorf("authenticating: %w", err)
+		}
+	}
+
+	perms, err := h.Role.Permissions(ctx, usr.Roles)
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("resolving permissions: %w", err)
+	}
+
+	refresh, err := h.Tokens.Issue(ctx, usr.ID, clientIDOf(clt))
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("issuing refresh token: %w", err)
+	}
+
+	return h.issue(usr, perms, clt, &refresh)
+}
+
+// refreshToken exchanges a refresh token for a new token and refresh token.
+// Presenting a refresh token that was already exchanged revokes the session.
+// Only the client the refresh token was issued to can exchange it.
+func (h Handlers) refreshToken(ctx context.Context, r *http.Request, clt *oauthclient.Client) (tokenResponse, error) {
+	tkn := r.PostForm.Get("refresh_token")
+	if tkn == "" {
+		return tokenResponse{}, newTokenError(errInvalidRequest, "missing refresh_token")
+	}
+
+	refresh, err := h.Tokens.Rotate(ctx, tkn, clientIDOf(clt))
+	if err != nil {
+		switch {
+		case errors.Is(err, token.ErrInvalidToken), errors.Is(err, token.ErrTokenReused), errors.Is(err, token.ErrWrongClient):
+			return tokenResponse{}, newTokenError(errInvalidGrant, err.Error())
+		default:
+			return tokenResponse{}, fmt.Errorf("rotating refresh token: %w", err)
+		}
+	}
+
+	// The refresh token identifies the user, the tenant comes from the user.
+	usr, err := h.User.QueryByID(tenant.SetAll(ctx), refresh.UserID)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return tokenResponse{}, newTokenError(errInvalidGrant, "user doesn't exist")
+		}
+		return tokenResponse{}, fmt.Errorf("ID[%s]: %w", refresh.UserID, err)
+	}
+
+	if !usr.Enabled {
+		if err := h.Tokens.RevokeSession(ctx, refresh.FamilyID); err != nil {
+			return tokenResponse{}, fmt.Errorf("revoking session: %w", err)
+		}
+		return tokenResponse{}, newTokenError(errInvalidGrant, "user is disabled")
+	}
+
+	perms, err := h.Role.Permissions(ctx, usr.Roles)
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("resolving permissions: %w", err)
+	}
+
+	return h.issue(usr, perms, clt, &refresh)
+}
+
+// issue signs a token for the user that carries the permissions. The token
+// belongs to the session of the refresh token when there is one.
+func (h Handlers) issue(usr user.User, perms []string, clt *oauthclient.Client, refresh *token.Refresh) (tokenResponse, error) {
+	claims := h.Auth.NewClaims(usr.ID.String(), usr.Roles)
+	claims.Permissions = perms
+	claims.ID = uuid.NewString()
+	claims.TenantID = usr.TenantID.String()
+
+	if clt != nil {
+		claims.ClientID = clt.ID.String()
+	}
+
+	var resp tokenResponse
+	if refresh != nil {
+		claims.SessionID = refresh.FamilyID.String()
+		resp.RefreshToken = refresh.Token
+	}
+
+	tkn, err := h.Auth.GenerateToken(h.ActiveKID(), claims)
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("generating token: %w", err)
+	}
+
+	resp.AccessToken = tkn
+	resp.TokenType = "Bearer"
+	resp.ExpiresIn = int(time.Until(claims.ExpiresAt.Time).Round(time.Second).Seconds())
+	resp.Scope = strings.Join(perms, " ")
+
+	return resp, nil
+}
+
+// clientIDOf returns the id of the authenticated client, uuid.Nil when the
+// client didn't authenticate.
+func clientIDOf(clt *oauthclient.Client) uuid.UUID {
+	if clt == nil {
+		return uuid.Nil
+	}
+	return clt.ID
+}
+
+// authenticateClient returns the client that made the request and whether it
+// authenticated. A client can identify itself with just the client_id
+// parameter, like a public client does, so the refresh tokens issued to it
+// are bound to it. A request without a client returns no client.
+func (h Handlers) authenticateClient(ctx context.Context, r *http.Request) (*oauthclient.Client, bool, error) {
+	id, secret, basic := r.BasicAuth()
+	formID := r.PostForm.Get("client_id")
+	formSecret := r.PostForm.Get("client_secret")
+
+	switch {
+	case basic && formSecret != "":
+		return nil, false, newTokenError(errInvalidRequest, "multiple client authentication methods")
+
+	case basic:
+		// The credentials are form encoded before they are placed in the
+		// header, RFC 6749 section 2.3.1.
+		var err error
+		if id, err = url.QueryUnescape(id); err != nil {
+			return nil, false, newTokenError(errInvalidClient, "malformed client credentials")
+		}
+		if secret, err = url.QueryUnescape(secret); err != nil {
+			return nil, false, newTokenError(errInvalidClient, "malformed client credentials")
+		}
+
+	case formSecret != "":
+		id, secret = formID, formSecret
+
+	case formID != "":
+		clt, err := h.identifyClient(ctx, formID)
+		if err != nil {
+			return nil, false, err
+		}
+		return clt, false, nil
+
+	default:
+		return nil, false, nil
+	}
+
+	clientID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, false, newTokenError(errInvalidClient, "client authentication failed")
+	}
+
+	// The tenant isn't known until the client is found.
+	clt, err := h.Client.Authenticate(tenant.SetAll(ctx), clientID, secret)
+	if err != nil {
+		if errors.Is(err, oauthclient.ErrAuthenticationFailure) {
+			return nil, false, newTokenError(errInvalidClient, "client authentication failed")
+		}
+		return nil, false, fmt.Errorf("authenticating client: %w", err)
+	}
+
+	return &clt, true, nil
+}
+
+// identifyClient returns the client with the specified id, the client
+// doesn't prove who it is.
+func (h Handlers) identifyClient(ctx context.Context, id string) (*oauthclient.Client, error) {
+	clientID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, newTokenError(errInvalidClient, "unknown client")
+	}
+
+	// The tenant isn't known until the client is found.
+	clt, err := h.Client.QueryByID(tenant.SetAll(ctx), clientID)
+	if err != nil {
+		if errors.Is(err, oauthclient.ErrNotFound) {
+			return nil, newTokenError(errInvalidClient, "unknown client")
+		}
+		return nil, fmt.Errorf("identifying client: %w", err)
+	}
+
+	return &clt, nil
+}
+
+// narrow returns the permissions in the requested scope, which is a space
+// separated list of permissions. An empty scope requests every permission.
+func narrow(perms []string, scope string) ([]string, error) {
+	requested := strings.Fields(scope)
+	if len(requested) == 0 {
+		return perms, nil
+	}
+
+	narrowed := intersect(perms, requested)
+	if len(narrowed) != len(uniqueValues(requested)) {
+		return nil, newTokenError(errInvalidScope, "scope includes permissions the client doesn't hold")
+	}
+
+	return narrowed, nil
+}
+
+// intersect returns the values of a that are also in b.
+func intersect(a []string, b []string) []string {
+	set := make(map[string]bool, len(b))
+	for _, v := range b {
+		set[v] = true
+	}
+
+	values := make([]string, 0, len(a))
+	for _, v := range a {
+		if set[v] {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
+
+// uniqueValues returns the values without duplicates.
+func uniqueValues(values []string) []string {
+	set := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+
+	for _, v := range values {
+		if !set[v] {
+			set[v] = true
+			unique = append(unique, v)
+		}
+	}
+
+	return unique
+}
@@ -0,0 +1,160 @@
+// Package productgrp maintains the group of handlers for product access.
+package productgrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ardanlabs/service/business/core/product"
+	"github.com/ardanlabs/service/business/web/auth"
+	v1Web "github.com/ardanlabs/service/business/web/v1"
+	"github.com/ardanlabs/service/foundation/web"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidID = errors.New("ID is not in its proper form")
+
+// Handlers manages the set of product endpoints.
+type Handlers struct {
+	Product *product.Core
+}
+
+// Owner returns the user that owns the product identified in the request so
+// access to the product can be authorized.
+func (h Handlers) Owner(ctx context.Context, r *http.Request) (string, error) {
+	productID, err := uuid.Parse(web.Param(r, "id"))
+	if err != nil {
+		return "", v1Web.NewRequestError(ErrInvalidID, http.StatusBadRequest)
+	}
+
+	prd, err := h.Product.QueryByID(ctx, productID)
+	if err != nil {
+		switch {
+		case errors.Is(err, product.ErrNotFound):
+			return "", v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return "", fmt.Errorf("ID[%s]: %w", productID, err)
+		}
+	}
+
+	return prd.UserID.String(), nil
+}
+
+// Create adds a new product to the system. The product is owned by the
+// authenticated user making the call.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var np product.NewProduct
+	if err := web.Decode(r, &np); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	claims := auth.GetClaims(ctx)
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return auth.NewAuthError("invalid subject in claims")
+	}
+	np.UserID = userID
+
+	prd, err := h.Product.Create(ctx, np)
+	if err != nil {
+		return fmt.Errorf("product[%+v]: %w", &np, err)
+	}
+
+	return web.Respond(ctx, w, prd, http.StatusCreated)
+}
+
+// Update updates a product in the system.
+func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var upd product.UpdateProduct
+	if err := web.Decode(r, &upd); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	productID, err := uuid.Parse(web.Param(r, "id"))
+	if err != nil {
+		return v1Web.NewRequestError(ErrInvalidID, http.StatusBadRequest)
+	}
+
+	prd, err := h.Product.QueryByID(ctx, productID)
+	if err != nil {
+		switch {
+		case errors.Is(err, product.ErrNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", productID, err)
+		}
+	}
+
+	prd, err = h.Product.Update(ctx, prd, upd)
+	if err != nil {
+		return fmt.Errorf("ID[%s] Product[%+v]: %w", productID, &upd, err)
+	}
+
+	return web.Respond(ctx, w, prd, http.StatusOK)
+}
+
+// Delete removes a product from the system.
+func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	productID, err := uuid.Parse(web.Param(r, "id"))
+	if err != nil {
+		return v1Web.NewRequestError(ErrInvalidID, http.StatusBadRequest)
+	}
+
+	prd, err := h.Product.QueryByID(ctx, productID)
+	if err != nil {
+		switch {
+		case errors.Is(err, product.ErrNotFound):
+			return web.Respond(ctx, w, nil, http.StatusNoContent)
+		default:
+			return fmt.Errorf("ID[%s]: %w", productID, err)
+		}
+	}
+
+	if err := h.Product.Delete(ctx, prd); err != nil {
+		return fmt.Errorf("ID[%s]: %w", productID, err)
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Query returns a page of products.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	page, err := v1Web.ParsePageRequest(r)
+	if err != nil {
+		return err
+	}
+
+	prds, err := h.Product.Query(ctx, page.Number, page.RowsPerPage)
+	if err != nil {
+		return fmt.Errorf("unable to query for products: %w", err)
+	}
+
+	total, err := h.Product.Count(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to count products: %w", err)
+	}
+
+	return web.Respond(ctx, w, v1Web.NewPageDocument(prds, total, page.Number, page.RowsPerPage), http.StatusOK)
+}
+
+// QueryByID returns a product by its ID.
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	productID, err := uuid.Parse(web.Param(r, "id"))
+	if err != nil {
+		return v1Web.NewRequestError(ErrInvalidID, http.StatusBadRequest)
+	}
+
+	prd, err := h.Product.QueryByID(ctx, productID)
+	if err != nil {
+		switch {
+		case errors.Is(err, product.ErrNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", productID, err)
+		}
+	}
+
+	return web.Respond(ctx, w, prd, http.StatusOK)
+}
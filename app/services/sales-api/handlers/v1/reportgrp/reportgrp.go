@@ -0,0 +1,70 @@
+// Package reportgrp maintains the group of handlers for reporting.
+package reportgrp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/report"
+	v1Web "github.com/ardanlabs/service/business/web/v1"
+	"github.com/ardanlabs/service/foundation/web"
+)
+
+// defaultRange is the report range used when no start date is provided.
+const defaultRange = 30 * 24 * time.Hour
+
+// Handlers manages the set of report endpoints.
+type Handlers struct {
+	Report *report.Core
+}
+
+// Sales returns revenue, unit counts and average sale value for a date range.
+// The range is provided with the start and end query parameters in either
+// RFC3339 or YYYY-MM-DD form. The report can be broken into day, week or
+// month buckets and grouped by product or user.
+func (h Handlers) Sales(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	values := r.URL.Query()
+
+	end := time.Now()
+	if v := values.Get("end"); v != "" {
+		t, err := parseDate(v)
+		if err != nil {
+			return v1Web.NewRequestError(fmt.Errorf("invalid end format [%s]", v), http.StatusBadRequest)
+		}
+		end = t
+	}
+
+	start := end.Add(-defaultRange)
+	if v := values.Get("start"); v != "" {
+		t, err := parseDate(v)
+		if err != nil {
+			return v1Web.NewRequestError(fmt.Errorf("invalid start format [%s]", v), http.StatusBadRequest)
+		}
+		start = t
+	}
+
+	filter := report.SalesFilter{
+		StartDate: start,
+		EndDate:   end,
+		Bucket:    values.Get("bucket"),
+		GroupBy:   values.Get("group"),
+	}
+
+	sums, err := h.Report.QuerySales(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("unable to query sales report: %w", err)
+	}
+
+	return web.Respond(ctx, w, sums, http.StatusOK)
+}
+
+// parseDate accepts a date in RFC3339 or YYYY-MM-DD form.
+func parseDate(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+
+	return time.Parse("2006-01-02", v)
+}
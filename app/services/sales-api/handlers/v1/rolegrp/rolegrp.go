@@ -0,0 +1,125 @@
+// Package rolegrp maintains the group of handlers for managing roles and the
+// permissions they carry. Changes to a role apply to the tokens issued after
+// the change, so they are picked up when a session is refreshed.
+package rolegrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ardanlabs/service/business/core/role"
+	v1Web "github.com/ardanlabs/service/business/web/v1"
+	"github.com/ardanlabs/service/foundation/web"
+)
+
+// Handlers manages the set of role endpoints.
+type Handlers struct {
+	Role *role.Core
+}
+
+// Create adds a new role to the system.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var nr role.NewRole
+	if err := web.Decode(r, &nr); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	rol, err := h.Role.Create(ctx, nr)
+	if err != nil {
+		return roleError(err, fmt.Sprintf("role[%+v]", &nr))
+	}
+
+	return web.Respond(ctx, w, rol, http.StatusCreated)
+}
+
+// Delete removes a role from the system.
+func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	name := web.Param(r, "name")
+
+	if err := h.Role.Delete(ctx, name); err != nil {
+		if errors.Is(err, role.ErrNotFound) {
+			return web.Respond(ctx, w, nil, http.StatusNoContent)
+		}
+		return roleError(err, fmt.Sprintf("name[%s]", name))
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// AddPermission attaches a permission to a role.
+func (h Handlers) AddPermission(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	name := web.Param(r, "name")
+	permission := web.Param(r, "permission")
+
+	rol, err := h.Role.AddPermission(ctx, name, permission)
+	if err != nil {
+		return roleError(err, fmt.Sprintf("name[%s] permission[%s]", name, permission))
+	}
+
+	return web.Respond(ctx, w, rol, http.StatusOK)
+}
+
+// RemovePermission detaches a permission from a role.
+func (h Handlers) RemovePermission(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	name := web.Param(r, "name")
+	permission := web.Param(r, "permission")
+
+	rol, err := h.Role.RemovePermission(ctx, name, permission)
+	if err != nil {
+		return roleError(err, fmt.Sprintf("name[%s] permission[%s]", name, permission))
+	}
+
+	return web.Respond(ctx, w, rol, http.StatusOK)
+}
+
+// Query returns every role.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	roles, err := h.Role.Query(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to query for roles: %w", err)
+	}
+
+	return web.Respond(ctx, w, roles, http.StatusOK)
+}
+
+// QueryByName returns a role by its name.
+func (h Handlers) QueryByName(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	name := web.Param(r, "name")
+
+	rol, err := h.Role.QueryByName(ctx, name)
+	if err != nil {
+		return roleError(err, fmt.Sprintf("name[%s]", name))
+	}
+
+	return web.Respond(ctx, w, rol, http.StatusOK)
+}
+
+// QueryPermissions returns every permission a role can carry.
+func (h Handlers) QueryPermissions(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	perms, err := h.Role.QueryPermissions(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to query for permissions: %w", err)
+	}
+
+	return web.Respond(ctx, w, perms, http.StatusOK)
+}
+
+// =============================================================================
+
+// roleError maps the errors of the role core to the response for the client.
+func roleError(err error, msg string) error {
+	switch {
+	case errors.Is(err, role.ErrNotFound):
+		return v1Web.NewRequestError(err, http.StatusNotFound)
+	case errors.Is(err, role.ErrInvalidName), errors.Is(err, role.ErrUnknownPermission):
+		return v1Web.NewRequestError(err, http.StatusBadRequest)
+	case errors.Is(err, role.ErrUniqueName), errors.Is(err, role.ErrInUse):
+		return v1Web.NewRequestError(err, http.StatusConflict)
+	case errors.Is(err, role.ErrReserved):
+		return v1Web.NewRequestError(err, http.StatusForbidden)
+	}
+
+	return fmt.Errorf("%s: %w", msg, err)
+}
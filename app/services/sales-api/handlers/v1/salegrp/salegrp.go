@@ -0,0 +1,50 @@
+// Package salegrp maintains the group of handlers for sale access.
+package salegrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ardanlabs/service/business/core/sale"
+	"github.com/ardanlabs/service/business/web/auth"
+	v1Web "github.com/ardanlabs/service/business/web/v1"
+	"github.com/ardanlabs/service/foundation/web"
+	"github.com/google/uuid"
+)
+
+// Handlers manages the set of sale endpoints.
+type Handlers struct {
+	Sale *sale.Core
+}
+
+// Create records a new sale for the authenticated user and decrements the
+// inventory of the product that was sold.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var ns sale.NewSale
+	if err := web.Decode(r, &ns); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	claims := auth.GetClaims(ctx)
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return auth.NewAuthError("invalid subject in claims")
+	}
+	ns.UserID = userID
+
+	sl, err := h.Sale.Create(ctx, ns)
+	if err != nil {
+		switch {
+		case errors.Is(err, sale.ErrProductNotFound):
+			return v1Web.NewRequestError(sale.ErrProductNotFound, http.StatusNotFound)
+		case sale.IsInsufficientStock(err):
+			return v1Web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("sale[%+v]: %w", &ns, err)
+		}
+	}
+
+	return web.Respond(ctx, w, sl, http.StatusCreated)
+}
@@ -0,0 +1,66 @@
+package usergrp
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/user"
+	"github.com/ardanlabs/service/business/sys/validate"
+)
+
+// parseFilter constructs a user filter from the query parameters of the
+// request. Dates are accepted in RFC3339 form.
+func parseFilter(r *http.Request) (user.QueryFilter, error) {
+	values := r.URL.Query()
+
+	var filter user.QueryFilter
+
+	if name := values.Get("name"); name != "" {
+		filter.Name = &name
+	}
+
+	if email := values.Get("email"); email != "" {
+		addr, err := mail.ParseAddress(email)
+		if err != nil {
+			return user.QueryFilter{}, validate.FieldErrors{{Field: "email", Error: err.Error()}}
+		}
+		filter.Email = addr
+	}
+
+	if role := values.Get("role"); role != "" {
+		filter.Role = &role
+	}
+
+	if enabled := values.Get("enabled"); enabled != "" {
+		b, err := strconv.ParseBool(enabled)
+		if err != nil {
+			return user.QueryFilter{}, validate.FieldErrors{{Field: "enabled", Error: err.Error()}}
+		}
+		filter.Enabled = &b
+	}
+
+	if start := values.Get("startCreatedDate"); start != "" {
+		t, err := time.Parse(time.RFC3339, start)
+		if err != nil {
+			return user.QueryFilter{}, validate.FieldErrors{{Field: "startCreatedDate", Error: err.Error()}}
+		}
+		filter.StartCreatedDate = &t
+	}
+
+	if end := values.Get("endCreatedDate"); end != "" {
+		t, err := time.Parse(time.RFC3339, end)
+		if err != nil {
+			return user.QueryFilter{}, validate.FieldErrors{{Field: "endCreatedDate", Error: err.Error()}}
+		}
+		filter.EndCreatedDate = &t
+	}
+
+	if err := filter.Validate(); err != nil {
+		return user.QueryFilter{}, fmt.Errorf("filter: %w", err)
+	}
+
+	return filter, nil
+}
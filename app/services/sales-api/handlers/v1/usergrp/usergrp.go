@@ -7,11 +7,16 @@ import (
 	"fmt"
 	"net/http"
 	"net/mail"
-	"strconv"
 	"time"
 
+	"github.com/ardanlabs/service/business/core/role"
+	"github.com/ardanlabs/service/business/core/token"
 	"github.com/ardanlabs/service/business/core/user"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/ardanlabs/service/business/sys/order"
+	"github.com/ardanlabs/service/business/sys/tenant"
 	"github.com/ardanlabs/service/business/web/auth"
+	"github.com/ardanlabs/service/business/web/keystore"
 	v1Web "github.com/ardanlabs/service/business/web/v1"
 	"github.com/ardanlabs/service/foundation/web"
 	"github.com/golang-jwt/jwt/v4"
@@ -20,10 +25,15 @@ import (
 
 var ErrInvalidID = errors.New("ID is not in its proper form")
 
+// ImpersonationTTL is how long a token issued to impersonate a user is valid.
+const ImpersonationTTL = 15 * time.Minute
+
 // Handlers manages the set of user endpoints.
 type Handlers struct {
-	User *user.Core
-	Auth *auth.Auth
+	User   *user.Core
+	Role   *role.Core
+	Tokens *token.Core
+	Auth   *auth.Auth
 }
 
 // Create adds a new user to the system.
@@ -33,10 +43,17 @@ func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Req
 		return fmt.Errorf("unable to decode payload: %w", err)
 	}
 
+	if err := h.checkGrant(ctx, nu.Roles); err != nil {
+		return err
+	}
+
 	usr, err := h.User.Create(ctx, nu)
 	if err != nil {
-		if errors.Is(err, user.ErrUniqueEmail) {
+		switch {
+		case errors.Is(err, user.ErrUniqueEmail):
 			return v1Web.NewRequestError(err, http.StatusConflict)
+		case errors.Is(err, user.ErrInvalidTenant):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
 		}
 		return fmt.Errorf("user[%+v]: %w", &usr, err)
 	}
@@ -57,8 +74,14 @@ func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Req
 	}
 
 	claims := auth.GetClaims(ctx)
-	if claims.Subject != userID.String() && h.Auth.Authorize(ctx, claims, auth.RuleAdminOnly) != nil {
-		return auth.NewAuthError("auth failed")
+	if err := h.Auth.AuthorizeFields(ctx, claims, auth.RuleUserFields, updatedFields(upd)); err != nil {
+		return auth.NewAuthError("not authorized to change fields: %s", err)
+	}
+
+	if upd.Roles != nil {
+		if err := h.checkGrant(ctx, upd.Roles); err != nil {
+			return err
+		}
 	}
 
 	usr, err := h.User.QueryByID(ctx, userID)
@@ -86,11 +109,6 @@ func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Req
 		return v1Web.NewRequestError(ErrInvalidID, http.StatusBadRequest)
 	}
 
-	claims := auth.GetClaims(ctx)
-	if claims.Subject != userID.String() && h.Auth.Authorize(ctx, claims, auth.RuleAdminOnly) != nil {
-		return auth.NewAuthError("auth failed")
-	}
-
 	usr, err := h.User.QueryByID(ctx, userID)
 	if err != nil {
 		switch {
@@ -108,20 +126,99 @@ func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Req
 	return web.Respond(ctx, w, nil, http.StatusNoContent)
 }
 
-// Query returns a list of users with paging.
+// AssignRoles replaces the set of roles granted to a user. The roles must
+// exist and can only carry permissions the caller holds.
+func (h Handlers) AssignRoles(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var req struct {
+		Roles []string `json:"roles"`
+	}
+	if err := web.Decode(r, &req); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	if len(req.Roles) == 0 {
+		return v1Web.NewRequestError(errors.New("missing roles"), http.StatusBadRequest)
+	}
+
+	userID, err := uuid.Parse(web.Param(r, "id"))
+	if err != nil {
+		return v1Web.NewRequestError(ErrInvalidID, http.StatusBadRequest)
+	}
+
+	if err := h.checkGrant(ctx, req.Roles); err != nil {
+		return err
+	}
+
+	usr, err := h.User.QueryByID(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", userID, err)
+		}
+	}
+
+	usr, err = h.User.Update(ctx, usr, user.UpdateUser{Roles: req.Roles})
+	if err != nil {
+		return fmt.Errorf("ID[%s] roles%v: %w", userID, req.Roles, err)
+	}
+
+	return web.Respond(ctx, w, usr, http.StatusOK)
+}
+
+// Query returns a page of users. The set of users can be filtered and
+// ordered using query parameters. Paging is either by page number or, when
+// the cursor parameter is present, by keyset cursor.
 func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	page := web.Param(r, "page")
-	pageNumber, err := strconv.Atoi(page)
+	page, err := v1Web.ParsePageRequest(r)
 	if err != nil {
-		return v1Web.NewRequestError(fmt.Errorf("invalid page format [%s]", page), http.StatusBadRequest)
+		return err
 	}
-	rows := web.Param(r, "rows")
-	rowsPerPage, err := strconv.Atoi(rows)
+
+	filter, err := parseFilter(r)
 	if err != nil {
-		return v1Web.NewRequestError(fmt.Errorf("invalid rows format [%s]", rows), http.StatusBadRequest)
+		return v1Web.NewRequestError(err, http.StatusBadRequest)
 	}
 
-	users, err := h.User.Query(ctx, pageNumber, rowsPerPage)
+	// Roles are managed at runtime, so the role filtered on is checked
+	// against the roles that exist.
+	if filter.Role != nil {
+		if _, err := h.Role.QueryByName(ctx, *filter.Role); err != nil {
+			if errors.Is(err, role.ErrNotFound) {
+				return v1Web.NewRequestError(err, http.StatusBadRequest)
+			}
+			return fmt.Errorf("unable to query role[%s]: %w", *filter.Role, err)
+		}
+	}
+
+	values := r.URL.Query()
+
+	orderBy, err := order.Parse(values.Get("orderBy"), user.DefaultOrderBy)
+	if err != nil {
+		return v1Web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	total, err := h.User.Count(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("unable to count users: %w", err)
+	}
+
+	// When a cursor is provided, even an empty one, keyset pagination is used
+	// and the page number is ignored.
+	if values.Has("cursor") {
+		users, next, err := h.User.QueryAfter(ctx, filter, orderBy, values.Get("cursor"), page.RowsPerPage)
+		if err != nil {
+			if errors.Is(err, user.ErrInvalidOrder) || errors.Is(err, database.ErrInvalidCursor) {
+				return v1Web.NewRequestError(err, http.StatusBadRequest)
+			}
+			return fmt.Errorf("unable to query for users: %w", err)
+		}
+
+		return web.Respond(ctx, w, v1Web.NewCursorDocument(users, total, page.RowsPerPage, next), http.StatusOK)
+	}
+
+	users, err := h.User.Query(ctx, filter, orderBy, page.Number, page.RowsPerPage)
 	if err != nil {
 		if errors.Is(err, user.ErrInvalidOrder) {
 			return v1Web.NewRequestError(err, http.StatusBadRequest)
@@ -129,7 +226,7 @@ func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		return fmt.Errorf("unable to query for users: %w", err)
 	}
 
-	return web.Respond(ctx, w, users, http.StatusOK)
+	return web.Respond(ctx, w, v1Web.NewPageDocument(users, total, page.Number, page.RowsPerPage), http.StatusOK)
 }
 
 // QueryByID returns a user by its ID.
@@ -139,11 +236,6 @@ func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.
 		return v1Web.NewRequestError(ErrInvalidID, http.StatusBadRequest)
 	}
 
-	claims := auth.GetClaims(ctx)
-	if claims.Subject != userID.String() && h.Auth.Authorize(ctx, claims, auth.RuleAdminOnly) != nil {
-		return auth.NewAuthError("auth failed")
-	}
-
 	usr, err := h.User.QueryByID(ctx, userID)
 	if err != nil {
 		switch {
@@ -157,7 +249,9 @@ func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.
 	return web.Respond(ctx, w, usr, http.StatusOK)
 }
 
-// Token provides an API token for the authenticated user.
+// Token provides an API token for the authenticated user. It's kept for
+// existing clients, new clients use the password grant of the OAuth2 token
+// endpoint.
 func (h Handlers) Token(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	kid := web.Param(r, "kid")
 	if kid == "" {
@@ -174,35 +268,309 @@ func (h Handlers) Token(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		return auth.NewAuthError("invalid email format")
 	}
 
-	usr, err := h.User.Authenticate(ctx, *addr, pass)
+	// The tenant isn't known until the user is found, emails are unique
+	// across every tenant.
+	usr, err := h.User.Authenticate(tenant.SetAll(ctx), *addr, pass)
 	if err != nil {
 		switch {
 		case errors.Is(err, user.ErrNotFound):
 			return v1Web.NewRequestError(err, http.StatusNotFound)
-		case errors.Is(err, user.ErrAuthenticationFailure):
+		case errors.Is(err, user.ErrAuthenticationFailure), errors.Is(err, user.ErrUserDisabled):
 			return auth.NewAuthError(err.Error())
 		default:
 			return fmt.Errorf("authenticating: %w", err)
 		}
 	}
 
-	claims := auth.Claims{
-		RegisteredClaims: jwt.RegisteredClaims{
-			Subject:   usr.ID.String(),
-			Issuer:    "service project",
-			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		},
-		Roles: usr.Roles,
+	refresh, err := h.Tokens.Issue(ctx, usr.ID, uuid.Nil)
+	if err != nil {
+		return fmt.Errorf("issuing refresh token: %w", err)
+	}
+
+	tkn, err := h.newToken(ctx, kid, usr, refresh)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, tkn, http.StatusOK)
+}
+
+// Refresh exchanges a refresh token for a new access token and refresh token.
+// Presenting a refresh token that was already exchanged revokes the session.
+// It's kept for existing clients, new clients use the refresh token grant of
+// the OAuth2 token endpoint.
+func (h Handlers) Refresh(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	kid := web.Param(r, "kid")
+	if kid == "" {
+		return v1Web.NewRequestError(errors.New("missing kid"), http.StatusBadRequest)
+	}
+
+	var req struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := web.Decode(r, &req); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
 	}
 
-	var tkn struct {
-		Token string `json:"token"`
+	if req.RefreshToken == "" {
+		return v1Web.NewRequestError(errors.New("missing refresh token"), http.StatusBadRequest)
 	}
-	tkn.Token, err = h.Auth.GenerateToken(kid, claims)
+
+	refresh, err := h.Tokens.Rotate(ctx, req.RefreshToken, uuid.Nil)
 	if err != nil {
-		return fmt.Errorf("generating token: %w", err)
+		switch {
+		case errors.Is(err, token.ErrInvalidToken), errors.Is(err, token.ErrTokenReused), errors.Is(err, token.ErrWrongClient):
+			return auth.NewAuthError(err.Error())
+		default:
+			return fmt.Errorf("rotating refresh token: %w", err)
+		}
+	}
+
+	// The refresh token identifies the user, the tenant comes from the user.
+	usr, err := h.User.QueryByID(tenant.SetAll(ctx), refresh.UserID)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return auth.NewAuthError("user[%s] not found", refresh.UserID)
+		}
+		return fmt.Errorf("ID[%s]: %w", refresh.UserID, err)
+	}
+
+	if !usr.Enabled {
+		if err := h.Tokens.RevokeSession(ctx, refresh.FamilyID); err != nil {
+			return fmt.Errorf("revoking session: %w", err)
+		}
+		return auth.NewAuthError(user.ErrUserDisabled.Error())
+	}
+
+	tkn, err := h.newToken(ctx, kid, usr, refresh)
+	if err != nil {
+		return err
 	}
 
 	return web.Respond(ctx, w, tkn, http.StatusOK)
 }
+
+// Logout revokes the session the access token was issued for along with the
+// access token itself.
+func (h Handlers) Logout(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims := auth.GetClaims(ctx)
+
+	if claims.SessionID != "" {
+		familyID, err := uuid.Parse(claims.SessionID)
+		if err != nil {
+			return auth.NewAuthError("invalid session[%s]", claims.SessionID)
+		}
+
+		if err := h.Tokens.RevokeSession(ctx, familyID); err != nil {
+			return fmt.Errorf("revoking session: %w", err)
+		}
+	}
+
+	if claims.ID != "" {
+		jti, err := uuid.Parse(claims.ID)
+		if err != nil {
+			return auth.NewAuthError("invalid token id[%s]", claims.ID)
+		}
+
+		userID, err := uuid.Parse(claims.Subject)
+		if err != nil {
+			return auth.NewAuthError("invalid subject[%s]", claims.Subject)
+		}
+
+		var expires time.Time
+		if claims.ExpiresAt != nil {
+			expires = claims.ExpiresAt.Time
+		}
+
+		if err := h.Tokens.RevokeAccess(ctx, jti, userID, expires); err != nil {
+			return fmt.Errorf("revoking access token: %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// RevokeSessions revokes every session that belongs to the specified user.
+// The user must belong to the tenant of the caller.
+func (h Handlers) RevokeSessions(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := uuid.Parse(web.Param(r, "id"))
+	if err != nil {
+		return v1Web.NewRequestError(ErrInvalidID, http.StatusBadRequest)
+	}
+
+	if _, err := h.User.QueryByID(ctx, userID); err != nil {
+		switch {
+		case errors.Is(err, user.ErrNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", userID, err)
+		}
+	}
+
+	if err := h.Tokens.RevokeUser(ctx, userID); err != nil {
+		return fmt.Errorf("ID[%s]: %w", userID, err)
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Impersonate provides a short lived access token for another user of the
+// tenant so support staff can reproduce the issues of that user. The token
+// identifies the caller as the actor and can't be refreshed. Users that hold
+// permissions the caller doesn't can't be impersonated.
+func (h Handlers) Impersonate(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	kid := web.Param(r, "kid")
+	if kid == "" {
+		return v1Web.NewRequestError(errors.New("missing kid"), http.StatusBadRequest)
+	}
+
+	userID, err := uuid.Parse(web.Param(r, "id"))
+	if err != nil {
+		return v1Web.NewRequestError(ErrInvalidID, http.StatusBadRequest)
+	}
+
+	actor := auth.GetClaims(ctx)
+	if actor.Subject == userID.String() {
+		return v1Web.NewRequestError(errors.New("can't impersonate yourself"), http.StatusBadRequest)
+	}
+
+	usr, err := h.User.QueryByID(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", userID, err)
+		}
+	}
+
+	if !usr.Enabled {
+		return v1Web.NewRequestError(user.ErrUserDisabled, http.StatusBadRequest)
+	}
+
+	if err := h.checkGrant(ctx, usr.Roles); err != nil {
+		return err
+	}
+
+	claims, err := h.userClaims(ctx, usr)
+	if err != nil {
+		return err
+	}
+	claims.Act = &auth.Actor{Subject: actor.Subject}
+	if expires := time.Now().Add(ImpersonationTTL); expires.Before(claims.ExpiresAt.Time) {
+		claims.ExpiresAt = jwt.NewNumericDate(expires)
+	}
+
+	tkn, err := h.Auth.GenerateToken(kid, claims)
+	if err != nil {
+		return generateError(err)
+	}
+
+	resp := tokenResponse{
+		Token:     tkn,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
+
+// tokenResponse is the set of tokens handed to a client that authenticates or
+// refreshes its session. An impersonation doesn't start a session, so there
+// is no refresh token.
+type tokenResponse struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// newToken generates an access token for the user that belongs to the session
+// of the specified refresh token.
+func (h Handlers) newToken(ctx context.Context, kid string, usr user.User, refresh token.Refresh) (tokenResponse, error) {
+	claims, err := h.userClaims(ctx, usr)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+	claims.SessionID = refresh.FamilyID.String()
+
+	tkn, err := h.Auth.GenerateToken(kid, claims)
+	if err != nil {
+		return tokenResponse{}, generateError(err)
+	}
+
+	resp := tokenResponse{
+		Token:        tkn,
+		RefreshToken: refresh.Token,
+		ExpiresAt:    claims.ExpiresAt.Time,
+	}
+
+	return resp, nil
+}
+
+// generateError maps the failure to generate a token to the response. A kid
+// that isn't the active signing key is a mistake of the client.
+func generateError(err error) error {
+	switch {
+	case errors.Is(err, keystore.ErrKeyRetired), errors.Is(err, keystore.ErrKeyNotFound):
+		return v1Web.NewRequestError(err, http.StatusBadRequest)
+	default:
+		return fmt.Errorf("generating token: %w", err)
+	}
+}
+
+// userClaims constructs the claims for an access token issued to the user.
+// The token carries the permissions of the roles granted to the user.
+func (h Handlers) userClaims(ctx context.Context, usr user.User) (auth.Claims, error) {
+	perms, err := h.Role.Permissions(ctx, usr.Roles)
+	if err != nil {
+		return auth.Claims{}, fmt.Errorf("resolving permissions: %w", err)
+	}
+
+	claims := h.Auth.NewClaims(usr.ID.String(), usr.Roles)
+	claims.Permissions = perms
+	claims.ID = uuid.NewString()
+	claims.TenantID = usr.TenantID.String()
+
+	return claims, nil
+}
+
+// checkGrant validates the authenticated user is allowed to grant the roles.
+func (h Handlers) checkGrant(ctx context.Context, roles []string) error {
+	claims := auth.GetClaims(ctx)
+
+	if err := h.Role.CheckGrant(ctx, roles, claims.Permissions); err != nil {
+		switch {
+		case errors.Is(err, role.ErrNotFound):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, role.ErrReserved), errors.Is(err, role.ErrNotGrantable):
+			return v1Web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return fmt.Errorf("checking roles%v: %w", roles, err)
+		}
+	}
+
+	return nil
+}
+
+// updatedFields returns the names of the fields the client provided in an
+// update so the fields can be authorized.
+func updatedFields(upd user.UpdateUser) []string {
+	var fields []string
+
+	if upd.Name != nil {
+		fields = append(fields, "name")
+	}
+	if upd.Email != nil {
+		fields = append(fields, "email")
+	}
+	if upd.Roles != nil {
+		fields = append(fields, "roles")
+	}
+	if upd.Password != nil {
+		fields = append(fields, "password")
+	}
+	if upd.Enabled != nil {
+		fields = append(fields, "enabled")
+	}
+
+	return fields
+}
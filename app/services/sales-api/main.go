@@ -15,6 +15,8 @@ import (
 	"github.com/ardanlabs/service/app/services/sales-api/handlers"
 	"github.com/ardanlabs/service/business/sys/database"
 	"github.com/ardanlabs/service/business/web/auth"
+	"github.com/ardanlabs/service/business/web/decisionlog"
+	"github.com/ardanlabs/service/business/web/jwks"
 	"github.com/ardanlabs/service/business/web/keystore"
 	"github.com/ardanlabs/service/business/web/v1/debug"
 	"github.com/ardanlabs/service/foundation/logger"
@@ -77,6 +79,24 @@ func run(log *zap.SugaredLogger) error {
 			MaxOpenConns int    `conf:"default:0"`
 			DisableTLS   bool   `conf:"default:true"`
 		}
+		Auth struct {
+			KeysFolder     string `conf:"default:zarf/keys/"`
+			ActiveKID      string
+			KeysRetireTTL  time.Duration `conf:"default:1h"`
+			KeysReloadTime time.Duration `conf:"default:1m"`
+			Issuer         string        `conf:"default:service project"`
+			Audience       []string      `conf:"default:sales-api"`
+			TokenLifetime  time.Duration `conf:"default:1h"`
+			Leeway         time.Duration `conf:"default:30s"`
+			Engine         string        `conf:"default:jwt"`
+			PolicySource   string
+			PolicyReload   time.Duration `conf:"default:1m"`
+			DecisionFile   string
+			DecisionURL    string
+			DecisionSample float64  `conf:"default:0.1"`
+			DecisionRedact []string `conf:"default:Token"`
+			TrustedJWKSURL string
+		}
 	}{
 		Version: conf.Version{
 			Build: build,
@@ -133,14 +153,68 @@ func run(log *zap.SugaredLogger) error {
 
 	log.Infow("startup", "status", "initializing authentication support")
 
-	keyStore, err := keystore.New()
+	keyStore, err := keystore.New(keystore.Config{
+		Log:       log,
+		Dir:       cfg.Auth.KeysFolder,
+		ActiveKID: cfg.Auth.ActiveKID,
+		RetireTTL: cfg.Auth.KeysRetireTTL,
+	})
 	if err != nil {
 		return fmt.Errorf("constructing keystore: %w", err)
 	}
 
+	stopKeyWatch := keyStore.Watch(cfg.Auth.KeysReloadTime)
+	defer stopKeyWatch()
+
+	// Decisions are written to the service log unless a file or an endpoint
+	// accepting OPA decision logs is configured.
+	var decisionSink auth.DecisionSink
+	switch {
+	case cfg.Auth.DecisionFile != "":
+		file, err := decisionlog.NewFile(log, cfg.Auth.DecisionFile)
+		if err != nil {
+			return fmt.Errorf("constructing decision log: %w", err)
+		}
+		defer file.Close()
+		decisionSink = file
+
+	case cfg.Auth.DecisionURL != "":
+		sink := decisionlog.NewHTTP(decisionlog.HTTPConfig{
+			Log: log,
+			URL: cfg.Auth.DecisionURL,
+		})
+		defer sink.Close()
+		decisionSink = sink
+	}
+
+	// Tokens issued by another service are trusted when the URL of the key
+	// set it publishes is configured. The other service must issue tokens
+	// for the same issuer and audience.
+	var keyLookup auth.KeyLookup = keyStore
+	if cfg.Auth.TrustedJWKSURL != "" {
+		remote := jwks.NewRemote(jwks.RemoteConfig{
+			Log: log,
+			URL: cfg.Auth.TrustedJWKSURL,
+		})
+		keyLookup = jwks.NewFallback(keyStore, remote)
+
+		log.Infow("startup", "status", "trusting remote key set", "url", cfg.Auth.TrustedJWKSURL)
+	}
+
 	authCfg := auth.Config{
-		Log:       log,
-		KeyLookup: keyStore,
+		Log:           log,
+		KeyLookup:     keyLookup,
+		Issuer:        cfg.Auth.Issuer,
+		Audience:      cfg.Auth.Audience,
+		TokenLifetime: cfg.Auth.TokenLifetime,
+		Leeway:        cfg.Auth.Leeway,
+		Engine:        cfg.Auth.Engine,
+		PolicySource:  cfg.Auth.PolicySource,
+		DecisionLog: auth.DecisionLogConfig{
+			Sink:       decisionSink,
+			SampleRate: cfg.Auth.DecisionSample,
+			Redact:     cfg.Auth.DecisionRedact,
+		},
 	}
 
 	auth, err := auth.New(authCfg)
@@ -148,13 +222,18 @@ func run(log *zap.SugaredLogger) error {
 		return fmt.Errorf("constructing auth: %w", err)
 	}
 
+	log.Infow("startup", "status", "policies loaded", "source", auth.PolicyStatus().Source, "revision", auth.PolicyStatus().Revision)
+
+	stopPolicyWatch := auth.WatchPolicies(cfg.Auth.PolicyReload)
+	defer stopPolicyWatch()
+
 	// =========================================================================
 	// Start Debug Service
 
 	log.Infow("startup", "status", "debug v1 router started", "host", cfg.Web.DebugHost)
 
 	go func() {
-		if err := http.ListenAndServe(cfg.Web.DebugHost, debug.Mux(build, log, db)); err != nil {
+		if err := http.ListenAndServe(cfg.Web.DebugHost, debug.Mux(build, log, db, auth)); err != nil {
 			log.Errorw("shutdown", "status", "debug v1 router closed", "host", cfg.Web.DebugHost, "ERROR", err)
 		}
 	}()
@@ -168,10 +247,12 @@ func run(log *zap.SugaredLogger) error {
 	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
 
 	apiMux := handlers.APIMux(handlers.APIMuxConfig{
-		Shutdown: shutdown,
-		Log:      log,
-		Auth:     auth,
-		DB:       db,
+		Shutdown:  shutdown,
+		Log:       log,
+		Auth:      auth,
+		Keys:      keyStore,
+		ActiveKID: keyStore.ActiveKID,
+		DB:        db,
 	})
 
 	api := http.Server{
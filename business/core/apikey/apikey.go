@@ -0,0 +1,237 @@
+// Package apikey provides the core business API for the long lived keys
+// machine clients use to authenticate as a user.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/google/uuid"
+)
+
+// Set of error variables for API key operations.
+var (
+	ErrNotFound       = errors.New("api key not found")
+	ErrInvalidKey     = errors.New("api key is not valid")
+	ErrAddressDenied  = errors.New("api key can't be used from this address")
+	ErrInvalidExpiry  = errors.New("api key expiry must be in the future")
+	ErrInvalidKeyUser = errors.New("api key user is not valid")
+	ErrNotGrantable   = errors.New("api key carries permissions that aren't held")
+)
+
+// prefix is added to every key so it can be recognized, for example by
+// secret scanners.
+const prefix = "sak_"
+
+// lastUsedInterval is how often the last time a key was used is recorded.
+// Keys used by batch jobs make lots of requests, recording every use would
+// turn every request into a write.
+const lastUsedInterval = time.Minute
+
+// Storer interface declares the behavior this package needs to perists and
+// retrieve data.
+type Storer interface {
+	Create(ctx context.Context, key Key) error
+	Revoke(ctx context.Context, keyID uuid.UUID, dateRevoked time.Time) error
+	MarkUsed(ctx context.Context, keyID uuid.UUID, dateUsed time.Time) error
+	QueryByID(ctx context.Context, keyID uuid.UUID) (Key, error)
+	QueryByHash(ctx context.Context, hash string) (Key, error)
+	QueryByUserID(ctx context.Context, userID uuid.UUID) ([]Key, error)
+}
+
+// Core manages the set of APIs for API key access.
+type Core struct {
+	storer Storer
+}
+
+// NewCore constructs a core for API key api access.
+func NewCore(storer Storer) *Core {
+	return &Core{
+		storer: storer,
+	}
+}
+
+// Create generates a new key for the user. The key can only carry the held
+// permissions. The key is returned along with the record that represents it
+// and can't be retrieved again.
+func (c *Core) Create(ctx context.Context, nk NewKey, held []string) (Created, error) {
+	if err := validate.Check(nk); err != nil {
+		return Created{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	if nk.UserID == uuid.Nil {
+		return Created{}, ErrInvalidKeyUser
+	}
+
+	heldSet := make(map[string]bool, len(held))
+	for _, p := range held {
+		heldSet[p] = true
+	}
+
+	for _, p := range nk.Permissions {
+		if !heldSet[p] {
+			return Created{}, fmt.Errorf("permission[%s]: %w", p, ErrNotGrantable)
+		}
+	}
+
+	now := time.Now()
+
+	var expires time.Time
+	if nk.DateExpires != nil {
+		if !nk.DateExpires.After(now) {
+			return Created{}, ErrInvalidExpiry
+		}
+		expires = *nk.DateExpires
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return Created{}, fmt.Errorf("generating key: %w", err)
+	}
+	tkn := prefix + base64.RawURLEncoding.EncodeToString(b)
+
+	key := Key{
+		ID:           uuid.New(),
+		TenantID:     nk.TenantID,
+		UserID:       nk.UserID,
+		Name:         nk.Name,
+		Prefix:       tkn[:len(prefix)+6],
+		Hash:         hash(tkn),
+		Permissions:  uniqueSorted(nk.Permissions),
+		AllowedCIDRs: networks(nk.AllowedCIDRs),
+		DateCreated:  now,
+		DateExpires:  expires,
+	}
+
+	if err := c.storer.Create(ctx, key); err != nil {
+		return Created{}, fmt.Errorf("create: %w", err)
+	}
+
+	return Created{Key: key, Token: tkn}, nil
+}
+
+// Revoke revokes the specified key of the user. Revoking a key that is
+// already revoked is not an error.
+func (c *Core) Revoke(ctx context.Context, userID uuid.UUID, keyID uuid.UUID) error {
+	key, err := c.storer.QueryByID(ctx, keyID)
+	if err != nil {
+		return fmt.Errorf("query: keyID[%s]: %w", keyID, err)
+	}
+
+	if key.UserID != userID {
+		return fmt.Errorf("keyID[%s] userID[%s]: %w", keyID, userID, ErrNotFound)
+	}
+
+	if key.Revoked() {
+		return nil
+	}
+
+	if err := c.storer.Revoke(ctx, keyID, time.Now()); err != nil {
+		return fmt.Errorf("revoke: keyID[%s]: %w", keyID, err)
+	}
+
+	return nil
+}
+
+// QueryByUserID retrieves the keys of the user, including the ones that are
+// revoked or expired.
+func (c *Core) QueryByUserID(ctx context.Context, userID uuid.UUID) ([]Key, error) {
+	keys, err := c.storer.QueryByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query: userID[%s]: %w", userID, err)
+	}
+
+	return keys, nil
+}
+
+// Authenticate returns the key that matches the token presented by a client
+// connecting from the specified address. The key must not be revoked or
+// expired and must allow the address.
+func (c *Core) Authenticate(ctx context.Context, token string, ip net.IP) (Key, error) {
+	key, err := c.storer.QueryByHash(ctx, hash(token))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return Key{}, ErrInvalidKey
+		}
+		return Key{}, fmt.Errorf("query: %w", err)
+	}
+
+	now := time.Now()
+
+	if key.Revoked() || key.Expired(now) {
+		return Key{}, ErrInvalidKey
+	}
+
+	if ip == nil || !key.Allows(ip) {
+		return Key{}, fmt.Errorf("keyID[%s] address[%s]: %w", key.ID, ip, ErrAddressDenied)
+	}
+
+	if now.Sub(key.DateLastUsed) >= lastUsedInterval {
+		if err := c.storer.MarkUsed(ctx, key.ID, now); err != nil {
+			return Key{}, fmt.Errorf("mark used: keyID[%s]: %w", key.ID, err)
+		}
+		key.DateLastUsed = now
+	}
+
+	return key, nil
+}
+
+// =============================================================================
+
+// hash returns the value stored for a key. The keys are random with enough
+// entropy that a plain sha256 is sufficient and it allows a key to be looked
+// up by its hash.
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// networks converts the allowed addresses to networks in CIDR notation. A
+// single IP address is a network with just that address.
+func networks(allowed []string) []string {
+	cidrs := make([]string, 0, len(allowed))
+
+	for _, a := range allowed {
+		if _, network, err := net.ParseCIDR(a); err == nil {
+			cidrs = append(cidrs, network.String())
+			continue
+		}
+
+		if ip := net.ParseIP(a); ip != nil {
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			network := net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+			cidrs = append(cidrs, network.String())
+		}
+	}
+
+	return cidrs
+}
+
+// uniqueSorted returns the sorted set of the values without duplicates.
+func uniqueSorted(values []string) []string {
+	set := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+
+	for _, v := range values {
+		if !set[v] {
+			set[v] = true
+			unique = append(unique, v)
+		}
+	}
+	sort.Strings(unique)
+
+	return unique
+}
@@ -0,0 +1,119 @@
+package apikey_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/apikey"
+	"github.com/ardanlabs/service/business/core/apikey/stores/apikeydb"
+	"github.com/ardanlabs/service/business/data/dbtest"
+	"github.com/ardanlabs/service/business/sys/tenant"
+	"github.com/ardanlabs/service/foundation/docker"
+	"github.com/google/uuid"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func Test_APIKey(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testapikey")
+	defer func() {
+		if r := recover(); r != nil {
+			t.Log(r)
+			t.Error(string(debug.Stack()))
+		}
+		teardown()
+	}()
+
+	core := apikey.NewCore(apikeydb.NewStore(log, db))
+
+	t.Log("Given the need to authenticate machine clients with API keys.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single key.", testID)
+		{
+			ctx := tenant.Set(context.Background(), tenant.Default)
+			userID := uuid.MustParse("45b5fbd3-755f-4379-8f07-a58d4a30fa2f")
+			expires := time.Now().Add(time.Hour)
+
+			nk := apikey.NewKey{
+				TenantID:     tenant.Default,
+				UserID:       userID,
+				Name:         "nightly export",
+				Permissions:  []string{"reports:read"},
+				AllowedCIDRs: []string{"10.0.0.0/8", "192.168.1.10"},
+				DateExpires:  &expires,
+			}
+
+			if _, err := core.Create(ctx, nk, []string{"products:write"}); !errors.Is(err, apikey.ErrNotGrantable) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to create a key with permissions that aren't held : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to create a key with permissions that aren't held.", dbtest.Success, testID)
+
+			created, err := core.Create(ctx, nk, []string{"reports:read", "users:read"})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a key : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a key.", dbtest.Success, testID)
+
+			key, err := core.Authenticate(ctx, created.Token, net.ParseIP("10.1.2.3"))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to authenticate with the key : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to authenticate with the key.", dbtest.Success, testID)
+
+			if key.ID != created.ID || key.UserID != userID || key.DateLastUsed.IsZero() {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, key)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, created.Key)
+				t.Fatalf("\t%s\tTest %d:\tShould get back the key marked as used.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the key marked as used.", dbtest.Success, testID)
+
+			if _, err := core.Authenticate(ctx, created.Token, net.ParseIP("192.168.1.11")); !errors.Is(err, apikey.ErrAddressDenied) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to use the key from another address : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to use the key from another address.", dbtest.Success, testID)
+
+			if err := core.Revoke(ctx, uuid.New(), created.ID); !errors.Is(err, apikey.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to revoke the key of another user : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to revoke the key of another user.", dbtest.Success, testID)
+
+			if err := core.Revoke(ctx, userID, created.ID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke the key : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to revoke the key.", dbtest.Success, testID)
+
+			if _, err := core.Authenticate(ctx, created.Token, net.ParseIP("10.1.2.3")); !errors.Is(err, apikey.ErrInvalidKey) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to authenticate with a revoked key : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to authenticate with a revoked key.", dbtest.Success, testID)
+
+			keys, err := core.QueryByUserID(ctx, userID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the keys of the user : %s.", dbtest.Failed, testID, err)
+			}
+
+			if len(keys) != 1 || !keys[0].Revoked() || keys[0].Hash == created.Token {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the revoked key without the key itself : %v.", dbtest.Failed, testID, keys)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the revoked key without the key itself.", dbtest.Success, testID)
+		}
+	}
+}
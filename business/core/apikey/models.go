@@ -0,0 +1,99 @@
+package apikey
+
+import (
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Key represents an API key a machine client uses to act as a user. Only a
+// hash of the key is kept, the key itself is handed to the client once when
+// it's created. The prefix is the start of the key so it can be recognized.
+type Key struct {
+	ID           uuid.UUID `json:"id"`
+	TenantID     uuid.UUID `json:"tenantID"`
+	UserID       uuid.UUID `json:"userID"`
+	Name         string    `json:"name"`
+	Prefix       string    `json:"prefix"`
+	Hash         string    `json:"-"`
+	Permissions  []string  `json:"permissions"`
+	AllowedCIDRs []string  `json:"allowedCIDRs"`
+	DateCreated  time.Time `json:"dateCreated"`
+	DateExpires  time.Time `json:"dateExpires,omitempty"`
+	DateLastUsed time.Time `json:"dateLastUsed,omitempty"`
+	DateRevoked  time.Time `json:"dateRevoked,omitempty"`
+}
+
+// Revoked reports whether the key has been revoked.
+func (k Key) Revoked() bool {
+	return !k.DateRevoked.IsZero()
+}
+
+// Expired reports whether the key has expired at the specified time. A key
+// without an expiry never expires.
+func (k Key) Expired(now time.Time) bool {
+	return !k.DateExpires.IsZero() && !now.Before(k.DateExpires)
+}
+
+// Allows reports whether the key can be used from the specified address. A
+// key without allowed networks can be used from anywhere.
+func (k Key) Allows(ip net.IP) bool {
+	if len(k.AllowedCIDRs) == 0 {
+		return true
+	}
+
+	for _, cidr := range k.AllowedCIDRs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// EffectivePermissions returns the permissions of the key that are also in
+// the held permissions. A key never carries more than the user it belongs
+// to, so a permission removed from the roles of the user is removed from the
+// key as well.
+func (k Key) EffectivePermissions(held []string) []string {
+	heldSet := make(map[string]bool, len(held))
+	for _, p := range held {
+		heldSet[p] = true
+	}
+
+	perms := make([]string, 0, len(k.Permissions))
+	for _, p := range k.Permissions {
+		if heldSet[p] {
+			perms = append(perms, p)
+		}
+	}
+
+	return perms
+}
+
+// NewKey contains information needed to create a new Key. The permissions
+// are a subset of the permissions of the user the key belongs to. The user
+// and tenant aren't provided by the client, they are set from the user the
+// key is created for. Allowed addresses can be networks in CIDR notation or
+// single IP addresses.
+type NewKey struct {
+	TenantID     uuid.UUID  `json:"-"`
+	UserID       uuid.UUID  `json:"-"`
+	Name         string     `json:"name" validate:"required"`
+	Permissions  []string   `json:"permissions" validate:"required,min=1,dive,required"`
+	AllowedCIDRs []string   `json:"allowedCIDRs" validate:"dive,cidr|ip"`
+	DateExpires  *time.Time `json:"dateExpires"`
+}
+
+// Created is what is handed back to the client when a key is created. It's
+// the only time the key itself is available.
+type Created struct {
+	Key
+	Token string `json:"key"`
+}
@@ -0,0 +1,176 @@
+// Package apikeydb contains API key related CRUD functionality.
+package apikeydb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/apikey"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for API key database access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs the api for data access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) *Store {
+	return &Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Create inserts a new API key into the database.
+func (s *Store) Create(ctx context.Context, key apikey.Key) error {
+	const q = `
+	INSERT INTO api_keys
+		(key_id, tenant_id, user_id, name, prefix, key_hash, permissions, allowed_cidrs, date_created, date_expires, date_last_used, date_revoked)
+	VALUES
+		(:key_id, :tenant_id, :user_id, :name, :prefix, :key_hash, :permissions, :allowed_cidrs, :date_created, :date_expires, :date_last_used, :date_revoked)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, toDBKey(key)); err != nil {
+		return fmt.Errorf("inserting api key: %w", err)
+	}
+
+	return nil
+}
+
+// Revoke records that the specified key has been revoked.
+func (s *Store) Revoke(ctx context.Context, keyID uuid.UUID, dateRevoked time.Time) error {
+	data := struct {
+		ID          string    `db:"key_id"`
+		DateRevoked time.Time `db:"date_revoked"`
+	}{
+		ID:          keyID.String(),
+		DateRevoked: dateRevoked.UTC(),
+	}
+
+	const q = `
+	UPDATE
+		api_keys
+	SET
+		"date_revoked" = :date_revoked
+	WHERE
+		key_id = :key_id AND
+		date_revoked IS NULL`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("revoking keyID[%s]: %w", keyID, err)
+	}
+
+	return nil
+}
+
+// MarkUsed records the last time the specified key was used.
+func (s *Store) MarkUsed(ctx context.Context, keyID uuid.UUID, dateUsed time.Time) error {
+	data := struct {
+		ID       string    `db:"key_id"`
+		DateUsed time.Time `db:"date_last_used"`
+	}{
+		ID:       keyID.String(),
+		DateUsed: dateUsed.UTC(),
+	}
+
+	const q = `
+	UPDATE
+		api_keys
+	SET
+		"date_last_used" = :date_last_used
+	WHERE
+		key_id = :key_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("updating keyID[%s]: %w", keyID, err)
+	}
+
+	return nil
+}
+
+// QueryByID gets the specified API key from the database.
+func (s *Store) QueryByID(ctx context.Context, keyID uuid.UUID) (apikey.Key, error) {
+	data := struct {
+		ID string `db:"key_id"`
+	}{
+		ID: keyID.String(),
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		api_keys
+	WHERE
+		key_id = :key_id`
+
+	var key dbKey
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &key); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return apikey.Key{}, apikey.ErrNotFound
+		}
+		return apikey.Key{}, fmt.Errorf("selecting keyID[%q]: %w", keyID, err)
+	}
+
+	return toCoreKey(key), nil
+}
+
+// QueryByHash gets the API key with the specified hash.
+func (s *Store) QueryByHash(ctx context.Context, hash string) (apikey.Key, error) {
+	data := struct {
+		Hash string `db:"key_hash"`
+	}{
+		Hash: hash,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		api_keys
+	WHERE
+		key_hash = :key_hash`
+
+	var key dbKey
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &key); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return apikey.Key{}, apikey.ErrNotFound
+		}
+		return apikey.Key{}, fmt.Errorf("selecting api key: %w", err)
+	}
+
+	return toCoreKey(key), nil
+}
+
+// QueryByUserID gets the API keys that belong to the specified user, the
+// most recent first.
+func (s *Store) QueryByUserID(ctx context.Context, userID uuid.UUID) ([]apikey.Key, error) {
+	data := struct {
+		ID string `db:"user_id"`
+	}{
+		ID: userID.String(),
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		api_keys
+	WHERE
+		user_id = :user_id
+	ORDER BY
+		date_created DESC`
+
+	var keys []dbKey
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, data, &keys); err != nil {
+		return nil, fmt.Errorf("selecting api keys userID[%s]: %w", userID, err)
+	}
+
+	return toCoreKeySlice(keys), nil
+}
@@ -0,0 +1,88 @@
+package apikeydb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/apikey"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// dbKey represent the structure we need for moving data
+// between the app and the database.
+type dbKey struct {
+	ID           uuid.UUID      `db:"key_id"`
+	TenantID     uuid.UUID      `db:"tenant_id"`
+	UserID       uuid.UUID      `db:"user_id"`
+	Name         string         `db:"name"`
+	Prefix       string         `db:"prefix"`
+	Hash         string         `db:"key_hash"`
+	Permissions  pq.StringArray `db:"permissions"`
+	AllowedCIDRs pq.StringArray `db:"allowed_cidrs"`
+	DateCreated  time.Time      `db:"date_created"`
+	DateExpires  sql.NullTime   `db:"date_expires"`
+	DateLastUsed sql.NullTime   `db:"date_last_used"`
+	DateRevoked  sql.NullTime   `db:"date_revoked"`
+}
+
+func toDBKey(key apikey.Key) dbKey {
+	return dbKey{
+		ID:           key.ID,
+		TenantID:     key.TenantID,
+		UserID:       key.UserID,
+		Name:         key.Name,
+		Prefix:       key.Prefix,
+		Hash:         key.Hash,
+		Permissions:  key.Permissions,
+		AllowedCIDRs: key.AllowedCIDRs,
+		DateCreated:  key.DateCreated.UTC(),
+		DateExpires:  toNullTime(key.DateExpires),
+		DateLastUsed: toNullTime(key.DateLastUsed),
+		DateRevoked:  toNullTime(key.DateRevoked),
+	}
+}
+
+func toCoreKey(dbKey dbKey) apikey.Key {
+	cidrs := []string(dbKey.AllowedCIDRs)
+	if cidrs == nil {
+		cidrs = []string{}
+	}
+
+	return apikey.Key{
+		ID:           dbKey.ID,
+		TenantID:     dbKey.TenantID,
+		UserID:       dbKey.UserID,
+		Name:         dbKey.Name,
+		Prefix:       dbKey.Prefix,
+		Hash:         dbKey.Hash,
+		Permissions:  dbKey.Permissions,
+		AllowedCIDRs: cidrs,
+		DateCreated:  dbKey.DateCreated.In(time.Local),
+		DateExpires:  fromNullTime(dbKey.DateExpires),
+		DateLastUsed: fromNullTime(dbKey.DateLastUsed),
+		DateRevoked:  fromNullTime(dbKey.DateRevoked),
+	}
+}
+
+func toCoreKeySlice(dbKeys []dbKey) []apikey.Key {
+	keys := make([]apikey.Key, len(dbKeys))
+	for i, dbKey := range dbKeys {
+		keys[i] = toCoreKey(dbKey)
+	}
+	return keys
+}
+
+func toNullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+func fromNullTime(nt sql.NullTime) time.Time {
+	if !nt.Valid {
+		return time.Time{}
+	}
+	return nt.Time.In(time.Local)
+}
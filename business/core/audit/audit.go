@@ -0,0 +1,79 @@
+// Package audit provides the core business API for recording the changes
+// made to the system and who made them.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/google/uuid"
+)
+
+// Storer interface declares the behavior this package needs to perists and
+// retrieve data.
+type Storer interface {
+	Create(ctx context.Context, rec Record) error
+	Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]Record, error)
+	Count(ctx context.Context) (int, error)
+}
+
+// Core manages the set of APIs for audit access.
+type Core struct {
+	storer Storer
+}
+
+// NewCore constructs a core for audit api access.
+func NewCore(storer Storer) *Core {
+	return &Core{
+		storer: storer,
+	}
+}
+
+// Create records a change.
+func (c *Core) Create(ctx context.Context, nr NewRecord) (Record, error) {
+	if err := validate.Check(nr); err != nil {
+		return Record{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	rec := Record{
+		ID:          uuid.New(),
+		TenantID:    nr.TenantID,
+		TraceID:     nr.TraceID,
+		Subject:     nr.Subject,
+		Actor:       nr.Actor,
+		Method:      nr.Method,
+		Route:       nr.Route,
+		Path:        nr.Path,
+		StatusCode:  nr.StatusCode,
+		DateCreated: time.Now(),
+	}
+
+	if err := c.storer.Create(ctx, rec); err != nil {
+		return Record{}, fmt.Errorf("create: %w", err)
+	}
+
+	return rec, nil
+}
+
+// Query retrieves a page of the records of the tenants in scope, the most
+// recent first.
+func (c *Core) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]Record, error) {
+	recs, err := c.storer.Query(ctx, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return recs, nil
+}
+
+// Count returns the total number of records of the tenants in scope.
+func (c *Core) Count(ctx context.Context) (int, error) {
+	count, err := c.storer.Count(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("count: %w", err)
+	}
+
+	return count, nil
+}
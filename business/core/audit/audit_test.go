@@ -0,0 +1,93 @@
+package audit_test
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"testing"
+
+	"github.com/ardanlabs/service/business/core/audit"
+	"github.com/ardanlabs/service/business/core/audit/stores/auditdb"
+	"github.com/ardanlabs/service/business/data/dbtest"
+	"github.com/ardanlabs/service/business/sys/tenant"
+	"github.com/ardanlabs/service/foundation/docker"
+	"github.com/google/uuid"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func Test_Audit(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testaudit")
+	defer func() {
+		if r := recover(); r != nil {
+			t.Log(r)
+			t.Error(string(debug.Stack()))
+		}
+		teardown()
+	}()
+
+	core := audit.NewCore(auditdb.NewStore(log, db))
+
+	t.Log("Given the need to record the changes made to a tenant.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen recording a change made under impersonation.", testID)
+		{
+			ctx := tenant.Set(context.Background(), tenant.Default)
+
+			nr := audit.NewRecord{
+				TenantID:   tenant.Default,
+				TraceID:    uuid.NewString(),
+				Subject:    "45b5fbd3-755f-4379-8f07-a58d4a30fa2f",
+				Actor:      "5cf37266-3473-4006-984f-9325122678b7",
+				Method:     "PUT",
+				Route:      "/products/:id",
+				Path:       "/v1/products/a2b0639f-2cc6-44b8-b97b-15d69dbb511e",
+				StatusCode: 200,
+			}
+
+			rec, err := core.Create(ctx, nr)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to record the change : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to record the change.", dbtest.Success, testID)
+
+			recs, err := core.Query(ctx, 1, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the records : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve the records.", dbtest.Success, testID)
+
+			if len(recs) != 1 || recs[0].ID != rec.ID || recs[0].Actor != nr.Actor {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, recs)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, rec)
+				t.Fatalf("\t%s\tTest %d:\tShould get back the record with the actor.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the record with the actor.", dbtest.Success, testID)
+
+			other := tenant.Set(context.Background(), uuid.New())
+
+			count, err := core.Count(other)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to count the records : %s.", dbtest.Failed, testID, err)
+			}
+
+			if count != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould not see the records of another tenant : %d.", dbtest.Failed, testID, count)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not see the records of another tenant.", dbtest.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package audit
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Record represents a change made to the system through the API. The Actor
+// is set when the change was made with an impersonated token and identifies
+// the admin that really made it.
+type Record struct {
+	ID          uuid.UUID `json:"id"`
+	TenantID    uuid.UUID `json:"tenantID"`
+	TraceID     string    `json:"traceID"`
+	Subject     string    `json:"subject"`
+	Actor       string    `json:"actor,omitempty"`
+	Method      string    `json:"method"`
+	Route       string    `json:"route"`
+	Path        string    `json:"path"`
+	StatusCode  int       `json:"statusCode"`
+	DateCreated time.Time `json:"dateCreated"`
+}
+
+// NewRecord contains information needed to record a change.
+type NewRecord struct {
+	TenantID   uuid.UUID
+	TraceID    string
+	Subject    string `validate:"required"`
+	Actor      string
+	Method     string `validate:"required"`
+	Route      string
+	Path       string `validate:"required"`
+	StatusCode int
+}
@@ -0,0 +1,116 @@
+// Package auditdb contains audit record related CRUD functionality.
+package auditdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/ardanlabs/service/business/core/audit"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/ardanlabs/service/business/sys/tenant"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for audit database access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs the api for data access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) *Store {
+	return &Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Create inserts a new audit record into the database.
+func (s *Store) Create(ctx context.Context, rec audit.Record) error {
+	const q = `
+	INSERT INTO audit_log
+		(audit_id, tenant_id, trace_id, subject, actor, method, route, path, status_code, date_created)
+	VALUES
+		(:audit_id, :tenant_id, :trace_id, :subject, :actor, :method, :route, :path, :status_code, :date_created)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, toDBRecord(rec)); err != nil {
+		return fmt.Errorf("inserting audit record: %w", err)
+	}
+
+	return nil
+}
+
+// Query retrieves a page of the audit records of the tenants in scope, the
+// most recent first.
+func (s *Store) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]audit.Record, error) {
+	data := map[string]any{
+		"offset":        (pageNumber - 1) * rowsPerPage,
+		"rows_per_page": rowsPerPage,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		audit_log`
+
+	buf := bytes.NewBufferString(q)
+	if err := applyTenant(ctx, data, buf); err != nil {
+		return nil, err
+	}
+
+	buf.WriteString(" ORDER BY date_created DESC, audit_id")
+	buf.WriteString(" OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY")
+
+	var recs []dbRecord
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, buf.String(), data, &recs); err != nil {
+		return nil, fmt.Errorf("selecting audit records: %w", err)
+	}
+
+	return toCoreRecordSlice(recs), nil
+}
+
+// Count returns the total number of audit records of the tenants in scope.
+func (s *Store) Count(ctx context.Context) (int, error) {
+	data := map[string]any{}
+
+	const q = `
+	SELECT
+		count(1)
+	FROM
+		audit_log`
+
+	buf := bytes.NewBufferString(q)
+	if err := applyTenant(ctx, data, buf); err != nil {
+		return 0, err
+	}
+
+	var count struct {
+		Count int `db:"count"`
+	}
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, buf.String(), data, &count); err != nil {
+		return 0, fmt.Errorf("selecting audit records count: %w", err)
+	}
+
+	return count.Count, nil
+}
+
+// applyTenant restricts the query to the tenant in scope. Nothing is added
+// when every tenant is in scope.
+func applyTenant(ctx context.Context, data map[string]any, buf *bytes.Buffer) error {
+	scope, err := tenant.Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	if scope.All {
+		return nil
+	}
+
+	data["tenant_id"] = scope.ID
+	buf.WriteString(" WHERE tenant_id = :tenant_id")
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package auditdb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/audit"
+	"github.com/google/uuid"
+)
+
+// dbRecord represent the structure we need for moving data
+// between the app and the database.
+type dbRecord struct {
+	ID          uuid.UUID      `db:"audit_id"`
+	TenantID    uuid.UUID      `db:"tenant_id"`
+	TraceID     string         `db:"trace_id"`
+	Subject     string         `db:"subject"`
+	Actor       sql.NullString `db:"actor"`
+	Method      string         `db:"method"`
+	Route       string         `db:"route"`
+	Path        string         `db:"path"`
+	StatusCode  int            `db:"status_code"`
+	DateCreated time.Time      `db:"date_created"`
+}
+
+func toDBRecord(rec audit.Record) dbRecord {
+	return dbRecord{
+		ID:          rec.ID,
+		TenantID:    rec.TenantID,
+		TraceID:     rec.TraceID,
+		Subject:     rec.Subject,
+		Actor:       sql.NullString{String: rec.Actor, Valid: rec.Actor != ""},
+		Method:      rec.Method,
+		Route:       rec.Route,
+		Path:        rec.Path,
+		StatusCode:  rec.StatusCode,
+		DateCreated: rec.DateCreated.UTC(),
+	}
+}
+
+func toCoreRecord(dbRec dbRecord) audit.Record {
+	return audit.Record{
+		ID:          dbRec.ID,
+		TenantID:    dbRec.TenantID,
+		TraceID:     dbRec.TraceID,
+		Subject:     dbRec.Subject,
+		Actor:       dbRec.Actor.String,
+		Method:      dbRec.Method,
+		Route:       dbRec.Route,
+		Path:        dbRec.Path,
+		StatusCode:  dbRec.StatusCode,
+		DateCreated: dbRec.DateCreated.In(time.Local),
+	}
+}
+
+func toCoreRecordSlice(dbRecs []dbRecord) []audit.Record {
+	recs := make([]audit.Record, len(dbRecs))
+	for i, dbRec := range dbRecs {
+		recs[i] = toCoreRecord(dbRec)
+	}
+	return recs
+}
@@ -0,0 +1,39 @@
+package oauthclient
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Client represents a service registered to request tokens with the OAuth2
+// client credentials grant. The tokens identify the user the client acts for
+// and carry the permissions of the client that user still holds. Only a hash
+// of the secret is kept, the secret is handed out once when the client is
+// registered.
+type Client struct {
+	ID          uuid.UUID `json:"id"`
+	TenantID    uuid.UUID `json:"tenantID"`
+	UserID      uuid.UUID `json:"userID"`
+	Name        string    `json:"name"`
+	SecretHash  string    `json:"-"`
+	Permissions []string  `json:"permissions"`
+	DateCreated time.Time `json:"dateCreated"`
+	DateUpdated time.Time `json:"dateUpdated"`
+}
+
+// NewClient contains information needed to register a new Client. The tenant
+// isn't provided by the client, it's set from the user the client acts for.
+type NewClient struct {
+	TenantID    uuid.UUID `json:"-"`
+	UserID      uuid.UUID `json:"userID" validate:"required"`
+	Name        string    `json:"name" validate:"required"`
+	Permissions []string  `json:"permissions" validate:"required,min=1,dive,required"`
+}
+
+// Created is what is handed back when a client is registered. It's the only
+// time the secret is available.
+type Created struct {
+	Client
+	Secret string `json:"clientSecret"`
+}
@@ -0,0 +1,159 @@
+// Package oauthclient provides the core business API for the services that
+// are registered to request tokens with the OAuth2 client credentials grant.
+package oauthclient
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/google/uuid"
+)
+
+// Set of error variables for client operations.
+var (
+	ErrNotFound              = errors.New("client not found")
+	ErrAuthenticationFailure = errors.New("client authentication failed")
+	ErrNotGrantable          = errors.New("client carries permissions that aren't held")
+)
+
+// Storer interface declares the behavior this package needs to perists and
+// retrieve data.
+type Storer interface {
+	Create(ctx context.Context, clt Client) error
+	Delete(ctx context.Context, clt Client) error
+	QueryByID(ctx context.Context, clientID uuid.UUID) (Client, error)
+}
+
+// Core manages the set of APIs for client access.
+type Core struct {
+	storer Storer
+}
+
+// NewCore constructs a core for client api access.
+func NewCore(storer Storer) *Core {
+	return &Core{
+		storer: storer,
+	}
+}
+
+// Create registers a new client. The client can only carry the held
+// permissions. The secret is returned along with the client and can't be
+// retrieved again.
+func (c *Core) Create(ctx context.Context, nc NewClient, held []string) (Created, error) {
+	if err := validate.Check(nc); err != nil {
+		return Created{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	heldSet := make(map[string]bool, len(held))
+	for _, p := range held {
+		heldSet[p] = true
+	}
+
+	for _, p := range nc.Permissions {
+		if !heldSet[p] {
+			return Created{}, fmt.Errorf("permission[%s]: %w", p, ErrNotGrantable)
+		}
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return Created{}, fmt.Errorf("generating secret: %w", err)
+	}
+	secret := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+
+	clt := Client{
+		ID:          uuid.New(),
+		TenantID:    nc.TenantID,
+		UserID:      nc.UserID,
+		Name:        nc.Name,
+		SecretHash:  hash(secret),
+		Permissions: uniqueSorted(nc.Permissions),
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	if err := c.storer.Create(ctx, clt); err != nil {
+		return Created{}, fmt.Errorf("create: %w", err)
+	}
+
+	return Created{Client: clt, Secret: secret}, nil
+}
+
+// Delete removes the client. Revoking the tokens already issued to the client
+// is up to the caller.
+func (c *Core) Delete(ctx context.Context, clientID uuid.UUID) error {
+	clt, err := c.storer.QueryByID(ctx, clientID)
+	if err != nil {
+		return fmt.Errorf("query: clientID[%s]: %w", clientID, err)
+	}
+
+	if err := c.storer.Delete(ctx, clt); err != nil {
+		return fmt.Errorf("delete: clientID[%s]: %w", clientID, err)
+	}
+
+	return nil
+}
+
+// QueryByID gets the specified client from the database.
+func (c *Core) QueryByID(ctx context.Context, clientID uuid.UUID) (Client, error) {
+	clt, err := c.storer.QueryByID(ctx, clientID)
+	if err != nil {
+		return Client{}, fmt.Errorf("query: clientID[%s]: %w", clientID, err)
+	}
+
+	return clt, nil
+}
+
+// Authenticate returns the client with the specified id if the secret
+// matches. A client that doesn't exist fails the same way as a wrong secret.
+func (c *Core) Authenticate(ctx context.Context, clientID uuid.UUID, secret string) (Client, error) {
+	clt, err := c.storer.QueryByID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return Client{}, ErrAuthenticationFailure
+		}
+		return Client{}, fmt.Errorf("query: clientID[%s]: %w", clientID, err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(clt.SecretHash), []byte(hash(secret))) != 1 {
+		return Client{}, ErrAuthenticationFailure
+	}
+
+	return clt, nil
+}
+
+// =============================================================================
+
+// hash returns the value stored for a secret. The secrets are random with
+// enough entropy that a plain sha256 is sufficient.
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// uniqueSorted returns the sorted set of the values without duplicates.
+func uniqueSorted(values []string) []string {
+	set := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+
+	for _, v := range values {
+		if !set[v] {
+			set[v] = true
+			unique = append(unique, v)
+		}
+	}
+	sort.Strings(unique)
+
+	return unique
+}
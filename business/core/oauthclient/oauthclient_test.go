@@ -0,0 +1,110 @@
+package oauthclient_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"testing"
+
+	"github.com/ardanlabs/service/business/core/oauthclient"
+	"github.com/ardanlabs/service/business/core/oauthclient/stores/oauthclientdb"
+	"github.com/ardanlabs/service/business/data/dbtest"
+	"github.com/ardanlabs/service/business/sys/tenant"
+	"github.com/ardanlabs/service/foundation/docker"
+	"github.com/google/uuid"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func Test_Client(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testoauthclient")
+	defer func() {
+		if r := recover(); r != nil {
+			t.Log(r)
+			t.Error(string(debug.Stack()))
+		}
+		teardown()
+	}()
+
+	core := oauthclient.NewCore(oauthclientdb.NewStore(log, db))
+
+	t.Log("Given the need to register clients for the client credentials grant.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single client.", testID)
+		{
+			ctx := tenant.Set(context.Background(), tenant.Default)
+
+			nc := oauthclient.NewClient{
+				TenantID:    tenant.Default,
+				UserID:      uuid.MustParse("5cf37266-3473-4006-984f-9325122678b7"),
+				Name:        "billing service",
+				Permissions: []string{"users:read", "reports:read"},
+			}
+
+			if _, err := core.Create(ctx, nc, []string{"users:read"}); !errors.Is(err, oauthclient.ErrNotGrantable) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to register a client with permissions that aren't held : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to register a client with permissions that aren't held.", dbtest.Success, testID)
+
+			created, err := core.Create(ctx, nc, []string{"users:read", "users:write", "reports:read"})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to register a client : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to register a client.", dbtest.Success, testID)
+
+			clt, err := core.Authenticate(ctx, created.ID, created.Secret)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to authenticate the client : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to authenticate the client.", dbtest.Success, testID)
+
+			if clt.UserID != nc.UserID || len(clt.Permissions) != 2 {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, clt)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, created.Client)
+				t.Fatalf("\t%s\tTest %d:\tShould get back the same client.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the same client.", dbtest.Success, testID)
+
+			if _, err := core.Authenticate(ctx, created.ID, "wrong"); !errors.Is(err, oauthclient.ErrAuthenticationFailure) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to authenticate with the wrong secret : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to authenticate with the wrong secret.", dbtest.Success, testID)
+
+			other := tenant.Set(context.Background(), uuid.New())
+
+			if _, err := core.QueryByID(other, created.ID); !errors.Is(err, oauthclient.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to retrieve the client from another tenant : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to retrieve the client from another tenant.", dbtest.Success, testID)
+
+			if err := core.Delete(other, created.ID); !errors.Is(err, oauthclient.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to delete the client from another tenant : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to delete the client from another tenant.", dbtest.Success, testID)
+
+			if err := core.Delete(ctx, created.ID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete the client : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete the client.", dbtest.Success, testID)
+
+			if _, err := core.Authenticate(ctx, created.ID, created.Secret); !errors.Is(err, oauthclient.ErrAuthenticationFailure) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to authenticate a deleted client : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to authenticate a deleted client.", dbtest.Success, testID)
+		}
+	}
+}
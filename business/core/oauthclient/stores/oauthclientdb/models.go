@@ -0,0 +1,48 @@
+package oauthclientdb
+
+import (
+	"time"
+
+	"github.com/ardanlabs/service/business/core/oauthclient"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// dbClient represent the structure we need for moving data
+// between the app and the database.
+type dbClient struct {
+	ID          uuid.UUID      `db:"client_id"`
+	TenantID    uuid.UUID      `db:"tenant_id"`
+	UserID      uuid.UUID      `db:"user_id"`
+	Name        string         `db:"name"`
+	SecretHash  string         `db:"secret_hash"`
+	Permissions pq.StringArray `db:"permissions"`
+	DateCreated time.Time      `db:"date_created"`
+	DateUpdated time.Time      `db:"date_updated"`
+}
+
+func toDBClient(clt oauthclient.Client) dbClient {
+	return dbClient{
+		ID:          clt.ID,
+		TenantID:    clt.TenantID,
+		UserID:      clt.UserID,
+		Name:        clt.Name,
+		SecretHash:  clt.SecretHash,
+		Permissions: clt.Permissions,
+		DateCreated: clt.DateCreated.UTC(),
+		DateUpdated: clt.DateUpdated.UTC(),
+	}
+}
+
+func toCoreClient(dbClt dbClient) oauthclient.Client {
+	return oauthclient.Client{
+		ID:          dbClt.ID,
+		TenantID:    dbClt.TenantID,
+		UserID:      dbClt.UserID,
+		Name:        dbClt.Name,
+		SecretHash:  dbClt.SecretHash,
+		Permissions: dbClt.Permissions,
+		DateCreated: dbClt.DateCreated.In(time.Local),
+		DateUpdated: dbClt.DateUpdated.In(time.Local),
+	}
+}
@@ -0,0 +1,100 @@
+// Package oauthclientdb contains OAuth2 client related CRUD functionality.
+package oauthclientdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ardanlabs/service/business/core/oauthclient"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for client database access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs the api for data access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) *Store {
+	return &Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Create inserts a new client into the database.
+func (s *Store) Create(ctx context.Context, clt oauthclient.Client) error {
+	const q = `
+	INSERT INTO oauth_clients
+		(client_id, tenant_id, user_id, name, secret_hash, permissions, date_created, date_updated)
+	VALUES
+		(:client_id, :tenant_id, :user_id, :name, :secret_hash, :permissions, :date_created, :date_updated)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, toDBClient(clt)); err != nil {
+		return fmt.Errorf("inserting client: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a client from the database.
+func (s *Store) Delete(ctx context.Context, clt oauthclient.Client) error {
+	data := map[string]any{
+		"client_id": clt.ID.String(),
+	}
+
+	const q = `
+	DELETE FROM
+		oauth_clients`
+
+	wc, err := tenantClauses(ctx, data)
+	if err != nil {
+		return err
+	}
+
+	buf := bytes.NewBufferString(q)
+	writeWhere(buf, append(wc, "client_id = :client_id"))
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, buf.String(), data); err != nil {
+		return fmt.Errorf("deleting clientID[%s]: %w", clt.ID, err)
+	}
+
+	return nil
+}
+
+// QueryByID gets the specified client from the database.
+func (s *Store) QueryByID(ctx context.Context, clientID uuid.UUID) (oauthclient.Client, error) {
+	data := map[string]any{
+		"client_id": clientID.String(),
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		oauth_clients`
+
+	wc, err := tenantClauses(ctx, data)
+	if err != nil {
+		return oauthclient.Client{}, err
+	}
+
+	buf := bytes.NewBufferString(q)
+	writeWhere(buf, append(wc, "client_id = :client_id"))
+
+	var clt dbClient
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, buf.String(), data, &clt); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return oauthclient.Client{}, oauthclient.ErrNotFound
+		}
+		return oauthclient.Client{}, fmt.Errorf("selecting clientID[%q]: %w", clientID, err)
+	}
+
+	return toCoreClient(clt), nil
+}
@@ -0,0 +1,41 @@
+package product
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Product represents an individual product.
+type Product struct {
+	ID          uuid.UUID `json:"id"`
+	TenantID    uuid.UUID `json:"tenantID"`
+	Name        string    `json:"name"`
+	Cost        int       `json:"cost"`
+	Quantity    int       `json:"quantity"`
+	UserID      uuid.UUID `json:"userID"`
+	DateCreated time.Time `json:"dateCreated"`
+	DateUpdated time.Time `json:"dateUpdated"`
+}
+
+// NewProduct is what we require from clients when adding a Product. The
+// UserID is not provided by the client, it is set from the claims of the
+// authenticated user that is creating the product.
+type NewProduct struct {
+	Name     string    `json:"name" validate:"required"`
+	Cost     int       `json:"cost" validate:"gte=0"`
+	Quantity int       `json:"quantity" validate:"gte=0"`
+	UserID   uuid.UUID `json:"-"`
+}
+
+// UpdateProduct defines what information may be provided to modify an
+// existing Product. All fields are optional so clients can send just the
+// fields they want changed. It uses pointer fields so we can differentiate
+// between a field that was not provided and a field that was provided as
+// explicitly blank. Normally we do not want to use pointers to basic types but
+// we make exceptions around marshalling/unmarshalling.
+type UpdateProduct struct {
+	Name     *string `json:"name"`
+	Cost     *int    `json:"cost" validate:"omitempty,gte=0"`
+	Quantity *int    `json:"quantity" validate:"omitempty,gte=0"`
+}
@@ -0,0 +1,182 @@
+// Package product provides an example of a core business API. Right now these
+// calls are just wrapping the data/store layer. But at some point you will
+// want auditing or something that isn't specific to the data/store layer.
+package product
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/google/uuid"
+)
+
+// Set of error variables for CRUD operations.
+var (
+	ErrNotFound     = errors.New("product not found")
+	ErrInvalidOwner = errors.New("product owner is not valid")
+)
+
+// Storer interface declares the behavior this package needs to perists and
+// retrieve data.
+type Storer interface {
+	WithinTran(ctx context.Context, fn func(s Storer) error) error
+	Create(ctx context.Context, prd Product) error
+	Update(ctx context.Context, prd Product) error
+	Delete(ctx context.Context, prd Product) error
+	Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]Product, error)
+	Count(ctx context.Context) (int, error)
+	QueryByID(ctx context.Context, productID uuid.UUID) (Product, error)
+	QueryByIDForUpdate(ctx context.Context, productID uuid.UUID) (Product, error)
+	QueryByUserID(ctx context.Context, userID uuid.UUID) ([]Product, error)
+}
+
+// Core manages the set of APIs for product access.
+type Core struct {
+	storer Storer
+}
+
+// NewCore constructs a core for product api access.
+func NewCore(storer Storer) *Core {
+	return &Core{
+		storer: storer,
+	}
+}
+
+// Create adds a Product to the database. It returns the created Product with
+// fields like ID and DateCreated populated.
+func (c *Core) Create(ctx context.Context, np NewProduct) (Product, error) {
+	if err := validate.Check(np); err != nil {
+		return Product{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	if np.UserID == uuid.Nil {
+		return Product{}, ErrInvalidOwner
+	}
+
+	now := time.Now()
+
+	prd := Product{
+		ID:          uuid.New(),
+		Name:        np.Name,
+		Cost:        np.Cost,
+		Quantity:    np.Quantity,
+		UserID:      np.UserID,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	// The product belongs to the tenant of its owner, which the store sets,
+	// so the product is read back to return the tenant it was stored with.
+	tran := func(s Storer) error {
+		if err := s.Create(ctx, prd); err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+
+		saved, err := s.QueryByID(ctx, prd.ID)
+		if err != nil {
+			return fmt.Errorf("query: productID[%s]: %w", prd.ID, err)
+		}
+		prd = saved
+		return nil
+	}
+
+	if err := c.storer.WithinTran(ctx, tran); err != nil {
+		return Product{}, fmt.Errorf("tran: %w", err)
+	}
+
+	return prd, nil
+}
+
+// Update modifies data about a Product. It will error if the specified ID is
+// invalid or does not reference an existing Product. The product row is
+// locked and read again for the life of the transaction, so fields that
+// aren't changed keep their current values, like a quantity a concurrent sale
+// just decremented.
+func (c *Core) Update(ctx context.Context, prd Product, up UpdateProduct) (Product, error) {
+	if err := validate.Check(up); err != nil {
+		return Product{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	productID := prd.ID
+
+	tran := func(s Storer) error {
+		var err error
+		if prd, err = s.QueryByIDForUpdate(ctx, productID); err != nil {
+			return fmt.Errorf("query: productID[%s]: %w", productID, err)
+		}
+
+		if up.Name != nil {
+			prd.Name = *up.Name
+		}
+		if up.Cost != nil {
+			prd.Cost = *up.Cost
+		}
+		if up.Quantity != nil {
+			prd.Quantity = *up.Quantity
+		}
+		prd.DateUpdated = time.Now()
+
+		if err := s.Update(ctx, prd); err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+		return nil
+	}
+
+	if err := c.storer.WithinTran(ctx, tran); err != nil {
+		return Product{}, fmt.Errorf("tran: %w", err)
+	}
+
+	return prd, nil
+}
+
+// Delete removes the product identified by a given ID.
+func (c *Core) Delete(ctx context.Context, prd Product) error {
+	if err := c.storer.Delete(ctx, prd); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	return nil
+}
+
+// Query gets all Products from the database.
+func (c *Core) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]Product, error) {
+	prds, err := c.storer.Query(ctx, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return prds, nil
+}
+
+// Count returns the total number of products.
+func (c *Core) Count(ctx context.Context) (int, error) {
+	count, err := c.storer.Count(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("count: %w", err)
+	}
+
+	return count, nil
+}
+
+// QueryByID finds the product identified by a given ID.
+func (c *Core) QueryByID(ctx context.Context, productID uuid.UUID) (Product, error) {
+	prd, err := c.storer.QueryByID(ctx, productID)
+	if err != nil {
+		return Product{}, fmt.Errorf("query: productID[%s]: %w", productID, err)
+	}
+
+	return prd, nil
+}
+
+// QueryByUserID finds the products owned by the specified user.
+func (c *Core) QueryByUserID(ctx context.Context, userID uuid.UUID) ([]Product, error) {
+	prds, err := c.storer.QueryByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query: userID[%s]: %w", userID, err)
+	}
+
+	return prds, nil
+}
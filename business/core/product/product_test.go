@@ -0,0 +1,162 @@
+package product_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/product"
+	"github.com/ardanlabs/service/business/core/product/stores/productdb"
+	"github.com/ardanlabs/service/business/data/dbtest"
+	"github.com/ardanlabs/service/business/sys/tenant"
+	"github.com/ardanlabs/service/foundation/docker"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/uuid"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func Test_Product(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testproduct")
+	defer func() {
+		if r := recover(); r != nil {
+			t.Log(r)
+			t.Error(string(debug.Stack()))
+		}
+		teardown()
+	}()
+
+	core := product.NewCore(productdb.NewStore(log, db))
+
+	t.Log("Given the need to work with Product records.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single Product.", testID)
+		{
+			ctx := tenant.Set(context.Background(), tenant.Default)
+
+			np := product.NewProduct{
+				Name:     "Comic Books",
+				Cost:     10,
+				Quantity: 55,
+				UserID:   uuid.MustParse("5cf37266-3473-4006-984f-9325122678b7"),
+			}
+
+			prd, err := core.Create(ctx, np)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a product : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a product.", dbtest.Success, testID)
+
+			if prd.TenantID != tenant.Default {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, prd.TenantID)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, tenant.Default)
+				t.Fatalf("\t%s\tTest %d:\tShould belong to the tenant of the owner.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould belong to the tenant of the owner.", dbtest.Success, testID)
+
+			saved, err := core.QueryByID(ctx, prd.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product by ID: %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve product by ID.", dbtest.Success, testID)
+
+			if prd.DateCreated.UnixMilli() != saved.DateCreated.UnixMilli() {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, saved.DateCreated)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, prd.DateCreated)
+				t.Fatalf("\t%s\tTest %d:\tShould get back the same date created.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the same date created.", dbtest.Success, testID)
+
+			prd.DateCreated = time.Time{}
+			prd.DateUpdated = time.Time{}
+			saved.DateCreated = time.Time{}
+			saved.DateUpdated = time.Time{}
+
+			if diff := cmp.Diff(prd, saved); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the same product. Diff:\n%s", dbtest.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the same product.", dbtest.Success, testID)
+
+			upd := product.UpdateProduct{
+				Name:     dbtest.StringPointer("Comics"),
+				Cost:     dbtest.IntPointer(50),
+				Quantity: dbtest.IntPointer(40),
+			}
+
+			if _, err := core.Update(ctx, saved, upd); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update product : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to update product.", dbtest.Success, testID)
+
+			saved, err = core.QueryByID(ctx, prd.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve updated product : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve updated product.", dbtest.Success, testID)
+
+			want := prd
+			want.Name = *upd.Name
+			want.Cost = *upd.Cost
+			want.Quantity = *upd.Quantity
+			saved.DateCreated = time.Time{}
+			saved.DateUpdated = time.Time{}
+
+			if diff := cmp.Diff(want, saved); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the same product. Diff:\n%s", dbtest.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the same product.", dbtest.Success, testID)
+
+			prds, err := core.QueryByUserID(ctx, np.UserID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve products by user : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve products by user.", dbtest.Success, testID)
+
+			if len(prds) != 1 {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, len(prds))
+				t.Logf("\t\tTest %d:\tExp: %v", testID, 1)
+				t.Fatalf("\t%s\tTest %d:\tShould have one product for the user.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould have one product for the user.", dbtest.Success, testID)
+
+			other := tenant.Set(context.Background(), uuid.New())
+
+			if _, err := core.QueryByID(other, prd.ID); !errors.Is(err, product.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve the product from another tenant : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve the product from another tenant.", dbtest.Success, testID)
+
+			if err := core.Delete(other, saved); !errors.Is(err, product.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to delete the product from another tenant : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to delete the product from another tenant.", dbtest.Success, testID)
+
+			if err := core.Delete(ctx, saved); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete product : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete product.", dbtest.Success, testID)
+
+			_, err = core.QueryByID(ctx, prd.ID)
+			if !errors.Is(err, product.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve deleted product : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve deleted product.", dbtest.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package productdb
+
+import (
+	"time"
+
+	"github.com/ardanlabs/service/business/core/product"
+	"github.com/google/uuid"
+)
+
+// dbProduct represents an individual product.
+type dbProduct struct {
+	ID          uuid.UUID `db:"product_id"`
+	TenantID    uuid.UUID `db:"tenant_id"`
+	Name        string    `db:"name"`
+	Cost        int       `db:"cost"`
+	Quantity    int       `db:"quantity"`
+	UserID      uuid.UUID `db:"user_id"`
+	DateCreated time.Time `db:"date_created"`
+	DateUpdated time.Time `db:"date_updated"`
+}
+
+func toDBProduct(prd product.Product) dbProduct {
+	return dbProduct{
+		ID:          prd.ID,
+		TenantID:    prd.TenantID,
+		Name:        prd.Name,
+		Cost:        prd.Cost,
+		Quantity:    prd.Quantity,
+		UserID:      prd.UserID,
+		DateCreated: prd.DateCreated.UTC(),
+		DateUpdated: prd.DateUpdated.UTC(),
+	}
+}
+
+func toCoreProduct(dbPrd dbProduct) product.Product {
+	prd := product.Product{
+		ID:          dbPrd.ID,
+		TenantID:    dbPrd.TenantID,
+		Name:        dbPrd.Name,
+		Cost:        dbPrd.Cost,
+		Quantity:    dbPrd.Quantity,
+		UserID:      dbPrd.UserID,
+		DateCreated: dbPrd.DateCreated.In(time.Local),
+		DateUpdated: dbPrd.DateUpdated.In(time.Local),
+	}
+
+	return prd
+}
+
+func toCoreProductSlice(dbProducts []dbProduct) []product.Product {
+	prds := make([]product.Product, len(dbProducts))
+	for i, dbPrd := range dbProducts {
+		prds[i] = toCoreProduct(dbPrd)
+	}
+	return prds
+}
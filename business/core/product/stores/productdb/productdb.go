@@ -0,0 +1,275 @@
+// Package productdb contains product related CRUD functionality.
+package productdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ardanlabs/service/business/core/product"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for product database access.
+type Store struct {
+	log    *zap.SugaredLogger
+	db     sqlx.ExtContext
+	inTran bool
+}
+
+// NewStore constructs the api for data access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) *Store {
+	return &Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// WithinTran runs passed function and do commit/rollback at the end.
+func (s *Store) WithinTran(ctx context.Context, fn func(s product.Storer) error) error {
+	if s.inTran {
+		return fn(s)
+	}
+
+	f := func(tx *sqlx.Tx) error {
+		s := &Store{
+			log:    s.log,
+			db:     tx,
+			inTran: true,
+		}
+		return fn(s)
+	}
+
+	return database.WithinTran(ctx, s.log, s.db.(*sqlx.DB), f)
+}
+
+// Create adds a Product to the database. The product belongs to the tenant
+// of the user that owns it.
+func (s *Store) Create(ctx context.Context, prd product.Product) error {
+	const q = `
+	INSERT INTO products
+		(product_id, tenant_id, user_id, name, cost, quantity, date_created, date_updated)
+	VALUES
+		(:product_id, (SELECT tenant_id FROM users WHERE user_id = :user_id), :user_id, :name, :cost, :quantity, :date_created, :date_updated)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, toDBProduct(prd)); err != nil {
+		return fmt.Errorf("inserting product: %w", err)
+	}
+
+	return nil
+}
+
+// Update modifies data about a Product. The product must belong to a tenant
+// in scope.
+func (s *Store) Update(ctx context.Context, prd product.Product) error {
+	if err := inScope(ctx, prd.TenantID); err != nil {
+		return fmt.Errorf("updating productID[%s]: %w", prd.ID, err)
+	}
+
+	const q = `
+	UPDATE
+		products
+	SET
+		"name" = :name,
+		"cost" = :cost,
+		"quantity" = :quantity,
+		"date_updated" = :date_updated
+	WHERE
+		product_id = :product_id AND
+		tenant_id = :tenant_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, toDBProduct(prd)); err != nil {
+		return fmt.Errorf("updating productID[%s]: %w", prd.ID, err)
+	}
+
+	return nil
+}
+
+// Delete removes the product identified by a given ID. The product must
+// belong to a tenant in scope.
+func (s *Store) Delete(ctx context.Context, prd product.Product) error {
+	if err := inScope(ctx, prd.TenantID); err != nil {
+		return fmt.Errorf("deleting productID[%s]: %w", prd.ID, err)
+	}
+
+	data := struct {
+		ID       string `db:"product_id"`
+		TenantID string `db:"tenant_id"`
+	}{
+		ID:       prd.ID.String(),
+		TenantID: prd.TenantID.String(),
+	}
+
+	const q = `
+	DELETE FROM
+		products
+	WHERE
+		product_id = :product_id AND
+		tenant_id = :tenant_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting productID[%s]: %w", prd.ID, err)
+	}
+
+	return nil
+}
+
+// Query gets all Products in scope from the database.
+func (s *Store) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]product.Product, error) {
+	data := map[string]any{
+		"offset":        (pageNumber - 1) * rowsPerPage,
+		"rows_per_page": rowsPerPage,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		products`
+
+	wc, err := tenantClauses(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBufferString(q)
+	writeWhere(buf, wc)
+	buf.WriteString(" ORDER BY product_id")
+	buf.WriteString(" OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY")
+
+	var prds []dbProduct
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, buf.String(), data, &prds); err != nil {
+		return nil, fmt.Errorf("selecting products: %w", err)
+	}
+
+	return toCoreProductSlice(prds), nil
+}
+
+// Count returns the total number of products in scope.
+func (s *Store) Count(ctx context.Context) (int, error) {
+	data := map[string]any{}
+
+	const q = `
+	SELECT
+		count(1)
+	FROM
+		products`
+
+	wc, err := tenantClauses(ctx, data)
+	if err != nil {
+		return 0, err
+	}
+
+	buf := bytes.NewBufferString(q)
+	writeWhere(buf, wc)
+
+	var count struct {
+		Count int `db:"count"`
+	}
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, buf.String(), data, &count); err != nil {
+		return 0, fmt.Errorf("selecting products count: %w", err)
+	}
+
+	return count.Count, nil
+}
+
+// QueryByID finds the product identified by a given ID.
+func (s *Store) QueryByID(ctx context.Context, productID uuid.UUID) (product.Product, error) {
+	data := map[string]any{
+		"product_id": productID.String(),
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		products`
+
+	wc, err := tenantClauses(ctx, data)
+	if err != nil {
+		return product.Product{}, err
+	}
+
+	buf := bytes.NewBufferString(q)
+	writeWhere(buf, append(wc, "product_id = :product_id"))
+
+	var prd dbProduct
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, buf.String(), data, &prd); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return product.Product{}, product.ErrNotFound
+		}
+		return product.Product{}, fmt.Errorf("selecting productID[%q]: %w", productID, err)
+	}
+
+	return toCoreProduct(prd), nil
+}
+
+// QueryByIDForUpdate finds the product identified by a given ID and locks the
+// row until the transaction completes. This must be called inside of a
+// transaction.
+func (s *Store) QueryByIDForUpdate(ctx context.Context, productID uuid.UUID) (product.Product, error) {
+	if !s.inTran {
+		return product.Product{}, errors.New("query by id for update must be called within a transaction")
+	}
+
+	data := map[string]any{
+		"product_id": productID.String(),
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		products`
+
+	wc, err := tenantClauses(ctx, data)
+	if err != nil {
+		return product.Product{}, err
+	}
+
+	buf := bytes.NewBufferString(q)
+	writeWhere(buf, append(wc, "product_id = :product_id"))
+	buf.WriteString(" FOR UPDATE")
+
+	var prd dbProduct
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, buf.String(), data, &prd); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return product.Product{}, product.ErrNotFound
+		}
+		return product.Product{}, fmt.Errorf("selecting productID[%q]: %w", productID, err)
+	}
+
+	return toCoreProduct(prd), nil
+}
+
+// QueryByUserID finds the products owned by the specified user.
+func (s *Store) QueryByUserID(ctx context.Context, userID uuid.UUID) ([]product.Product, error) {
+	data := map[string]any{
+		"user_id": userID.String(),
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		products`
+
+	wc, err := tenantClauses(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBufferString(q)
+	writeWhere(buf, append(wc, "user_id = :user_id"))
+
+	var prds []dbProduct
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, buf.String(), data, &prds); err != nil {
+		return nil, fmt.Errorf("selecting products userID[%s]: %w", userID, err)
+	}
+
+	return toCoreProductSlice(prds), nil
+}
@@ -0,0 +1,44 @@
+package report
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Set of time buckets a sales report can be broken into.
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// Set of dimensions a sales report can be grouped by.
+const (
+	GroupByProduct = "product"
+	GroupByUser    = "user"
+)
+
+// SalesFilter defines the range and shape of a sales report. The range is
+// inclusive of the StartDate and exclusive of the EndDate. Bucket and GroupBy
+// are optional and can be combined, leaving both empty produces a single
+// summary for the whole range.
+type SalesFilter struct {
+	StartDate time.Time `json:"startDate" validate:"required"`
+	EndDate   time.Time `json:"endDate" validate:"required,gtfield=StartDate"`
+	Bucket    string    `json:"bucket" validate:"omitempty,oneof=day week month"`
+	GroupBy   string    `json:"groupBy" validate:"omitempty,oneof=product user"`
+}
+
+// SalesSummary represents the aggregated sales for a single row of a report.
+// Bucket, ProductID and UserID are only set when the report was broken down
+// by that dimension.
+type SalesSummary struct {
+	Bucket      *time.Time `json:"bucket,omitempty"`
+	ProductID   *uuid.UUID `json:"productID,omitempty"`
+	UserID      *uuid.UUID `json:"userID,omitempty"`
+	Sales       int        `json:"sales"`
+	Units       int        `json:"units"`
+	Revenue     int        `json:"revenue"`
+	AverageSale float64    `json:"averageSale"`
+}
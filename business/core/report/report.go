@@ -0,0 +1,42 @@
+// Package report provides the core business API for reporting on sales.
+package report
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ardanlabs/service/business/sys/validate"
+)
+
+// Storer interface declares the behavior this package needs to retrieve
+// aggregated data.
+type Storer interface {
+	QuerySales(ctx context.Context, filter SalesFilter) ([]SalesSummary, error)
+}
+
+// Core manages the set of APIs for report access.
+type Core struct {
+	storer Storer
+}
+
+// NewCore constructs a core for report api access.
+func NewCore(storer Storer) *Core {
+	return &Core{
+		storer: storer,
+	}
+}
+
+// QuerySales calculates revenue, units sold and the average sale value for
+// the sales recorded within the filter's date range.
+func (c *Core) QuerySales(ctx context.Context, filter SalesFilter) ([]SalesSummary, error) {
+	if err := validate.Check(filter); err != nil {
+		return nil, fmt.Errorf("validating filter: %w", err)
+	}
+
+	sums, err := c.storer.QuerySales(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return sums, nil
+}
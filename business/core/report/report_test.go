@@ -0,0 +1,149 @@
+package report_test
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/report"
+	"github.com/ardanlabs/service/business/core/report/stores/reportdb"
+	"github.com/ardanlabs/service/business/data/dbtest"
+	"github.com/ardanlabs/service/business/sys/tenant"
+	"github.com/ardanlabs/service/foundation/docker"
+	"github.com/google/uuid"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func Test_SalesReport(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testreport")
+	defer func() {
+		if r := recover(); r != nil {
+			t.Log(r)
+			t.Error(string(debug.Stack()))
+		}
+		teardown()
+	}()
+
+	core := report.NewCore(reportdb.NewStore(log, db))
+
+	// The seed data records 3 sales on 2019-01-01 for a total of 10 units
+	// and 575 in revenue across 2 products.
+	start := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+
+	t.Log("Given the need to report on Sales.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen summarizing the whole range.", testID)
+		{
+			ctx := tenant.Set(context.Background(), tenant.Default)
+
+			filter := report.SalesFilter{
+				StartDate: start,
+				EndDate:   end,
+			}
+
+			sums, err := core.QuerySales(ctx, filter)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query the report : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to query the report.", dbtest.Success, testID)
+
+			if len(sums) != 1 || sums[0].Sales != 3 || sums[0].Units != 10 || sums[0].Revenue != 575 {
+				t.Logf("\t\tTest %d:\tGot: %+v", testID, sums)
+				t.Logf("\t\tTest %d:\tExp: sales[3] units[10] revenue[575]", testID)
+				t.Fatalf("\t%s\tTest %d:\tShould have the totals for the range.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould have the totals for the range.", dbtest.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen bucketing by month and grouping by product.", testID)
+		{
+			ctx := tenant.Set(context.Background(), tenant.Default)
+
+			filter := report.SalesFilter{
+				StartDate: start,
+				EndDate:   end,
+				Bucket:    report.BucketMonth,
+				GroupBy:   report.GroupByProduct,
+			}
+
+			sums, err := core.QuerySales(ctx, filter)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query the report : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to query the report.", dbtest.Success, testID)
+
+			if len(sums) != 2 {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, len(sums))
+				t.Logf("\t\tTest %d:\tExp: %v", testID, 2)
+				t.Fatalf("\t%s\tTest %d:\tShould have a row per product.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould have a row per product.", dbtest.Success, testID)
+
+			for _, sum := range sums {
+				if sum.Bucket == nil || sum.ProductID == nil || sum.UserID != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould only set the requested dimensions : %+v.", dbtest.Failed, testID, sum)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould only set the requested dimensions.", dbtest.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen using an unsupported bucket.", testID)
+		{
+			ctx := tenant.Set(context.Background(), tenant.Default)
+
+			filter := report.SalesFilter{
+				StartDate: start,
+				EndDate:   end,
+				Bucket:    "year",
+			}
+
+			if _, err := core.QuerySales(ctx, filter); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to query the report.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to query the report.", dbtest.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen summarizing for another tenant.", testID)
+		{
+			ctx := tenant.Set(context.Background(), uuid.New())
+
+			filter := report.SalesFilter{
+				StartDate: start,
+				EndDate:   end,
+			}
+
+			sums, err := core.QuerySales(ctx, filter)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query the report : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to query the report.", dbtest.Success, testID)
+
+			if len(sums) != 1 || sums[0].Sales != 0 {
+				t.Logf("\t\tTest %d:\tGot: %+v", testID, sums)
+				t.Logf("\t\tTest %d:\tExp: sales[0]", testID)
+				t.Fatalf("\t%s\tTest %d:\tShould not count another tenant's sales.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not count another tenant's sales.", dbtest.Success, testID)
+		}
+	}
+}
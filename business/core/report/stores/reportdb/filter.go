@@ -0,0 +1,25 @@
+package reportdb
+
+import (
+	"context"
+
+	"github.com/ardanlabs/service/business/sys/tenant"
+)
+
+// tenantClauses returns the predicate that limits a query to the tenant in
+// scope, there is none when every tenant is in scope. The value is added to
+// data so it's bound as a parameter.
+func tenantClauses(ctx context.Context, data map[string]any) ([]string, error) {
+	scope, err := tenant.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if scope.All {
+		return nil, nil
+	}
+
+	data["tenant_id"] = scope.ID
+
+	return []string{"tenant_id = :tenant_id"}, nil
+}
@@ -0,0 +1,51 @@
+package reportdb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/report"
+	"github.com/google/uuid"
+)
+
+// dbSalesSummary represent the structure we need for moving aggregated
+// sales data between the app and the database.
+type dbSalesSummary struct {
+	Bucket      sql.NullTime  `db:"bucket"`
+	ProductID   uuid.NullUUID `db:"product_id"`
+	UserID      uuid.NullUUID `db:"user_id"`
+	Sales       int           `db:"sales"`
+	Units       int           `db:"units"`
+	Revenue     int           `db:"revenue"`
+	AverageSale float64       `db:"average_sale"`
+}
+
+func toCoreSalesSummary(dbSum dbSalesSummary) report.SalesSummary {
+	sum := report.SalesSummary{
+		Sales:       dbSum.Sales,
+		Units:       dbSum.Units,
+		Revenue:     dbSum.Revenue,
+		AverageSale: dbSum.AverageSale,
+	}
+
+	if dbSum.Bucket.Valid {
+		bucket := dbSum.Bucket.Time.In(time.Local)
+		sum.Bucket = &bucket
+	}
+	if dbSum.ProductID.Valid {
+		sum.ProductID = &dbSum.ProductID.UUID
+	}
+	if dbSum.UserID.Valid {
+		sum.UserID = &dbSum.UserID.UUID
+	}
+
+	return sum
+}
+
+func toCoreSalesSummarySlice(dbSums []dbSalesSummary) []report.SalesSummary {
+	sums := make([]report.SalesSummary, len(dbSums))
+	for i, dbSum := range dbSums {
+		sums[i] = toCoreSalesSummary(dbSum)
+	}
+	return sums
+}
@@ -0,0 +1,103 @@
+// Package reportdb contains the aggregate queries for reporting.
+package reportdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ardanlabs/service/business/core/report"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// bucketFields maps the supported buckets to the date_trunc field used to
+// build them. Only values from this map are ever written into the query.
+var bucketFields = map[string]string{
+	report.BucketDay:   "day",
+	report.BucketWeek:  "week",
+	report.BucketMonth: "month",
+}
+
+// Store manages the set of APIs for report database access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs the api for data access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) *Store {
+	return &Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// QuerySales aggregates the sales recorded within the filter's date range.
+func (s *Store) QuerySales(ctx context.Context, filter report.SalesFilter) ([]report.SalesSummary, error) {
+	data := map[string]any{
+		"start_date": filter.StartDate.UTC(),
+		"end_date":   filter.EndDate.UTC(),
+	}
+
+	wc, err := tenantClauses(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	wc = append([]string{"date_created >= :start_date", "date_created < :end_date"}, wc...)
+
+	// Every dimension is always selected so each row scans the same way. A
+	// dimension that is not part of the report is selected as NULL. Casts use
+	// CAST since sqlx treats :: in a named query as an escaped colon.
+	bucket, productID, userID := "CAST(NULL AS TIMESTAMP)", "CAST(NULL AS UUID)", "CAST(NULL AS UUID)"
+	var groupBy []string
+
+	if filter.Bucket != "" {
+		field, exists := bucketFields[filter.Bucket]
+		if !exists {
+			return nil, fmt.Errorf("bucket %q is not supported", filter.Bucket)
+		}
+		bucket = fmt.Sprintf("date_trunc('%s', date_created)", field)
+		groupBy = append(groupBy, "bucket")
+	}
+
+	switch filter.GroupBy {
+	case "":
+	case report.GroupByProduct:
+		productID = "product_id"
+		groupBy = append(groupBy, "product_id")
+	case report.GroupByUser:
+		userID = "user_id"
+		groupBy = append(groupBy, "user_id")
+	default:
+		return nil, fmt.Errorf("group by %q is not supported", filter.GroupBy)
+	}
+
+	buf := bytes.NewBufferString("SELECT ")
+	fmt.Fprintf(buf, "%s AS bucket, %s AS product_id, %s AS user_id,", bucket, productID, userID)
+
+	const q = `
+		COUNT(*) AS sales,
+		COALESCE(SUM(quantity), 0) AS units,
+		COALESCE(SUM(paid), 0) AS revenue,
+		CAST(COALESCE(AVG(paid), 0) AS FLOAT8) AS average_sale
+	FROM
+		sales
+	WHERE `
+	buf.WriteString(q)
+	buf.WriteString(strings.Join(wc, " AND "))
+
+	if len(groupBy) > 0 {
+		list := strings.Join(groupBy, ", ")
+		fmt.Fprintf(buf, " GROUP BY %s ORDER BY %s", list, list)
+	}
+
+	var sums []dbSalesSummary
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, buf.String(), data, &sums); err != nil {
+		return nil, fmt.Errorf("selecting sales report: %w", err)
+	}
+
+	return toCoreSalesSummarySlice(sums), nil
+}
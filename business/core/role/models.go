@@ -0,0 +1,62 @@
+package role
+
+import (
+	"time"
+)
+
+// Set of permissions the service checks. Roles are managed in the database
+// and are a named set of these permissions, so new roles don't require a
+// change to the code.
+const (
+	PermUsersRead        = "users:read"
+	PermUsersWrite       = "users:write"
+	PermUsersImpersonate = "users:impersonate"
+	PermProductsWrite    = "products:write"
+	PermReportsRead      = "reports:read"
+	PermRolesRead        = "roles:read"
+	PermRolesWrite       = "roles:write"
+	PermAuditRead        = "audit:read"
+)
+
+// Set of roles created by the migrations. A super admin is a platform
+// operator that can access every tenant, the role is reserved and can't be
+// granted, changed or removed through the API.
+const (
+	SuperAdmin = "SUPER_ADMIN"
+	Admin      = "ADMIN"
+	User       = "USER"
+)
+
+// Role represents a named set of permissions that can be granted to users.
+type Role struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	DateCreated time.Time `json:"dateCreated"`
+	DateUpdated time.Time `json:"dateUpdated"`
+}
+
+// HasPermission reports whether the role carries the specified permission.
+func (r Role) HasPermission(permission string) bool {
+	for _, p := range r.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Permission represents an action a role can be allowed to perform.
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// NewRole contains information needed to create a new Role. The name is made
+// of upper case letters, digits and underscores, like SUPPORT or AUDITOR.
+type NewRole struct {
+	Name        string   `json:"name" validate:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" validate:"dive,required"`
+}
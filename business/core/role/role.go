@@ -0,0 +1,320 @@
+// Package role provides the core business API for managing the roles that
+// are granted to users and the permissions they carry.
+package role
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/validate"
+)
+
+// Set of error variables for CRUD operations.
+var (
+	ErrNotFound          = errors.New("role not found")
+	ErrInvalidName       = errors.New("role name is not valid")
+	ErrUniqueName        = errors.New("role name is not unique")
+	ErrReserved          = errors.New("role is reserved")
+	ErrInUse             = errors.New("role is granted to users")
+	ErrUnknownPermission = errors.New("permission not found")
+	ErrNotGrantable      = errors.New("role carries permissions the caller doesn't have")
+)
+
+// validName matches the names a role can be created with. Role names are
+// stored in lists, so separators aren't allowed.
+var validName = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// Storer interface declares the behavior this package needs to perists and
+// retrieve data.
+type Storer interface {
+	WithinTran(ctx context.Context, fn func(s Storer) error) error
+	Create(ctx context.Context, rol Role) error
+	Update(ctx context.Context, rol Role) error
+	Delete(ctx context.Context, rol Role) error
+	Query(ctx context.Context) ([]Role, error)
+	QueryByName(ctx context.Context, name string) (Role, error)
+	QueryByNames(ctx context.Context, names []string) ([]Role, error)
+	QueryPermissions(ctx context.Context) ([]Permission, error)
+	CountUsers(ctx context.Context, name string) (int, error)
+}
+
+// Core manages the set of APIs for role access.
+type Core struct {
+	storer Storer
+}
+
+// NewCore constructs a core for role api access.
+func NewCore(storer Storer) *Core {
+	return &Core{
+		storer: storer,
+	}
+}
+
+// Create adds a new role with the specified permissions.
+func (c *Core) Create(ctx context.Context, nr NewRole) (Role, error) {
+	if err := validate.Check(nr); err != nil {
+		return Role{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	if !validName.MatchString(nr.Name) {
+		return Role{}, fmt.Errorf("name[%s]: %w", nr.Name, ErrInvalidName)
+	}
+
+	now := time.Now()
+
+	rol := Role{
+		Name:        nr.Name,
+		Description: nr.Description,
+		Permissions: uniqueSorted(nr.Permissions),
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	tran := func(s Storer) error {
+		if err := checkPermissions(ctx, s, rol.Permissions); err != nil {
+			return err
+		}
+
+		if err := s.Create(ctx, rol); err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+		return nil
+	}
+
+	if err := c.storer.WithinTran(ctx, tran); err != nil {
+		return Role{}, fmt.Errorf("tran: %w", err)
+	}
+
+	return rol, nil
+}
+
+// AddPermission attaches the permission to the role. Adding a permission the
+// role already carries is not an error.
+func (c *Core) AddPermission(ctx context.Context, name string, permission string) (Role, error) {
+	f := func(rol Role) Role {
+		if !rol.HasPermission(permission) {
+			rol.Permissions = uniqueSorted(append(rol.Permissions, permission))
+		}
+		return rol
+	}
+
+	return c.change(ctx, name, []string{permission}, f)
+}
+
+// RemovePermission detaches the permission from the role. Removing a
+// permission the role doesn't carry is not an error.
+func (c *Core) RemovePermission(ctx context.Context, name string, permission string) (Role, error) {
+	f := func(rol Role) Role {
+		perms := make([]string, 0, len(rol.Permissions))
+		for _, p := range rol.Permissions {
+			if p != permission {
+				perms = append(perms, p)
+			}
+		}
+		rol.Permissions = perms
+		return rol
+	}
+
+	return c.change(ctx, name, nil, f)
+}
+
+// Delete removes a role. A role that is granted to users can't be removed.
+func (c *Core) Delete(ctx context.Context, name string) error {
+	if name == SuperAdmin {
+		return ErrReserved
+	}
+
+	tran := func(s Storer) error {
+		rol, err := s.QueryByName(ctx, name)
+		if err != nil {
+			return fmt.Errorf("query: name[%s]: %w", name, err)
+		}
+
+		count, err := s.CountUsers(ctx, name)
+		if err != nil {
+			return fmt.Errorf("count users: name[%s]: %w", name, err)
+		}
+
+		if count > 0 {
+			return fmt.Errorf("name[%s]: %w", name, ErrInUse)
+		}
+
+		if err := s.Delete(ctx, rol); err != nil {
+			return fmt.Errorf("delete: name[%s]: %w", name, err)
+		}
+		return nil
+	}
+
+	if err := c.storer.WithinTran(ctx, tran); err != nil {
+		return fmt.Errorf("tran: %w", err)
+	}
+
+	return nil
+}
+
+// Query retrieves every role sorted by name.
+func (c *Core) Query(ctx context.Context) ([]Role, error) {
+	roles, err := c.storer.Query(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return roles, nil
+}
+
+// QueryByName gets the specified role from the database.
+func (c *Core) QueryByName(ctx context.Context, name string) (Role, error) {
+	rol, err := c.storer.QueryByName(ctx, name)
+	if err != nil {
+		return Role{}, fmt.Errorf("query: name[%s]: %w", name, err)
+	}
+
+	return rol, nil
+}
+
+// QueryPermissions retrieves every permission a role can carry.
+func (c *Core) QueryPermissions(ctx context.Context) ([]Permission, error) {
+	perms, err := c.storer.QueryPermissions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("query permissions: %w", err)
+	}
+
+	return perms, nil
+}
+
+// Permissions returns the sorted set of permissions the specified roles
+// carry. Roles that don't exist carry no permissions.
+func (c *Core) Permissions(ctx context.Context, names []string) ([]string, error) {
+	roles, err := c.storer.QueryByNames(ctx, names)
+	if err != nil {
+		return nil, fmt.Errorf("query: names%v: %w", names, err)
+	}
+
+	var perms []string
+	for _, rol := range roles {
+		perms = append(perms, rol.Permissions...)
+	}
+
+	return uniqueSorted(perms), nil
+}
+
+// CheckGrant validates the specified roles can be granted by a caller that
+// holds the specified permissions. Every role must exist and callers can't
+// grant permissions they don't hold themselves.
+func (c *Core) CheckGrant(ctx context.Context, names []string, held []string) error {
+	heldSet := make(map[string]bool, len(held))
+	for _, p := range held {
+		heldSet[p] = true
+	}
+
+	roles, err := c.storer.QueryByNames(ctx, names)
+	if err != nil {
+		return fmt.Errorf("query: names%v: %w", names, err)
+	}
+
+	found := make(map[string]Role, len(roles))
+	for _, rol := range roles {
+		found[rol.Name] = rol
+	}
+
+	for _, name := range names {
+		if name == SuperAdmin {
+			return fmt.Errorf("name[%s]: %w", name, ErrReserved)
+		}
+
+		rol, exists := found[name]
+		if !exists {
+			return fmt.Errorf("name[%s]: %w", name, ErrNotFound)
+		}
+
+		for _, p := range rol.Permissions {
+			if !heldSet[p] {
+				return fmt.Errorf("name[%s] permission[%s]: %w", name, p, ErrNotGrantable)
+			}
+		}
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// change applies the function to the role with the specified name and stores
+// the result. The added permissions must exist.
+func (c *Core) change(ctx context.Context, name string, added []string, f func(rol Role) Role) (Role, error) {
+	if name == SuperAdmin {
+		return Role{}, ErrReserved
+	}
+
+	var rol Role
+
+	tran := func(s Storer) error {
+		if err := checkPermissions(ctx, s, added); err != nil {
+			return err
+		}
+
+		var err error
+		if rol, err = s.QueryByName(ctx, name); err != nil {
+			return fmt.Errorf("query: name[%s]: %w", name, err)
+		}
+
+		rol = f(rol)
+		rol.DateUpdated = time.Now()
+
+		if err := s.Update(ctx, rol); err != nil {
+			return fmt.Errorf("update: name[%s]: %w", name, err)
+		}
+		return nil
+	}
+
+	if err := c.storer.WithinTran(ctx, tran); err != nil {
+		return Role{}, fmt.Errorf("tran: %w", err)
+	}
+
+	return rol, nil
+}
+
+// checkPermissions validates that every permission exists.
+func checkPermissions(ctx context.Context, s Storer, perms []string) error {
+	if len(perms) == 0 {
+		return nil
+	}
+
+	known, err := s.QueryPermissions(ctx)
+	if err != nil {
+		return fmt.Errorf("query permissions: %w", err)
+	}
+
+	exists := make(map[string]bool, len(known))
+	for _, p := range known {
+		exists[p.Name] = true
+	}
+
+	for _, p := range perms {
+		if !exists[p] {
+			return fmt.Errorf("permission[%s]: %w", p, ErrUnknownPermission)
+		}
+	}
+
+	return nil
+}
+
+// uniqueSorted returns the sorted set of the values without duplicates.
+func uniqueSorted(values []string) []string {
+	set := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+
+	for _, v := range values {
+		if !set[v] {
+			set[v] = true
+			unique = append(unique, v)
+		}
+	}
+	sort.Strings(unique)
+
+	return unique
+}
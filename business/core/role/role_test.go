@@ -0,0 +1,141 @@
+package role_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"testing"
+
+	"github.com/ardanlabs/service/business/core/role"
+	"github.com/ardanlabs/service/business/core/role/stores/roledb"
+	"github.com/ardanlabs/service/business/data/dbtest"
+	"github.com/ardanlabs/service/foundation/docker"
+	"github.com/google/go-cmp/cmp"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func Test_Role(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testrole")
+	defer func() {
+		if r := recover(); r != nil {
+			t.Log(r)
+			t.Error(string(debug.Stack()))
+		}
+		teardown()
+	}()
+
+	core := role.NewCore(roledb.NewStore(log, db))
+
+	t.Log("Given the need to work with Role records.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single Role.", testID)
+		{
+			ctx := context.Background()
+
+			nr := role.NewRole{
+				Name:        "AUDITOR",
+				Description: "Reads reports",
+				Permissions: []string{role.PermReportsRead, role.PermUsersRead, role.PermReportsRead},
+			}
+
+			rol, err := core.Create(ctx, nr)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create role : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create role.", dbtest.Success, testID)
+
+			saved, err := core.QueryByName(ctx, rol.Name)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve role by name: %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve role by name.", dbtest.Success, testID)
+
+			exp := []string{role.PermReportsRead, role.PermUsersRead}
+			if diff := cmp.Diff(exp, saved.Permissions); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the unique permissions. Diff:\n%s", dbtest.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the unique permissions.", dbtest.Success, testID)
+
+			if _, err := core.Create(ctx, nr); !errors.Is(err, role.ErrUniqueName) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to create the role twice : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to create the role twice.", dbtest.Success, testID)
+
+			if _, err := core.AddPermission(ctx, rol.Name, "reports:delete"); !errors.Is(err, role.ErrUnknownPermission) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to add an unknown permission : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to add an unknown permission.", dbtest.Success, testID)
+
+			if _, err := core.RemovePermission(ctx, rol.Name, role.PermUsersRead); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to remove a permission : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to remove a permission.", dbtest.Success, testID)
+
+			perms, err := core.Permissions(ctx, []string{rol.Name, "UNKNOWN"})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to resolve permissions : %s.", dbtest.Failed, testID, err)
+			}
+
+			if diff := cmp.Diff([]string{role.PermReportsRead}, perms); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould resolve the permissions of the role. Diff:\n%s", dbtest.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould resolve the permissions of the role.", dbtest.Success, testID)
+
+			if err := core.Delete(ctx, rol.Name); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete role : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete role.", dbtest.Success, testID)
+
+			if _, err := core.QueryByName(ctx, rol.Name); !errors.Is(err, role.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve role : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve role.", dbtest.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen granting roles.", testID)
+		{
+			ctx := context.Background()
+
+			admin, err := core.Permissions(ctx, []string{role.Admin})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to resolve permissions : %s.", dbtest.Failed, testID, err)
+			}
+
+			if err := core.CheckGrant(ctx, []string{role.Admin, role.User}, admin); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to grant the roles an admin holds : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to grant the roles an admin holds.", dbtest.Success, testID)
+
+			if err := core.CheckGrant(ctx, []string{role.SuperAdmin}, admin); !errors.Is(err, role.ErrReserved) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to grant the reserved role : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to grant the reserved role.", dbtest.Success, testID)
+
+			if err := core.CheckGrant(ctx, []string{role.Admin}, nil); !errors.Is(err, role.ErrNotGrantable) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to grant permissions not held : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to grant permissions not held.", dbtest.Success, testID)
+
+			if err := core.Delete(ctx, role.Admin); !errors.Is(err, role.ErrInUse) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to delete a role in use : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to delete a role in use.", dbtest.Success, testID)
+		}
+	}
+}
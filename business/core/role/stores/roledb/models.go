@@ -0,0 +1,65 @@
+package roledb
+
+import (
+	"time"
+
+	"github.com/ardanlabs/service/business/core/role"
+	"github.com/lib/pq"
+)
+
+// dbRole represent the structure we need for moving data
+// between the app and the database.
+type dbRole struct {
+	Name        string         `db:"name"`
+	Description string         `db:"description"`
+	Permissions pq.StringArray `db:"permissions"`
+	DateCreated time.Time      `db:"date_created"`
+	DateUpdated time.Time      `db:"date_updated"`
+}
+
+// dbPermission represents a permission a role can carry.
+type dbPermission struct {
+	Name        string `db:"name"`
+	Description string `db:"description"`
+}
+
+func toDBRole(rol role.Role) dbRole {
+	return dbRole{
+		Name:        rol.Name,
+		Description: rol.Description,
+		Permissions: rol.Permissions,
+		DateCreated: rol.DateCreated.UTC(),
+		DateUpdated: rol.DateUpdated.UTC(),
+	}
+}
+
+func toCoreRole(dbRol dbRole) role.Role {
+	perms := []string(dbRol.Permissions)
+	if perms == nil {
+		perms = []string{}
+	}
+
+	return role.Role{
+		Name:        dbRol.Name,
+		Description: dbRol.Description,
+		Permissions: perms,
+		DateCreated: dbRol.DateCreated.In(time.Local),
+		DateUpdated: dbRol.DateUpdated.In(time.Local),
+	}
+}
+
+func toCoreRoleSlice(dbRoles []dbRole) []role.Role {
+	roles := make([]role.Role, len(dbRoles))
+	for i, dbRol := range dbRoles {
+		roles[i] = toCoreRole(dbRol)
+	}
+	return roles
+}
+
+func toCorePermissionSlice(dbPerms []dbPermission) []role.Permission {
+	perms := make([]role.Permission, len(dbPerms))
+	for i, dbPerm := range dbPerms {
+		perms[i] = role.Permission(dbPerm)
+	}
+	return perms
+}
@@ -0,0 +1,277 @@
+// Package roledb contains role and permission related CRUD functionality.
+package roledb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ardanlabs/service/business/core/role"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for role database access.
+type Store struct {
+	log    *zap.SugaredLogger
+	db     sqlx.ExtContext
+	inTran bool
+}
+
+// NewStore constructs the api for data access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) *Store {
+	return &Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// WithinTran runs passed function and do commit/rollback at the end.
+func (s *Store) WithinTran(ctx context.Context, fn func(s role.Storer) error) error {
+	if s.inTran {
+		return fn(s)
+	}
+
+	f := func(tx *sqlx.Tx) error {
+		s := &Store{
+			log:    s.log,
+			db:     tx,
+			inTran: true,
+		}
+		return fn(s)
+	}
+
+	return database.WithinTran(ctx, s.log, s.db.(*sqlx.DB), f)
+}
+
+// Create inserts a new role and its permissions into the database. This must
+// be called inside of a transaction.
+func (s *Store) Create(ctx context.Context, rol role.Role) error {
+	if !s.inTran {
+		return errors.New("create must be called within a transaction")
+	}
+
+	const q = `
+	INSERT INTO roles
+		(name, description, date_created, date_updated)
+	VALUES
+		(:name, :description, :date_created, :date_updated)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, toDBRole(rol)); err != nil {
+		if errors.Is(err, database.ErrDBDuplicatedEntry) {
+			return fmt.Errorf("create: %w", role.ErrUniqueName)
+		}
+		return fmt.Errorf("inserting role: %w", err)
+	}
+
+	if err := s.insertPermissions(ctx, rol); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Update replaces a role and its set of permissions. This must be called
+// inside of a transaction.
+func (s *Store) Update(ctx context.Context, rol role.Role) error {
+	if !s.inTran {
+		return errors.New("update must be called within a transaction")
+	}
+
+	const q = `
+	UPDATE
+		roles
+	SET
+		"description" = :description,
+		"date_updated" = :date_updated
+	WHERE
+		name = :name`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, toDBRole(rol)); err != nil {
+		return fmt.Errorf("updating name[%s]: %w", rol.Name, err)
+	}
+
+	const del = `
+	DELETE FROM
+		role_permissions
+	WHERE
+		role_name = :name`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, del, toDBRole(rol)); err != nil {
+		return fmt.Errorf("deleting permissions name[%s]: %w", rol.Name, err)
+	}
+
+	if err := s.insertPermissions(ctx, rol); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes a role and the permissions attached to it.
+func (s *Store) Delete(ctx context.Context, rol role.Role) error {
+	data := struct {
+		Name string `db:"name"`
+	}{
+		Name: rol.Name,
+	}
+
+	const q = `
+	DELETE FROM
+		roles
+	WHERE
+		name = :name`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting name[%s]: %w", rol.Name, err)
+	}
+
+	return nil
+}
+
+// selectRoles selects roles with their permissions aggregated into a list.
+const selectRoles = `
+	SELECT
+		r.name,
+		r.description,
+		r.date_created,
+		r.date_updated,
+		COALESCE(array_agg(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}') AS permissions
+	FROM
+		roles AS r
+	LEFT JOIN
+		role_permissions AS rp ON rp.role_name = r.name`
+
+// Query retrieves every role sorted by name.
+func (s *Store) Query(ctx context.Context) ([]role.Role, error) {
+	const q = selectRoles + `
+	GROUP BY
+		r.name
+	ORDER BY
+		r.name`
+
+	var dbRoles []dbRole
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, struct{}{}, &dbRoles); err != nil {
+		return nil, fmt.Errorf("selecting roles: %w", err)
+	}
+
+	return toCoreRoleSlice(dbRoles), nil
+}
+
+// QueryByName gets the specified role from the database.
+func (s *Store) QueryByName(ctx context.Context, name string) (role.Role, error) {
+	data := struct {
+		Name string `db:"name"`
+	}{
+		Name: name,
+	}
+
+	const q = selectRoles + `
+	WHERE
+		r.name = :name
+	GROUP BY
+		r.name`
+
+	var dbRol dbRole
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &dbRol); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return role.Role{}, role.ErrNotFound
+		}
+		return role.Role{}, fmt.Errorf("selecting name[%q]: %w", name, err)
+	}
+
+	return toCoreRole(dbRol), nil
+}
+
+// QueryByNames gets the roles with the specified names. Names that don't
+// match a role are ignored.
+func (s *Store) QueryByNames(ctx context.Context, names []string) ([]role.Role, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	data := struct {
+		Names []string `db:"names"`
+	}{
+		Names: names,
+	}
+
+	const q = selectRoles + `
+	WHERE
+		r.name IN (:names)
+	GROUP BY
+		r.name`
+
+	var dbRoles []dbRole
+	if err := database.NamedQuerySliceUsingIN(ctx, s.log, s.db, q, data, &dbRoles); err != nil {
+		return nil, fmt.Errorf("selecting names%v: %w", names, err)
+	}
+
+	return toCoreRoleSlice(dbRoles), nil
+}
+
+// QueryPermissions retrieves every permission sorted by name.
+func (s *Store) QueryPermissions(ctx context.Context) ([]role.Permission, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		permissions
+	ORDER BY
+		name`
+
+	var dbPerms []dbPermission
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, struct{}{}, &dbPerms); err != nil {
+		return nil, fmt.Errorf("selecting permissions: %w", err)
+	}
+
+	return toCorePermissionSlice(dbPerms), nil
+}
+
+// CountUsers returns the number of users the role is granted to.
+func (s *Store) CountUsers(ctx context.Context, name string) (int, error) {
+	data := struct {
+		Name string `db:"name"`
+	}{
+		Name: name,
+	}
+
+	const q = `
+	SELECT
+		count(1)
+	FROM
+		users
+	WHERE
+		:name = ANY(roles)`
+
+	var count struct {
+		Count int `db:"count"`
+	}
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &count); err != nil {
+		return 0, fmt.Errorf("counting users name[%s]: %w", name, err)
+	}
+
+	return count.Count, nil
+}
+
+// =============================================================================
+
+// insertPermissions attaches the permissions of the role to it.
+func (s *Store) insertPermissions(ctx context.Context, rol role.Role) error {
+	if len(rol.Permissions) == 0 {
+		return nil
+	}
+
+	const q = `
+	INSERT INTO role_permissions
+		(role_name, permission)
+	SELECT
+		CAST(:name AS TEXT), unnest(CAST(:permissions AS TEXT[]))`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, toDBRole(rol)); err != nil {
+		return fmt.Errorf("inserting permissions name[%s]: %w", rol.Name, err)
+	}
+
+	return nil
+}
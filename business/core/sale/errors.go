@@ -0,0 +1,27 @@
+package sale
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// InsufficientStockError is returned when recording a sale would drive the
+// quantity of a product below zero.
+type InsufficientStockError struct {
+	ProductID uuid.UUID
+	Available int
+	Requested int
+}
+
+// Error implements the error interface.
+func (ise *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for productID[%s]: available[%d] requested[%d]", ise.ProductID, ise.Available, ise.Requested)
+}
+
+// IsInsufficientStock checks if an error of type InsufficientStockError exists.
+func IsInsufficientStock(err error) bool {
+	var ise *InsufficientStockError
+	return errors.As(err, &ise)
+}
@@ -0,0 +1,35 @@
+package sale
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sale represents an individual sale of a product.
+type Sale struct {
+	ID          uuid.UUID `json:"id"`
+	TenantID    uuid.UUID `json:"tenantID"`
+	UserID      uuid.UUID `json:"userID"`
+	ProductID   uuid.UUID `json:"productID"`
+	Quantity    int       `json:"quantity"`
+	Paid        int       `json:"paid"`
+	DateCreated time.Time `json:"dateCreated"`
+}
+
+// NewSale is what we require from clients when recording a Sale. The UserID
+// is not provided by the client, it is set from the claims of the
+// authenticated user that is recording the sale.
+type NewSale struct {
+	ProductID uuid.UUID `json:"productID" validate:"required"`
+	Quantity  int       `json:"quantity" validate:"required,gte=1"`
+	UserID    uuid.UUID `json:"-"`
+}
+
+// Stock represents the inventory information for a product that is needed
+// to record a sale.
+type Stock struct {
+	ProductID uuid.UUID
+	Cost      int
+	Quantity  int
+}
@@ -0,0 +1,124 @@
+// Package sale provides the core business API for recording sales. Recording
+// a sale also adjusts the inventory of the product being sold.
+package sale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/google/uuid"
+)
+
+// Set of error variables for CRUD operations.
+var (
+	ErrNotFound        = errors.New("sale not found")
+	ErrProductNotFound = errors.New("product not found")
+	ErrInvalidUser     = errors.New("sale user is not valid")
+	ErrStockNotUpdated = errors.New("stock not updated")
+)
+
+// Storer interface declares the behavior this package needs to perists and
+// retrieve data.
+type Storer interface {
+	WithinTran(ctx context.Context, fn func(s Storer) error) error
+	Create(ctx context.Context, sl Sale) error
+	QueryByID(ctx context.Context, saleID uuid.UUID) (Sale, error)
+	QueryStock(ctx context.Context, productID uuid.UUID) (Stock, error)
+	UpdateStock(ctx context.Context, productID uuid.UUID, sold int, dateUpdated time.Time) (Stock, error)
+}
+
+// Core manages the set of APIs for sale access.
+type Core struct {
+	storer Storer
+}
+
+// NewCore constructs a core for sale api access.
+func NewCore(storer Storer) *Core {
+	return &Core{
+		storer: storer,
+	}
+}
+
+// Create records a sale. The stock is only decremented when there is enough
+// of it to cover the sale, which the database checks as part of the update so
+// concurrent sales of the same product can't oversell it. The amount paid is
+// calculated from the cost of the product at the time of the sale.
+func (c *Core) Create(ctx context.Context, ns NewSale) (Sale, error) {
+	if err := validate.Check(ns); err != nil {
+		return Sale{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	if ns.UserID == uuid.Nil {
+		return Sale{}, ErrInvalidUser
+	}
+
+	now := time.Now()
+
+	sl := Sale{
+		ID:          uuid.New(),
+		UserID:      ns.UserID,
+		ProductID:   ns.ProductID,
+		Quantity:    ns.Quantity,
+		DateCreated: now,
+	}
+
+	tran := func(s Storer) error {
+		stock, err := s.UpdateStock(ctx, ns.ProductID, ns.Quantity, now)
+		if err != nil {
+			if !errors.Is(err, ErrStockNotUpdated) {
+				return fmt.Errorf("update stock: %w", err)
+			}
+
+			// Nothing was sold, find out if the product is unknown or
+			// doesn't have enough stock to cover the sale.
+			current, qerr := s.QueryStock(ctx, ns.ProductID)
+			if qerr != nil {
+				return fmt.Errorf("query stock: %w", qerr)
+			}
+
+			if current.Quantity < ns.Quantity {
+				return &InsufficientStockError{
+					ProductID: ns.ProductID,
+					Available: current.Quantity,
+					Requested: ns.Quantity,
+				}
+			}
+
+			return fmt.Errorf("update stock: productID[%s]: %w", ns.ProductID, err)
+		}
+
+		sl.Paid = stock.Cost * ns.Quantity
+		if err := s.Create(ctx, sl); err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+
+		// The sale belongs to the tenant of the user that made it, which the
+		// store sets, so the sale is read back to return it as stored.
+		saved, err := s.QueryByID(ctx, sl.ID)
+		if err != nil {
+			return fmt.Errorf("query: saleID[%s]: %w", sl.ID, err)
+		}
+		sl = saved
+
+		return nil
+	}
+
+	if err := c.storer.WithinTran(ctx, tran); err != nil {
+		return Sale{}, fmt.Errorf("tran: %w", err)
+	}
+
+	return sl, nil
+}
+
+// QueryByID gets the specified sale from the database.
+func (c *Core) QueryByID(ctx context.Context, saleID uuid.UUID) (Sale, error) {
+	sl, err := c.storer.QueryByID(ctx, saleID)
+	if err != nil {
+		return Sale{}, fmt.Errorf("query: saleID[%s]: %w", saleID, err)
+	}
+
+	return sl, nil
+}
@@ -0,0 +1,247 @@
+package sale_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"runtime/debug"
+	"sync"
+	"testing"
+
+	"github.com/ardanlabs/service/business/core/product"
+	"github.com/ardanlabs/service/business/core/product/stores/productdb"
+	"github.com/ardanlabs/service/business/core/role"
+	"github.com/ardanlabs/service/business/core/sale"
+	"github.com/ardanlabs/service/business/core/sale/stores/saledb"
+	"github.com/ardanlabs/service/business/core/user"
+	"github.com/ardanlabs/service/business/core/user/stores/userdb"
+	"github.com/ardanlabs/service/business/data/dbtest"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/ardanlabs/service/business/sys/tenant"
+	"github.com/ardanlabs/service/foundation/docker"
+	"github.com/google/uuid"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func Test_Sale(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testsale")
+	defer func() {
+		if r := recover(); r != nil {
+			t.Log(r)
+			t.Error(string(debug.Stack()))
+		}
+		teardown()
+	}()
+
+	core := sale.NewCore(saledb.NewStore(log, db))
+	prdCore := product.NewCore(productdb.NewStore(log, db))
+	usrCore := user.NewCore(userdb.NewStore(log, db))
+
+	// Seeded products "Comic Books" costs 50 with 42 in stock and "McDonalds
+	// Toys" costs 75 with 120 in stock. Both are owned by the seeded user.
+	productID := uuid.MustParse("a2b0639f-2cc6-44b8-b97b-15d69dbb511e")
+	toysID := uuid.MustParse("72f8b983-3eb4-48db-9ed0-e45cc6bd716b")
+	userID := uuid.MustParse("45b5fbd3-755f-4379-8f07-a58d4a30fa2f")
+
+	// Sales are recorded under a database session, like a request would, so
+	// the row level security policies apply.
+	session := func(ctx context.Context, userID uuid.UUID) context.Context {
+		return database.SetSession(ctx, database.Session{
+			UserID: userID.String(),
+			Roles:  []string{role.User},
+		})
+	}
+
+	t.Log("Given the need to record Sales against product inventory.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen recording a single Sale.", testID)
+		{
+			ctx := session(tenant.Set(context.Background(), tenant.Default), userID)
+
+			ns := sale.NewSale{
+				ProductID: productID,
+				Quantity:  2,
+				UserID:    userID,
+			}
+
+			sl, err := core.Create(ctx, ns)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to record a sale : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to record a sale.", dbtest.Success, testID)
+
+			if sl.Paid != 100 {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, sl.Paid)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, 100)
+				t.Fatalf("\t%s\tTest %d:\tShould calculate paid from the product cost.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould calculate paid from the product cost.", dbtest.Success, testID)
+
+			if _, err := core.QueryByID(ctx, sl.ID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the sale : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve the sale.", dbtest.Success, testID)
+
+			prd, err := prdCore.QueryByID(ctx, productID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the product : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve the product.", dbtest.Success, testID)
+
+			if prd.Quantity != 40 {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, prd.Quantity)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, 40)
+				t.Fatalf("\t%s\tTest %d:\tShould decrement the product quantity.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould decrement the product quantity.", dbtest.Success, testID)
+
+			ns.ProductID = uuid.New()
+			if _, err := core.Create(ctx, ns); !errors.Is(err, sale.ErrProductNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to sell an unknown product : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to sell an unknown product.", dbtest.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen recording concurrent Sales for the same product.", testID)
+		{
+			ctx := session(tenant.Set(context.Background(), tenant.Default), userID)
+
+			const goroutines = 10
+
+			var wg sync.WaitGroup
+			wg.Add(goroutines)
+
+			errs := make(chan error, goroutines)
+			for i := 0; i < goroutines; i++ {
+				go func() {
+					defer wg.Done()
+
+					ns := sale.NewSale{
+						ProductID: productID,
+						Quantity:  5,
+						UserID:    userID,
+					}
+
+					_, err := core.Create(ctx, ns)
+					errs <- err
+				}()
+			}
+
+			wg.Wait()
+			close(errs)
+
+			var sold, rejected int
+			for err := range errs {
+				switch {
+				case err == nil:
+					sold++
+				case sale.IsInsufficientStock(err):
+					rejected++
+				default:
+					t.Fatalf("\t%s\tTest %d:\tShould only fail with insufficient stock : %s.", dbtest.Failed, testID, err)
+				}
+			}
+
+			if sold != 8 || rejected != 2 {
+				t.Logf("\t\tTest %d:\tGot: sold[%d] rejected[%d]", testID, sold, rejected)
+				t.Logf("\t\tTest %d:\tExp: sold[%d] rejected[%d]", testID, 8, 2)
+				t.Fatalf("\t%s\tTest %d:\tShould reject the sales that would oversell.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the sales that would oversell.", dbtest.Success, testID)
+
+			prd, err := prdCore.QueryByID(ctx, productID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the product : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve the product.", dbtest.Success, testID)
+
+			if prd.Quantity != 0 {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, prd.Quantity)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, 0)
+				t.Fatalf("\t%s\tTest %d:\tShould never drive the quantity below zero.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould never drive the quantity below zero.", dbtest.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen a user sells a product they don't own.", testID)
+		{
+			ctx := tenant.Set(context.Background(), tenant.Default)
+
+			email, err := mail.ParseAddress("seller@ardanlabs.com")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to parse email : %s.", dbtest.Failed, testID, err)
+			}
+
+			nu := user.NewUser{
+				Name:            "Seller Gopher",
+				Email:           *email,
+				Roles:           []string{role.User},
+				Password:        "gophers",
+				PasswordConfirm: "gophers",
+			}
+
+			seller, err := usrCore.Create(ctx, nu)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create the seller : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create the seller.", dbtest.Success, testID)
+
+			ctx = session(ctx, seller.ID)
+
+			ns := sale.NewSale{
+				ProductID: toysID,
+				Quantity:  2,
+				UserID:    seller.ID,
+			}
+
+			sl, err := core.Create(ctx, ns)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to record a sale : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to record a sale.", dbtest.Success, testID)
+
+			if sl.Paid != 150 {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, sl.Paid)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, 150)
+				t.Fatalf("\t%s\tTest %d:\tShould calculate paid from the product cost.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould calculate paid from the product cost.", dbtest.Success, testID)
+
+			prd, err := prdCore.QueryByID(ctx, toysID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the product : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve the product.", dbtest.Success, testID)
+
+			if prd.Quantity != 118 {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, prd.Quantity)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, 118)
+				t.Fatalf("\t%s\tTest %d:\tShould decrement the product quantity.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould decrement the product quantity.", dbtest.Success, testID)
+
+			ns.Quantity = 1000
+			if _, err := core.Create(ctx, ns); !sale.IsInsufficientStock(err) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to oversell the product : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to oversell the product.", dbtest.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package saledb
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/ardanlabs/service/business/sys/tenant"
+)
+
+// tenantClauses returns the predicate that limits a query to the tenant in
+// scope, there is none when every tenant is in scope. The value is added to
+// data so it's bound as a parameter.
+func tenantClauses(ctx context.Context, data map[string]any) ([]string, error) {
+	scope, err := tenant.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if scope.All {
+		return nil, nil
+	}
+
+	data["tenant_id"] = scope.ID
+
+	return []string{"tenant_id = :tenant_id"}, nil
+}
+
+// writeWhere writes the WHERE clause for the set of predicates.
+func writeWhere(buf *bytes.Buffer, wc []string) {
+	if len(wc) > 0 {
+		buf.WriteString(" WHERE ")
+		buf.WriteString(strings.Join(wc, " AND "))
+	}
+}
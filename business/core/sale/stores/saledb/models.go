@@ -0,0 +1,60 @@
+package saledb
+
+import (
+	"time"
+
+	"github.com/ardanlabs/service/business/core/sale"
+	"github.com/google/uuid"
+)
+
+// dbSale represent the structure we need for moving data
+// between the app and the database.
+type dbSale struct {
+	ID          uuid.UUID `db:"sale_id"`
+	TenantID    uuid.UUID `db:"tenant_id"`
+	UserID      uuid.UUID `db:"user_id"`
+	ProductID   uuid.UUID `db:"product_id"`
+	Quantity    int       `db:"quantity"`
+	Paid        int       `db:"paid"`
+	DateCreated time.Time `db:"date_created"`
+}
+
+// dbStock represents the inventory columns of a product.
+type dbStock struct {
+	ProductID   uuid.UUID `db:"product_id"`
+	Cost        int       `db:"cost"`
+	Quantity    int       `db:"quantity"`
+	DateUpdated time.Time `db:"date_updated"`
+}
+
+func toDBSale(sl sale.Sale) dbSale {
+	return dbSale{
+		ID:          sl.ID,
+		TenantID:    sl.TenantID,
+		UserID:      sl.UserID,
+		ProductID:   sl.ProductID,
+		Quantity:    sl.Quantity,
+		Paid:        sl.Paid,
+		DateCreated: sl.DateCreated.UTC(),
+	}
+}
+
+func toCoreSale(dbSl dbSale) sale.Sale {
+	return sale.Sale{
+		ID:          dbSl.ID,
+		TenantID:    dbSl.TenantID,
+		UserID:      dbSl.UserID,
+		ProductID:   dbSl.ProductID,
+		Quantity:    dbSl.Quantity,
+		Paid:        dbSl.Paid,
+		DateCreated: dbSl.DateCreated.In(time.Local),
+	}
+}
+
+func toCoreStock(dbStk dbStock) sale.Stock {
+	return sale.Stock{
+		ProductID: dbStk.ProductID,
+		Cost:      dbStk.Cost,
+		Quantity:  dbStk.Quantity,
+	}
+}
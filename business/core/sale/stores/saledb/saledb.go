@@ -0,0 +1,156 @@
+// Package saledb contains sale related CRUD functionality.
+package saledb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/sale"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for sale database access.
+type Store struct {
+	log    *zap.SugaredLogger
+	db     sqlx.ExtContext
+	inTran bool
+}
+
+// NewStore constructs the api for data access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) *Store {
+	return &Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// WithinTran runs passed function and do commit/rollback at the end.
+func (s *Store) WithinTran(ctx context.Context, fn func(s sale.Storer) error) error {
+	if s.inTran {
+		return fn(s)
+	}
+
+	f := func(tx *sqlx.Tx) error {
+		s := &Store{
+			log:    s.log,
+			db:     tx,
+			inTran: true,
+		}
+		return fn(s)
+	}
+
+	return database.WithinTran(ctx, s.log, s.db.(*sqlx.DB), f)
+}
+
+// Create inserts a new sale into the database. The sale belongs to the tenant
+// of the user that made it.
+func (s *Store) Create(ctx context.Context, sl sale.Sale) error {
+	const q = `
+	INSERT INTO sales
+		(sale_id, tenant_id, user_id, product_id, quantity, paid, date_created)
+	VALUES
+		(:sale_id, (SELECT tenant_id FROM users WHERE user_id = :user_id), :user_id, :product_id, :quantity, :paid, :date_created)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, toDBSale(sl)); err != nil {
+		return fmt.Errorf("inserting sale: %w", err)
+	}
+
+	return nil
+}
+
+// QueryByID gets the specified sale from the database.
+func (s *Store) QueryByID(ctx context.Context, saleID uuid.UUID) (sale.Sale, error) {
+	data := map[string]any{
+		"sale_id": saleID.String(),
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		sales`
+
+	wc, err := tenantClauses(ctx, data)
+	if err != nil {
+		return sale.Sale{}, err
+	}
+
+	buf := bytes.NewBufferString(q)
+	writeWhere(buf, append(wc, "sale_id = :sale_id"))
+
+	var sl dbSale
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, buf.String(), data, &sl); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return sale.Sale{}, sale.ErrNotFound
+		}
+		return sale.Sale{}, fmt.Errorf("selecting saleID[%q]: %w", saleID, err)
+	}
+
+	return toCoreSale(sl), nil
+}
+
+// QueryStock retrieves the inventory information for the specified product.
+func (s *Store) QueryStock(ctx context.Context, productID uuid.UUID) (sale.Stock, error) {
+	data := map[string]any{
+		"product_id": productID.String(),
+	}
+
+	const q = `
+	SELECT
+		product_id, cost, quantity, date_updated
+	FROM
+		products`
+
+	wc, err := tenantClauses(ctx, data)
+	if err != nil {
+		return sale.Stock{}, err
+	}
+
+	buf := bytes.NewBufferString(q)
+	writeWhere(buf, append(wc, "product_id = :product_id"))
+
+	var stk dbStock
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, buf.String(), data, &stk); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return sale.Stock{}, sale.ErrProductNotFound
+		}
+		return sale.Stock{}, fmt.Errorf("selecting productID[%q]: %w", productID, err)
+	}
+
+	return toCoreStock(stk), nil
+}
+
+// UpdateStock decrements the quantity on hand for the specified product by
+// the quantity sold and returns the stock left. Sellers don't need to own the
+// product, so the decrement is made by the app_sell_stock function which
+// only checks the product belongs to the tenant of the session and has
+// enough stock. Nothing is updated when there is no session.
+func (s *Store) UpdateStock(ctx context.Context, productID uuid.UUID, sold int, dateUpdated time.Time) (sale.Stock, error) {
+	data := map[string]any{
+		"product_id":   productID.String(),
+		"sold":         sold,
+		"date_updated": dateUpdated.UTC(),
+	}
+
+	const q = `
+	SELECT
+		product_id, cost, quantity, date_updated
+	FROM
+		app_sell_stock(:product_id, :sold, :date_updated)`
+
+	var stk dbStock
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &stk); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return sale.Stock{}, sale.ErrStockNotUpdated
+		}
+		return sale.Stock{}, fmt.Errorf("updating stock productID[%s]: %w", productID, err)
+	}
+
+	return toCoreStock(stk), nil
+}
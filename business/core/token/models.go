@@ -0,0 +1,52 @@
+package token
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken represents a refresh token that has been issued to a user.
+// Only a hash of the token is kept, the token itself is handed to the client
+// once and never stored. The ClientID is the OAuth client the token was
+// issued to, uuid.Nil when it wasn't issued to a client.
+type RefreshToken struct {
+	ID          uuid.UUID
+	FamilyID    uuid.UUID
+	UserID      uuid.UUID
+	ClientID    uuid.UUID
+	Hash        string
+	DateCreated time.Time
+	DateExpires time.Time
+	DateUsed    time.Time
+	DateRevoked time.Time
+}
+
+// Used reports whether the refresh token has already been exchanged.
+func (rt RefreshToken) Used() bool {
+	return !rt.DateUsed.IsZero()
+}
+
+// Revoked reports whether the refresh token has been revoked.
+func (rt RefreshToken) Revoked() bool {
+	return !rt.DateRevoked.IsZero()
+}
+
+// RevokedToken represents an access token that has been placed on the
+// denylist before it expired.
+type RevokedToken struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	DateExpires time.Time
+	DateCreated time.Time
+}
+
+// Refresh is what is handed back to the client when a refresh token is
+// issued. The family identifies the session the token belongs to and is
+// carried in the access tokens issued alongside it.
+type Refresh struct {
+	Token    string
+	FamilyID uuid.UUID
+	UserID   uuid.UUID
+	Expires  time.Time
+}
@@ -0,0 +1,89 @@
+package tokendb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/token"
+	"github.com/google/uuid"
+)
+
+// dbRefreshToken represent the structure we need for moving data
+// between the app and the database.
+type dbRefreshToken struct {
+	ID          uuid.UUID     `db:"token_id"`
+	FamilyID    uuid.UUID     `db:"family_id"`
+	UserID      uuid.UUID     `db:"user_id"`
+	ClientID    uuid.NullUUID `db:"client_id"`
+	Hash        string        `db:"token_hash"`
+	DateCreated time.Time     `db:"date_created"`
+	DateExpires time.Time     `db:"date_expires"`
+	DateUsed    sql.NullTime  `db:"date_used"`
+	DateRevoked sql.NullTime  `db:"date_revoked"`
+}
+
+// dbRevokedToken represents an access token on the denylist.
+type dbRevokedToken struct {
+	ID          uuid.UUID `db:"jti"`
+	UserID      uuid.UUID `db:"user_id"`
+	DateExpires time.Time `db:"date_expires"`
+	DateCreated time.Time `db:"date_created"`
+}
+
+func toDBRefreshToken(rt token.RefreshToken) dbRefreshToken {
+	return dbRefreshToken{
+		ID:          rt.ID,
+		FamilyID:    rt.FamilyID,
+		UserID:      rt.UserID,
+		ClientID:    toNullUUID(rt.ClientID),
+		Hash:        rt.Hash,
+		DateCreated: rt.DateCreated.UTC(),
+		DateExpires: rt.DateExpires.UTC(),
+		DateUsed:    toNullTime(rt.DateUsed),
+		DateRevoked: toNullTime(rt.DateRevoked),
+	}
+}
+
+func toCoreRefreshToken(dbRT dbRefreshToken) token.RefreshToken {
+	return token.RefreshToken{
+		ID:          dbRT.ID,
+		FamilyID:    dbRT.FamilyID,
+		UserID:      dbRT.UserID,
+		ClientID:    dbRT.ClientID.UUID,
+		Hash:        dbRT.Hash,
+		DateCreated: dbRT.DateCreated.In(time.Local),
+		DateExpires: dbRT.DateExpires.In(time.Local),
+		DateUsed:    fromNullTime(dbRT.DateUsed),
+		DateRevoked: fromNullTime(dbRT.DateRevoked),
+	}
+}
+
+func toDBRevokedToken(rvk token.RevokedToken) dbRevokedToken {
+	return dbRevokedToken{
+		ID:          rvk.ID,
+		UserID:      rvk.UserID,
+		DateExpires: rvk.DateExpires.UTC(),
+		DateCreated: rvk.DateCreated.UTC(),
+	}
+}
+
+func toNullUUID(id uuid.UUID) uuid.NullUUID {
+	if id == uuid.Nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: id, Valid: true}
+}
+
+func toNullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+func fromNullTime(nt sql.NullTime) time.Time {
+	if !nt.Valid {
+		return time.Time{}
+	}
+	return nt.Time.In(time.Local)
+}
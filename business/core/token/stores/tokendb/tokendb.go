@@ -0,0 +1,282 @@
+// Package tokendb contains refresh token and revocation related CRUD
+// functionality.
+package tokendb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/token"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for token database access.
+type Store struct {
+	log    *zap.SugaredLogger
+	db     sqlx.ExtContext
+	inTran bool
+}
+
+// NewStore constructs the api for data access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) *Store {
+	return &Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// WithinTran runs passed function and do commit/rollback at the end.
+func (s *Store) WithinTran(ctx context.Context, fn func(s token.Storer) error) error {
+	if s.inTran {
+		return fn(s)
+	}
+
+	f := func(tx *sqlx.Tx) error {
+		s := &Store{
+			log:    s.log,
+			db:     tx,
+			inTran: true,
+		}
+		return fn(s)
+	}
+
+	return database.WithinTran(ctx, s.log, s.db.(*sqlx.DB), f)
+}
+
+// Create inserts a new refresh token into the database.
+func (s *Store) Create(ctx context.Context, rt token.RefreshToken) error {
+	const q = `
+	INSERT INTO refresh_tokens
+		(token_id, family_id, user_id, client_id, token_hash, date_created, date_expires, date_used, date_revoked)
+	VALUES
+		(:token_id, :family_id, :user_id, :client_id, :token_hash, :date_created, :date_expires, :date_used, :date_revoked)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, toDBRefreshToken(rt)); err != nil {
+		return fmt.Errorf("inserting refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// QueryByHashForUpdate gets the refresh token with the specified hash and
+// locks the row until the transaction completes. This must be called inside
+// of a transaction.
+func (s *Store) QueryByHashForUpdate(ctx context.Context, hash string) (token.RefreshToken, error) {
+	if !s.inTran {
+		return token.RefreshToken{}, errors.New("query by hash for update must be called within a transaction")
+	}
+
+	data := struct {
+		Hash string `db:"token_hash"`
+	}{
+		Hash: hash,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		refresh_tokens
+	WHERE
+		token_hash = :token_hash
+	FOR UPDATE`
+
+	var rt dbRefreshToken
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &rt); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return token.RefreshToken{}, token.ErrNotFound
+		}
+		return token.RefreshToken{}, fmt.Errorf("selecting refresh token: %w", err)
+	}
+
+	return toCoreRefreshToken(rt), nil
+}
+
+// MarkUsed records that the specified refresh token has been exchanged.
+func (s *Store) MarkUsed(ctx context.Context, tokenID uuid.UUID, dateUsed time.Time) error {
+	data := struct {
+		ID       string    `db:"token_id"`
+		DateUsed time.Time `db:"date_used"`
+	}{
+		ID:       tokenID.String(),
+		DateUsed: dateUsed.UTC(),
+	}
+
+	const q = `
+	UPDATE
+		refresh_tokens
+	SET
+		"date_used" = :date_used
+	WHERE
+		token_id = :token_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("updating tokenID[%s]: %w", tokenID, err)
+	}
+
+	return nil
+}
+
+// RevokeFamily revokes every refresh token in the specified family.
+func (s *Store) RevokeFamily(ctx context.Context, familyID uuid.UUID, dateRevoked time.Time) error {
+	data := struct {
+		FamilyID    string    `db:"family_id"`
+		DateRevoked time.Time `db:"date_revoked"`
+	}{
+		FamilyID:    familyID.String(),
+		DateRevoked: dateRevoked.UTC(),
+	}
+
+	const q = `
+	UPDATE
+		refresh_tokens
+	SET
+		"date_revoked" = :date_revoked
+	WHERE
+		family_id = :family_id AND
+		date_revoked IS NULL`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("revoking familyID[%s]: %w", familyID, err)
+	}
+
+	return nil
+}
+
+// RevokeClient revokes every refresh token in the families of the tokens
+// issued to the specified client.
+func (s *Store) RevokeClient(ctx context.Context, clientID uuid.UUID, dateRevoked time.Time) error {
+	data := struct {
+		ClientID    string    `db:"client_id"`
+		DateRevoked time.Time `db:"date_revoked"`
+	}{
+		ClientID:    clientID.String(),
+		DateRevoked: dateRevoked.UTC(),
+	}
+
+	const q = `
+	UPDATE
+		refresh_tokens
+	SET
+		"date_revoked" = :date_revoked
+	WHERE
+		family_id IN (SELECT family_id FROM refresh_tokens WHERE client_id = :client_id) AND
+		date_revoked IS NULL`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("revoking clientID[%s]: %w", clientID, err)
+	}
+
+	return nil
+}
+
+// RevokeUser revokes every refresh token that belongs to the specified user
+// and every access token issued to the user up to the time of the revocation.
+func (s *Store) RevokeUser(ctx context.Context, userID uuid.UUID, dateRevoked time.Time) error {
+	data := struct {
+		UserID      string    `db:"user_id"`
+		DateRevoked time.Time `db:"date_revoked"`
+	}{
+		UserID:      userID.String(),
+		DateRevoked: dateRevoked.UTC(),
+	}
+
+	const q = `
+	WITH revoked AS (
+		INSERT INTO revoked_users
+			(user_id, revoked_before)
+		VALUES
+			(:user_id, :date_revoked)
+		ON CONFLICT (user_id) DO UPDATE SET
+			revoked_before = EXCLUDED.revoked_before
+	)
+	UPDATE
+		refresh_tokens
+	SET
+		"date_revoked" = :date_revoked
+	WHERE
+		user_id = :user_id AND
+		date_revoked IS NULL`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("revoking userID[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
+// CreateRevoked places an access token on the denylist. Revoking a token
+// that is already on the denylist is not an error.
+func (s *Store) CreateRevoked(ctx context.Context, rvk token.RevokedToken) error {
+	const q = `
+	INSERT INTO revoked_tokens
+		(jti, user_id, date_expires, date_created)
+	VALUES
+		(:jti, :user_id, :date_expires, :date_created)
+	ON CONFLICT (jti) DO NOTHING`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, toDBRevokedToken(rvk)); err != nil {
+		return fmt.Errorf("inserting revoked token: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredRevoked removes the access tokens that expired before the
+// specified time from the denylist.
+func (s *Store) DeleteExpiredRevoked(ctx context.Context, now time.Time) error {
+	data := struct {
+		Now time.Time `db:"now"`
+	}{
+		Now: now.UTC(),
+	}
+
+	const q = `
+	DELETE FROM
+		revoked_tokens
+	WHERE
+		date_expires < :now`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting expired revoked tokens: %w", err)
+	}
+
+	return nil
+}
+
+// IsRevoked reports whether the access token is on the denylist, the family
+// it was issued for has been revoked or the user was revoked after it was
+// issued.
+func (s *Store) IsRevoked(ctx context.Context, jti uuid.UUID, familyID uuid.UUID, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	data := struct {
+		JTI      string    `db:"jti"`
+		FamilyID string    `db:"family_id"`
+		UserID   string    `db:"user_id"`
+		IssuedAt time.Time `db:"issued_at"`
+	}{
+		JTI:      jti.String(),
+		FamilyID: familyID.String(),
+		UserID:   userID.String(),
+		IssuedAt: issuedAt.UTC(),
+	}
+
+	const q = `
+	SELECT
+		EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = :jti) OR
+		EXISTS (SELECT 1 FROM refresh_tokens WHERE family_id = :family_id AND date_revoked IS NOT NULL) OR
+		EXISTS (SELECT 1 FROM revoked_users WHERE user_id = :user_id AND revoked_before >= :issued_at) AS revoked`
+
+	var result struct {
+		Revoked bool `db:"revoked"`
+	}
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &result); err != nil {
+		return false, fmt.Errorf("selecting revoked jti[%s]: %w", jti, err)
+	}
+
+	return result.Revoked, nil
+}
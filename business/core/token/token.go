@@ -0,0 +1,253 @@
+// Package token provides the core business API for managing the refresh
+// tokens and access token revocations that make up a user's sessions.
+//
+// Refresh tokens are rotated on every use. Each rotation keeps the new token
+// in the same family, so a family represents a single login session. If a
+// refresh token is presented after it has already been exchanged, the token
+// has been leaked and the whole family is revoked.
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Set of error variables for token operations.
+var (
+	ErrNotFound     = errors.New("token not found")
+	ErrInvalidToken = errors.New("refresh token is not valid")
+	ErrTokenReused  = errors.New("refresh token has already been used")
+	ErrWrongClient  = errors.New("refresh token was issued to another client")
+)
+
+// RefreshTTL is how long a refresh token can be exchanged for a new one.
+const RefreshTTL = 7 * 24 * time.Hour
+
+// Storer interface declares the behavior this package needs to perists and
+// retrieve data.
+type Storer interface {
+	WithinTran(ctx context.Context, fn func(s Storer) error) error
+	Create(ctx context.Context, rt RefreshToken) error
+	QueryByHashForUpdate(ctx context.Context, hash string) (RefreshToken, error)
+	MarkUsed(ctx context.Context, tokenID uuid.UUID, dateUsed time.Time) error
+	RevokeFamily(ctx context.Context, familyID uuid.UUID, dateRevoked time.Time) error
+	RevokeUser(ctx context.Context, userID uuid.UUID, dateRevoked time.Time) error
+	RevokeClient(ctx context.Context, clientID uuid.UUID, dateRevoked time.Time) error
+	CreateRevoked(ctx context.Context, rvk RevokedToken) error
+	DeleteExpiredRevoked(ctx context.Context, now time.Time) error
+	IsRevoked(ctx context.Context, jti uuid.UUID, familyID uuid.UUID, userID uuid.UUID, issuedAt time.Time) (bool, error)
+}
+
+// Core manages the set of APIs for token access.
+type Core struct {
+	storer Storer
+}
+
+// NewCore constructs a core for token api access.
+func NewCore(storer Storer) *Core {
+	return &Core{
+		storer: storer,
+	}
+}
+
+// Issue creates a refresh token for the specified user that starts a new
+// session. The session is bound to the OAuth client the token is issued to,
+// uuid.Nil when the token isn't issued to a client.
+func (c *Core) Issue(ctx context.Context, userID uuid.UUID, clientID uuid.UUID) (Refresh, error) {
+	rt, tkn, err := newRefreshToken(uuid.New(), userID, clientID, time.Now())
+	if err != nil {
+		return Refresh{}, err
+	}
+
+	if err := c.storer.Create(ctx, rt); err != nil {
+		return Refresh{}, fmt.Errorf("create: %w", err)
+	}
+
+	return toRefresh(rt, tkn), nil
+}
+
+// Rotate exchanges a refresh token for a new one that belongs to the same
+// session. A refresh token can only be exchanged once. When a token that has
+// already been exchanged is presented again, every token in the session is
+// revoked and ErrTokenReused is returned. Only the client the session is bound
+// to can exchange the token, RFC 6749 section 6, otherwise ErrWrongClient is
+// returned and the token can still be used.
+func (c *Core) Rotate(ctx context.Context, token string, clientID uuid.UUID) (Refresh, error) {
+	now := time.Now()
+
+	var refresh Refresh
+	var reused bool
+
+	tran := func(s Storer) error {
+		rt, err := s.QueryByHashForUpdate(ctx, hash(token))
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return ErrInvalidToken
+			}
+			return fmt.Errorf("query: %w", err)
+		}
+
+		switch {
+		case rt.ClientID != clientID:
+			return ErrWrongClient
+
+		case rt.Revoked():
+			return ErrInvalidToken
+
+		case rt.Used():
+
+			// The revocation has to be committed, so the error is reported
+			// once the transaction completes.
+			if err := s.RevokeFamily(ctx, rt.FamilyID, now); err != nil {
+				return fmt.Errorf("revoke family: %w", err)
+			}
+			reused = true
+			return nil
+
+		case !now.Before(rt.DateExpires):
+			return ErrInvalidToken
+		}
+
+		if err := s.MarkUsed(ctx, rt.ID, now); err != nil {
+			return fmt.Errorf("mark used: %w", err)
+		}
+
+		next, tkn, err := newRefreshToken(rt.FamilyID, rt.UserID, rt.ClientID, now)
+		if err != nil {
+			return err
+		}
+
+		if err := s.Create(ctx, next); err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+
+		refresh = toRefresh(next, tkn)
+		return nil
+	}
+
+	if err := c.storer.WithinTran(ctx, tran); err != nil {
+		return Refresh{}, fmt.Errorf("tran: %w", err)
+	}
+
+	if reused {
+		return Refresh{}, ErrTokenReused
+	}
+
+	return refresh, nil
+}
+
+// RevokeSession revokes every refresh token in the specified session. Access
+// tokens issued for the session are rejected from this point on.
+func (c *Core) RevokeSession(ctx context.Context, familyID uuid.UUID) error {
+	if err := c.storer.RevokeFamily(ctx, familyID, time.Now()); err != nil {
+		return fmt.Errorf("revoke family: familyID[%s]: %w", familyID, err)
+	}
+
+	return nil
+}
+
+// RevokeUser revokes every session that belongs to the specified user. Access
+// tokens issued to the user up to now are rejected from this point on, even
+// those that don't belong to a session.
+func (c *Core) RevokeUser(ctx context.Context, userID uuid.UUID) error {
+	if err := c.storer.RevokeUser(ctx, userID, time.Now()); err != nil {
+		return fmt.Errorf("revoke user: userID[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
+// RevokeClient revokes every session of the refresh tokens issued to the
+// specified client. Access tokens issued for those sessions are rejected from
+// this point on.
+func (c *Core) RevokeClient(ctx context.Context, clientID uuid.UUID) error {
+	if err := c.storer.RevokeClient(ctx, clientID, time.Now()); err != nil {
+		return fmt.Errorf("revoke client: clientID[%s]: %w", clientID, err)
+	}
+
+	return nil
+}
+
+// RevokeAccess places the access token with the specified id on the denylist
+// until it expires. Tokens that have expired are rejected anyway, so they are
+// removed from the denylist to keep it small.
+func (c *Core) RevokeAccess(ctx context.Context, jti uuid.UUID, userID uuid.UUID, expires time.Time) error {
+	now := time.Now()
+
+	rvk := RevokedToken{
+		ID:          jti,
+		UserID:      userID,
+		DateExpires: expires,
+		DateCreated: now,
+	}
+
+	if err := c.storer.CreateRevoked(ctx, rvk); err != nil {
+		return fmt.Errorf("create revoked: jti[%s]: %w", jti, err)
+	}
+
+	if err := c.storer.DeleteExpiredRevoked(ctx, now); err != nil {
+		return fmt.Errorf("delete expired revoked: %w", err)
+	}
+
+	return nil
+}
+
+// IsRevoked reports whether the access token with the specified id has been
+// placed on the denylist, the session it was issued for has been revoked or
+// every token of the user issued up to the specified time has been revoked.
+func (c *Core) IsRevoked(ctx context.Context, jti uuid.UUID, familyID uuid.UUID, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	revoked, err := c.storer.IsRevoked(ctx, jti, familyID, userID, issuedAt)
+	if err != nil {
+		return false, fmt.Errorf("is revoked: jti[%s]: %w", jti, err)
+	}
+
+	return revoked, nil
+}
+
+// =============================================================================
+
+// newRefreshToken generates a random token and the record that represents it.
+func newRefreshToken(familyID uuid.UUID, userID uuid.UUID, clientID uuid.UUID, now time.Time) (RefreshToken, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return RefreshToken{}, "", fmt.Errorf("generating token: %w", err)
+	}
+	tkn := base64.RawURLEncoding.EncodeToString(b)
+
+	rt := RefreshToken{
+		ID:          uuid.New(),
+		FamilyID:    familyID,
+		UserID:      userID,
+		ClientID:    clientID,
+		Hash:        hash(tkn),
+		DateCreated: now,
+		DateExpires: now.Add(RefreshTTL),
+	}
+
+	return rt, tkn, nil
+}
+
+// hash returns the value stored for a refresh token. The tokens are random
+// with enough entropy that a plain sha256 is sufficient and it allows a token
+// to be looked up by its hash.
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func toRefresh(rt RefreshToken, token string) Refresh {
+	return Refresh{
+		Token:    token,
+		FamilyID: rt.FamilyID,
+		UserID:   rt.UserID,
+		Expires:  rt.DateExpires,
+	}
+}
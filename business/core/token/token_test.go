@@ -0,0 +1,231 @@
+package token_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/core/token"
+	"github.com/ardanlabs/service/business/core/token/stores/tokendb"
+	"github.com/ardanlabs/service/business/data/dbtest"
+	"github.com/ardanlabs/service/foundation/docker"
+	"github.com/google/uuid"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func Test_Refresh(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testrefresh")
+	defer func() {
+		if r := recover(); r != nil {
+			t.Log(r)
+			t.Error(string(debug.Stack()))
+		}
+		teardown()
+	}()
+
+	core := token.NewCore(tokendb.NewStore(log, db))
+
+	userID := uuid.MustParse("45b5fbd3-755f-4379-8f07-a58d4a30fa2f")
+
+	t.Log("Given the need to rotate refresh tokens.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a refresh token.", testID)
+		{
+			ctx := context.Background()
+
+			first, err := core.Issue(ctx, userID, uuid.Nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to issue a refresh token : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to issue a refresh token.", dbtest.Success, testID)
+
+			if _, err := core.Rotate(ctx, first.Token, uuid.New()); !errors.Is(err, token.ErrWrongClient) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to rotate the refresh token as another client : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to rotate the refresh token as another client.", dbtest.Success, testID)
+
+			second, err := core.Rotate(ctx, first.Token, uuid.Nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to rotate the refresh token : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to rotate the refresh token.", dbtest.Success, testID)
+
+			if second.FamilyID != first.FamilyID || second.Token == first.Token {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, second)
+				t.Logf("\t\tTest %d:\tExp: family %v", testID, first.FamilyID)
+				t.Fatalf("\t%s\tTest %d:\tShould get a new token in the same family.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get a new token in the same family.", dbtest.Success, testID)
+
+			if _, err := core.Rotate(ctx, first.Token, uuid.Nil); !errors.Is(err, token.ErrTokenReused) {
+				t.Fatalf("\t%s\tTest %d:\tShould detect the reuse of a refresh token : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould detect the reuse of a refresh token.", dbtest.Success, testID)
+
+			if _, err := core.Rotate(ctx, second.Token, uuid.Nil); !errors.Is(err, token.ErrInvalidToken) {
+				t.Fatalf("\t%s\tTest %d:\tShould revoke the family on reuse : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould revoke the family on reuse.", dbtest.Success, testID)
+
+			revoked, err := core.IsRevoked(ctx, uuid.New(), first.FamilyID, userID, time.Now())
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to check the session : %s.", dbtest.Failed, testID, err)
+			}
+			if !revoked {
+				t.Fatalf("\t%s\tTest %d:\tShould reject access tokens of a revoked session.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject access tokens of a revoked session.", dbtest.Success, testID)
+		}
+	}
+}
+
+func Test_Revoke(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testrevoke")
+	defer func() {
+		if r := recover(); r != nil {
+			t.Log(r)
+			t.Error(string(debug.Stack()))
+		}
+		teardown()
+	}()
+
+	core := token.NewCore(tokendb.NewStore(log, db))
+
+	userID := uuid.MustParse("45b5fbd3-755f-4379-8f07-a58d4a30fa2f")
+
+	t.Log("Given the need to revoke access tokens and sessions.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen revoking an access token.", testID)
+		{
+			ctx := context.Background()
+
+			refresh, err := core.Issue(ctx, userID, uuid.Nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to issue a refresh token : %s.", dbtest.Failed, testID, err)
+			}
+
+			jti := uuid.New()
+
+			revoked, err := core.IsRevoked(ctx, jti, refresh.FamilyID, userID, time.Now())
+			if err != nil || revoked {
+				t.Fatalf("\t%s\tTest %d:\tShould accept a token that was not revoked : %v : %v.", dbtest.Failed, testID, revoked, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept a token that was not revoked.", dbtest.Success, testID)
+
+			if err := core.RevokeAccess(ctx, jti, userID, time.Now().Add(time.Hour)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke the token : %s.", dbtest.Failed, testID, err)
+			}
+
+			revoked, err = core.IsRevoked(ctx, jti, uuid.Nil, userID, time.Now())
+			if err != nil || !revoked {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a token on the denylist : %v : %v.", dbtest.Failed, testID, revoked, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject a token on the denylist.", dbtest.Success, testID)
+
+			expired := uuid.New()
+			if err := core.RevokeAccess(ctx, expired, userID, time.Now().Add(-time.Minute)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke an expired token : %s.", dbtest.Failed, testID, err)
+			}
+
+			revoked, err = core.IsRevoked(ctx, expired, uuid.Nil, userID, time.Now())
+			if err != nil || revoked {
+				t.Fatalf("\t%s\tTest %d:\tShould purge expired tokens from the denylist : %v : %v.", dbtest.Failed, testID, revoked, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould purge expired tokens from the denylist.", dbtest.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen revoking every session for a user.", testID)
+		{
+			ctx := context.Background()
+
+			refresh, err := core.Issue(ctx, userID, uuid.Nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to issue a refresh token : %s.", dbtest.Failed, testID, err)
+			}
+
+			issuedAt := time.Now().Add(-time.Minute)
+
+			if err := core.RevokeUser(ctx, userID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke the sessions : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to revoke the sessions.", dbtest.Success, testID)
+
+			if _, err := core.Rotate(ctx, refresh.Token, uuid.Nil); !errors.Is(err, token.ErrInvalidToken) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to use a revoked refresh token : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to use a revoked refresh token.", dbtest.Success, testID)
+
+			revoked, err := core.IsRevoked(ctx, uuid.New(), uuid.Nil, userID, issuedAt)
+			if err != nil || !revoked {
+				t.Fatalf("\t%s\tTest %d:\tShould reject an access token without a session issued before the revocation : %v : %v.", dbtest.Failed, testID, revoked, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject an access token without a session issued before the revocation.", dbtest.Success, testID)
+
+			revoked, err = core.IsRevoked(ctx, uuid.New(), uuid.Nil, userID, time.Now().Add(time.Minute))
+			if err != nil || revoked {
+				t.Fatalf("\t%s\tTest %d:\tShould accept an access token issued after the revocation : %v : %v.", dbtest.Failed, testID, revoked, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept an access token issued after the revocation.", dbtest.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen revoking every session of a client.", testID)
+		{
+			ctx := context.Background()
+
+			adminID := uuid.MustParse("5cf37266-3473-4006-984f-9325122678b7")
+			clientID := uuid.New()
+
+			refresh, err := core.Issue(ctx, adminID, clientID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to issue a refresh token to the client : %s.", dbtest.Failed, testID, err)
+			}
+
+			other, err := core.Issue(ctx, adminID, uuid.Nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to issue a refresh token : %s.", dbtest.Failed, testID, err)
+			}
+
+			if err := core.RevokeClient(ctx, clientID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke the sessions of the client : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to revoke the sessions of the client.", dbtest.Success, testID)
+
+			if _, err := core.Rotate(ctx, refresh.Token, clientID); !errors.Is(err, token.ErrInvalidToken) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to use a revoked refresh token : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to use a revoked refresh token.", dbtest.Success, testID)
+
+			revoked, err := core.IsRevoked(ctx, uuid.New(), refresh.FamilyID, adminID, time.Now())
+			if err != nil || !revoked {
+				t.Fatalf("\t%s\tTest %d:\tShould reject an access token of a revoked session : %v : %v.", dbtest.Failed, testID, revoked, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject an access token of a revoked session.", dbtest.Success, testID)
+
+			revoked, err = core.IsRevoked(ctx, uuid.New(), other.FamilyID, adminID, time.Now())
+			if err != nil || revoked {
+				t.Fatalf("\t%s\tTest %d:\tShould accept an access token of a session without the client : %v : %v.", dbtest.Failed, testID, revoked, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept an access token of a session without the client.", dbtest.Success, testID)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package user
+
+import (
+	"fmt"
+	"net/mail"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/validate"
+)
+
+// QueryFilter holds the available fields a query can be filtered on. A nil
+// field is not used as part of the filter.
+type QueryFilter struct {
+	Name             *string       `json:"name" validate:"omitempty,min=3"`
+	Email            *mail.Address `json:"email"`
+	Role             *string       `json:"role"`
+	Enabled          *bool         `json:"enabled"`
+	StartCreatedDate *time.Time    `json:"startCreatedDate"`
+	EndCreatedDate   *time.Time    `json:"endCreatedDate"`
+}
+
+// Validate checks the data in the filter is acceptable.
+func (qf QueryFilter) Validate() error {
+	if err := validate.Check(qf); err != nil {
+		return fmt.Errorf("validate: %w", err)
+	}
+
+	if qf.StartCreatedDate != nil && qf.EndCreatedDate != nil && qf.EndCreatedDate.Before(*qf.StartCreatedDate) {
+		return validate.FieldErrors{
+			{
+				Field: "endCreatedDate",
+				Error: "endCreatedDate must be after startCreatedDate",
+			},
+		}
+	}
+
+	return nil
+}
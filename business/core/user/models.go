@@ -7,15 +7,10 @@ import (
 	"github.com/google/uuid"
 )
 
-// Set of possible roles for a user.
-const (
-	RoleAdmin = "ADMIN"
-	RoleUser  = "USER"
-)
-
 // User represents an individual user.
 type User struct {
 	ID           uuid.UUID    `json:"id"`
+	TenantID     uuid.UUID    `json:"tenantID"`
 	Name         string       `json:"name"`
 	Email        mail.Address `json:"email"`
 	Roles        []string     `json:"roles"`
@@ -25,11 +20,14 @@ type User struct {
 	DateUpdated  time.Time    `json:"dateUpdated"`
 }
 
-// NewUser contains information needed to create a new User.
+// NewUser contains information needed to create a new User. The user is
+// created in the tenant of the caller, only a super admin can choose the
+// tenant.
 type NewUser struct {
+	TenantID        uuid.UUID    `json:"tenantID"`
 	Name            string       `json:"name" validate:"required"`
 	Email           mail.Address `json:"email" validate:"required,email"`
-	Roles           []string     `json:"roles" validate:"required"`
+	Roles           []string     `json:"roles" validate:"required,min=1,dive,required"`
 	Password        string       `json:"password" validate:"required"`
 	PasswordConfirm string       `json:"passwordConfirm" validate:"eqfield=Password"`
 }
@@ -43,7 +41,7 @@ type NewUser struct {
 type UpdateUser struct {
 	Name            *string       `json:"name"`
 	Email           *mail.Address `json:"email" validate:"omitempty,email"`
-	Roles           []string      `json:"roles"`
+	Roles           []string      `json:"roles" validate:"omitempty,min=1,dive,required"`
 	Password        *string       `json:"password"`
 	PasswordConfirm *string       `json:"passwordConfirm" validate:"omitempty,eqfield=Password"`
 	Enabled         *bool         `json:"enabled"`
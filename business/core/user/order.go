@@ -0,0 +1,25 @@
+package user
+
+import "github.com/ardanlabs/service/business/sys/order"
+
+// DefaultOrderBy represents the default way we sort.
+var DefaultOrderBy = order.NewBy(OrderByID, order.ASC)
+
+// Set of fields that the results can be ordered by. These are the names
+// that should be used by the application layer.
+const (
+	OrderByID          = "userID"
+	OrderByName        = "name"
+	OrderByEmail       = "email"
+	OrderByEnabled     = "enabled"
+	OrderByDateCreated = "dateCreated"
+)
+
+// orderByFields is the whitelist of fields that can be ordered by.
+var orderByFields = map[string]bool{
+	OrderByID:          true,
+	OrderByName:        true,
+	OrderByEmail:       true,
+	OrderByEnabled:     true,
+	OrderByDateCreated: true,
+}
@@ -0,0 +1,88 @@
+package userdb
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/ardanlabs/service/business/core/user"
+	"github.com/ardanlabs/service/business/sys/tenant"
+)
+
+// applyFilter adds a WHERE clause to the query that limits it to the tenant
+// in scope and to every field set in the filter. The values are added to data
+// so they are bound as parameters.
+func applyFilter(ctx context.Context, filter user.QueryFilter, data map[string]any, buf *bytes.Buffer) error {
+	wc, err := tenantClauses(ctx, data)
+	if err != nil {
+		return err
+	}
+
+	writeWhere(buf, append(wc, filterClauses(filter, data)...))
+
+	return nil
+}
+
+// tenantClauses returns the predicate that limits a query to the tenant in
+// scope, there is none when every tenant is in scope. The value is added to
+// data so it's bound as a parameter.
+func tenantClauses(ctx context.Context, data map[string]any) ([]string, error) {
+	scope, err := tenant.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if scope.All {
+		return nil, nil
+	}
+
+	data["tenant_id"] = scope.ID
+
+	return []string{"tenant_id = :tenant_id"}, nil
+}
+
+// filterClauses returns the set of predicates for the fields set in the
+// filter. The values are added to data so they are bound as parameters.
+func filterClauses(filter user.QueryFilter, data map[string]any) []string {
+	var wc []string
+
+	if filter.Name != nil {
+		data["name"] = "%" + *filter.Name + "%"
+		wc = append(wc, "name ILIKE :name")
+	}
+
+	if filter.Email != nil {
+		data["email"] = filter.Email.Address
+		wc = append(wc, "email = :email")
+	}
+
+	if filter.Role != nil {
+		data["role"] = *filter.Role
+		wc = append(wc, ":role = ANY(roles)")
+	}
+
+	if filter.Enabled != nil {
+		data["enabled"] = *filter.Enabled
+		wc = append(wc, "enabled = :enabled")
+	}
+
+	if filter.StartCreatedDate != nil {
+		data["start_date_created"] = filter.StartCreatedDate.UTC()
+		wc = append(wc, "date_created >= :start_date_created")
+	}
+
+	if filter.EndCreatedDate != nil {
+		data["end_date_created"] = filter.EndCreatedDate.UTC()
+		wc = append(wc, "date_created <= :end_date_created")
+	}
+
+	return wc
+}
+
+// writeWhere writes the WHERE clause for the set of predicates.
+func writeWhere(buf *bytes.Buffer, wc []string) {
+	if len(wc) > 0 {
+		buf.WriteString(" WHERE ")
+		buf.WriteString(strings.Join(wc, " AND "))
+	}
+}
@@ -13,6 +13,7 @@ import (
 // between the app and the database.
 type dbUser struct {
 	ID           uuid.UUID      `db:"user_id"`
+	TenantID     uuid.UUID      `db:"tenant_id"`
 	Name         string         `db:"name"`
 	Email        string         `db:"email"`
 	Roles        pq.StringArray `db:"roles"`
@@ -25,6 +26,7 @@ type dbUser struct {
 func toDBUser(usr user.User) dbUser {
 	return dbUser{
 		ID:           usr.ID,
+		TenantID:     usr.TenantID,
 		Name:         usr.Name,
 		Email:        usr.Email.Address,
 		Roles:        usr.Roles,
@@ -42,6 +44,7 @@ func toCoreUser(dbUsr dbUser) user.User {
 
 	usr := user.User{
 		ID:           dbUsr.ID,
+		TenantID:     dbUsr.TenantID,
 		Name:         dbUsr.Name,
 		Email:        addr,
 		Roles:        dbUsr.Roles,
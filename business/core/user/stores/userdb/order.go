@@ -0,0 +1,48 @@
+package userdb
+
+import (
+	"fmt"
+
+	"github.com/ardanlabs/service/business/core/user"
+	"github.com/ardanlabs/service/business/sys/order"
+)
+
+var orderByFields = map[string]string{
+	user.OrderByID:          "user_id",
+	user.OrderByName:        "name",
+	user.OrderByEmail:       "email",
+	user.OrderByEnabled:     "enabled",
+	user.OrderByDateCreated: "date_created",
+}
+
+// orderByClause returns the ORDER BY clause for the specified order. The
+// user_id is always added as a tie breaker so the order is stable.
+func orderByClause(orderBy order.By) (string, error) {
+	by, exists := orderByFields[orderBy.Field]
+	if !exists {
+		return "", fmt.Errorf("field %q does not exist: %w", orderBy.Field, user.ErrInvalidOrder)
+	}
+
+	if by == "user_id" {
+		return fmt.Sprintf("user_id %s", orderBy.Direction), nil
+	}
+
+	return fmt.Sprintf("%s %s, user_id %s", by, orderBy.Direction, orderBy.Direction), nil
+}
+
+// cursorKey returns the value of the field the results are ordered by for
+// the specified user. This is the key stored in a keyset cursor.
+func cursorKey(dbUsr dbUser, field string) any {
+	switch field {
+	case user.OrderByName:
+		return dbUsr.Name
+	case user.OrderByEmail:
+		return dbUsr.Email
+	case user.OrderByEnabled:
+		return dbUsr.Enabled
+	case user.OrderByDateCreated:
+		return dbUsr.DateCreated
+	default:
+		return dbUsr.ID.String()
+	}
+}
@@ -10,6 +10,8 @@ import (
 
 	"github.com/ardanlabs/service/business/core/user"
 	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/ardanlabs/service/business/sys/order"
+	"github.com/ardanlabs/service/business/sys/tenant"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
@@ -48,13 +50,18 @@ func (s *Store) WithinTran(ctx context.Context, fn func(s user.Storer) error) er
 	return database.WithinTran(ctx, s.log, s.db.(*sqlx.DB), f)
 }
 
-// Create inserts a new user into the database.
+// Create inserts a new user into the database. The user must belong to a
+// tenant in scope.
 func (s *Store) Create(ctx context.Context, usr user.User) error {
+	if err := inScope(ctx, usr.TenantID); err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+
 	const q = `
 	INSERT INTO users
-		(user_id, name, email, password_hash, roles, enabled, date_created, date_updated)
+		(user_id, tenant_id, name, email, password_hash, roles, enabled, date_created, date_updated)
 	VALUES
-		(:user_id, :name, :email, :password_hash, :roles, :enabled, :date_created, :date_updated)`
+		(:user_id, :tenant_id, :name, :email, :password_hash, :roles, :enabled, :date_created, :date_updated)`
 
 	if err := database.NamedExecContext(ctx, s.log, s.db, q, toDBUser(usr)); err != nil {
 		if errors.Is(err, database.ErrDBDuplicatedEntry) {
@@ -66,19 +73,26 @@ func (s *Store) Create(ctx context.Context, usr user.User) error {
 	return nil
 }
 
-// Update replaces a user document in the database.
+// Update replaces a user document in the database. The user must belong to a
+// tenant in scope and can't be moved to another tenant.
 func (s *Store) Update(ctx context.Context, usr user.User) error {
+	if err := inScope(ctx, usr.TenantID); err != nil {
+		return fmt.Errorf("updating userID[%s]: %w", usr.ID, err)
+	}
+
 	const q = `
 	UPDATE
 		users
-	SET 
+	SET
 		"name" = :name,
 		"email" = :email,
 		"roles" = :roles,
 		"password_hash" = :password_hash,
+		"enabled" = :enabled,
 		"date_updated" = :date_updated
 	WHERE
-		user_id = :user_id`
+		user_id = :user_id AND
+		tenant_id = :tenant_id`
 
 	if err := database.NamedExecContext(ctx, s.log, s.db, q, toDBUser(usr)); err != nil {
 		if errors.Is(err, database.ErrDBDuplicatedEntry) {
@@ -90,19 +104,27 @@ func (s *Store) Update(ctx context.Context, usr user.User) error {
 	return nil
 }
 
-// Delete removes a user from the database.
+// Delete removes a user from the database. The user must belong to a tenant
+// in scope.
 func (s *Store) Delete(ctx context.Context, usr user.User) error {
+	if err := inScope(ctx, usr.TenantID); err != nil {
+		return fmt.Errorf("deleting userID[%s]: %w", usr.ID, err)
+	}
+
 	data := struct {
-		UserID string `db:"user_id"`
+		UserID   string `db:"user_id"`
+		TenantID string `db:"tenant_id"`
 	}{
-		UserID: usr.ID.String(),
+		UserID:   usr.ID.String(),
+		TenantID: usr.TenantID.String(),
 	}
 
 	const q = `
 	DELETE FROM
 		users
 	WHERE
-		user_id = :user_id`
+		user_id = :user_id AND
+		tenant_id = :tenant_id`
 
 	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
 		return fmt.Errorf("deleting userID[%s]: %w", usr.ID, err)
@@ -112,25 +134,29 @@ func (s *Store) Delete(ctx context.Context, usr user.User) error {
 }
 
 // Query retrieves a list of existing users from the database.
-func (s *Store) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]user.User, error) {
-	data := struct {
-		ID          string `db:"user_id"`
-		Name        string `db:"name"`
-		Email       string `db:"email"`
-		Offset      int    `db:"offset"`
-		RowsPerPage int    `db:"rows_per_page"`
-	}{
-		Offset:      (pageNumber - 1) * rowsPerPage,
-		RowsPerPage: rowsPerPage,
+func (s *Store) Query(ctx context.Context, filter user.QueryFilter, orderBy order.By, pageNumber int, rowsPerPage int) ([]user.User, error) {
+	data := map[string]any{
+		"offset":        (pageNumber - 1) * rowsPerPage,
+		"rows_per_page": rowsPerPage,
 	}
 
 	const q = `
 	SELECT
 		*
 	FROM
-		users
-	`
+		users`
+
 	buf := bytes.NewBufferString(q)
+	if err := applyFilter(ctx, filter, data, buf); err != nil {
+		return nil, err
+	}
+
+	orderByClause, err := orderByClause(orderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	buf.WriteString(" ORDER BY " + orderByClause)
 	buf.WriteString(" OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY")
 
 	var usrs []dbUser
@@ -141,24 +167,121 @@ func (s *Store) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]u
 	return toCoreUserSlice(usrs), nil
 }
 
+// Count returns the total number of users that match the filter.
+func (s *Store) Count(ctx context.Context, filter user.QueryFilter) (int, error) {
+	data := map[string]any{}
+
+	const q = `
+	SELECT
+		count(1)
+	FROM
+		users`
+
+	buf := bytes.NewBufferString(q)
+	if err := applyFilter(ctx, filter, data, buf); err != nil {
+		return 0, err
+	}
+
+	var count struct {
+		Count int `db:"count"`
+	}
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, buf.String(), data, &count); err != nil {
+		return 0, fmt.Errorf("selecting users count: %w", err)
+	}
+
+	return count.Count, nil
+}
+
+// QueryAfter retrieves the next set of users after the specified cursor
+// using keyset pagination. An empty cursor starts at the beginning of the
+// collection. The cursor for the following page is returned and is empty
+// when there are no more users.
+func (s *Store) QueryAfter(ctx context.Context, filter user.QueryFilter, orderBy order.By, cursor string, rowsPerPage int) ([]user.User, string, error) {
+	column, exists := orderByFields[orderBy.Field]
+	if !exists {
+		return nil, "", fmt.Errorf("field %q does not exist: %w", orderBy.Field, user.ErrInvalidOrder)
+	}
+
+	cur, err := database.DecodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := cur.Check(orderBy.Field, orderBy.Direction); err != nil {
+		return nil, "", err
+	}
+
+	data := map[string]any{
+		"rows_per_page": rowsPerPage + 1,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		users`
+
+	buf := bytes.NewBufferString(q)
+
+	wc, err := tenantClauses(ctx, data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	wc = append(wc, filterClauses(filter, data)...)
+	if !cur.IsZero() {
+		cur.Bind(data)
+		wc = append(wc, database.KeysetClause(column, "user_id", orderBy.Direction))
+	}
+	writeWhere(buf, wc)
+
+	orderByClause, err := orderByClause(orderBy)
+	if err != nil {
+		return nil, "", err
+	}
+
+	buf.WriteString(" ORDER BY " + orderByClause)
+	buf.WriteString(" FETCH FIRST :rows_per_page ROWS ONLY")
+
+	var usrs []dbUser
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, buf.String(), data, &usrs); err != nil {
+		return nil, "", fmt.Errorf("selecting users: %w", err)
+	}
+
+	// One more row than requested is selected to know if there is another
+	// page without having to run a second query.
+	var next string
+	if len(usrs) > rowsPerPage {
+		usrs = usrs[:rowsPerPage]
+		last := usrs[len(usrs)-1]
+		next = database.NewCursor(orderBy.Field, orderBy.Direction, cursorKey(last, orderBy.Field), last.ID.String()).Encode()
+	}
+
+	return toCoreUserSlice(usrs), next, nil
+}
+
 // QueryByID gets the specified user from the database.
 func (s *Store) QueryByID(ctx context.Context, userID uuid.UUID) (user.User, error) {
-	data := struct {
-		UserID string `db:"user_id"`
-	}{
-		UserID: userID.String(),
+	data := map[string]any{
+		"user_id": userID.String(),
 	}
 
 	const q = `
 	SELECT
 		*
 	FROM
-		users
-	WHERE 
-		user_id = :user_id`
+		users`
+
+	wc, err := tenantClauses(ctx, data)
+	if err != nil {
+		return user.User{}, err
+	}
+
+	buf := bytes.NewBufferString(q)
+	writeWhere(buf, append(wc, "user_id = :user_id"))
 
 	var usr dbUser
-	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &usr); err != nil {
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, buf.String(), data, &usr); err != nil {
 		if errors.Is(err, database.ErrDBNotFound) {
 			return user.User{}, user.ErrNotFound
 		}
@@ -170,22 +293,26 @@ func (s *Store) QueryByID(ctx context.Context, userID uuid.UUID) (user.User, err
 
 // QueryByEmail gets the specified user from the database by email.
 func (s *Store) QueryByEmail(ctx context.Context, email mail.Address) (user.User, error) {
-	data := struct {
-		Email string `db:"email"`
-	}{
-		Email: email.Address,
+	data := map[string]any{
+		"email": email.Address,
 	}
 
 	const q = `
 	SELECT
 		*
 	FROM
-		users
-	WHERE
-		email = :email`
+		users`
+
+	wc, err := tenantClauses(ctx, data)
+	if err != nil {
+		return user.User{}, err
+	}
+
+	buf := bytes.NewBufferString(q)
+	writeWhere(buf, append(wc, "email = :email"))
 
 	var usr dbUser
-	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &usr); err != nil {
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, buf.String(), data, &usr); err != nil {
 		if errors.Is(err, database.ErrDBNotFound) {
 			return user.User{}, user.ErrNotFound
 		}
@@ -194,3 +321,20 @@ func (s *Store) QueryByEmail(ctx context.Context, email mail.Address) (user.User
 
 	return toCoreUser(usr), nil
 }
+
+// =============================================================================
+
+// inScope checks the tenant is in the scope of the context. A user from a
+// tenant that isn't in scope is reported as not found.
+func inScope(ctx context.Context, tenantID uuid.UUID) error {
+	scope, err := tenant.Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !scope.Allows(tenantID) {
+		return user.ErrNotFound
+	}
+
+	return nil
+}
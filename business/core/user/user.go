@@ -8,8 +8,11 @@ import (
 	"errors"
 	"fmt"
 	"net/mail"
+	"sync"
 	"time"
 
+	"github.com/ardanlabs/service/business/sys/order"
+	"github.com/ardanlabs/service/business/sys/tenant"
 	"github.com/ardanlabs/service/business/sys/validate"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
@@ -21,7 +24,9 @@ var (
 	ErrInvalidEmail          = errors.New("email is not valid")
 	ErrUniqueEmail           = errors.New("email is not unique")
 	ErrAuthenticationFailure = errors.New("authentication failed")
+	ErrUserDisabled          = errors.New("user is disabled")
 	ErrInvalidOrder          = errors.New("validating order by")
+	ErrInvalidTenant         = errors.New("tenant is not valid")
 )
 
 // Storer interface declares the behavior this package needs to perists and
@@ -31,29 +36,55 @@ type Storer interface {
 	Create(ctx context.Context, usr User) error
 	Update(ctx context.Context, usr User) error
 	Delete(ctx context.Context, usr User) error
-	Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]User, error)
+	Query(ctx context.Context, filter QueryFilter, orderBy order.By, pageNumber int, rowsPerPage int) ([]User, error)
+	Count(ctx context.Context, filter QueryFilter) (int, error)
+	QueryAfter(ctx context.Context, filter QueryFilter, orderBy order.By, cursor string, rowsPerPage int) ([]User, string, error)
 	QueryByID(ctx context.Context, userID uuid.UUID) (User, error)
 	QueryByEmail(ctx context.Context, email mail.Address) (User, error)
 }
 
-// Core manages the set of APIs for user access.
+// statusTTL is how long a cached enabled status is trusted before it is read
+// from the database again. Updates made through this Core are seen right away,
+// this bounds how long updates made by other instances of the service go
+// unnoticed.
+const statusTTL = time.Minute
+
+// status represents a cached enabled status for a user.
+type status struct {
+	enabled bool
+	expires time.Time
+}
+
+// Core manages the set of APIs for user access. Expired statuses are swept
+// from the cache at most once every statusTTL, so the cache only holds the
+// users that authenticated recently.
 type Core struct {
 	storer Storer
+	mu     sync.RWMutex
+	status map[uuid.UUID]status
+	swept  time.Time
 }
 
 // NewCore constructs a core for user api access.
 func NewCore(storer Storer) *Core {
 	return &Core{
 		storer: storer,
+		status: make(map[uuid.UUID]status),
 	}
 }
 
-// Create inserts a new user into the database.
+// Create inserts a new user into the database. The user is created in the
+// tenant in scope, when every tenant is in scope the tenant must be provided.
 func (c *Core) Create(ctx context.Context, nu NewUser) (User, error) {
 	if err := validate.Check(nu); err != nil {
 		return User{}, fmt.Errorf("validating data: %w", err)
 	}
 
+	tenantID, err := newUserTenant(ctx, nu)
+	if err != nil {
+		return User{}, err
+	}
+
 	hash, err := bcrypt.GenerateFromPassword([]byte(nu.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return User{}, fmt.Errorf("generating password hash: %w", err)
@@ -63,6 +94,7 @@ func (c *Core) Create(ctx context.Context, nu NewUser) (User, error) {
 
 	usr := User{
 		ID:           uuid.New(),
+		TenantID:     tenantID,
 		Name:         nu.Name,
 		Email:        nu.Email,
 		PasswordHash: hash,
@@ -118,6 +150,8 @@ func (c *Core) Update(ctx context.Context, usr User, uu UpdateUser) (User, error
 		return User{}, fmt.Errorf("update: %w", err)
 	}
 
+	c.evictStatus(usr.ID)
+
 	return usr, nil
 }
 
@@ -127,12 +161,23 @@ func (c *Core) Delete(ctx context.Context, usr User) error {
 		return fmt.Errorf("delete: %w", err)
 	}
 
+	c.evictStatus(usr.ID)
+
 	return nil
 }
 
-// Query retrieves a list of existing users from the database.
-func (c *Core) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]User, error) {
-	users, err := c.storer.Query(ctx, pageNumber, rowsPerPage)
+// Query retrieves a list of existing users from the database that match the
+// filter, sorted by the specified order.
+func (c *Core) Query(ctx context.Context, filter QueryFilter, orderBy order.By, pageNumber int, rowsPerPage int) ([]User, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	if !orderByFields[orderBy.Field] {
+		return nil, fmt.Errorf("field[%s]: %w", orderBy.Field, ErrInvalidOrder)
+	}
+
+	users, err := c.storer.Query(ctx, filter, orderBy, pageNumber, rowsPerPage)
 	if err != nil {
 		return nil, fmt.Errorf("query: %w", err)
 	}
@@ -140,6 +185,40 @@ func (c *Core) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]Us
 	return users, nil
 }
 
+// Count returns the total number of users that match the filter.
+func (c *Core) Count(ctx context.Context, filter QueryFilter) (int, error) {
+	if err := filter.Validate(); err != nil {
+		return 0, err
+	}
+
+	count, err := c.storer.Count(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("count: %w", err)
+	}
+
+	return count, nil
+}
+
+// QueryAfter retrieves the next page of users that match the filter after
+// the position held by the cursor. It returns the cursor for the page that
+// follows, which is empty when there are no more users.
+func (c *Core) QueryAfter(ctx context.Context, filter QueryFilter, orderBy order.By, cursor string, rowsPerPage int) ([]User, string, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, "", err
+	}
+
+	if !orderByFields[orderBy.Field] {
+		return nil, "", fmt.Errorf("field[%s]: %w", orderBy.Field, ErrInvalidOrder)
+	}
+
+	users, next, err := c.storer.QueryAfter(ctx, filter, orderBy, cursor, rowsPerPage)
+	if err != nil {
+		return nil, "", fmt.Errorf("query after: %w", err)
+	}
+
+	return users, next, nil
+}
+
 // QueryByID gets the specified user from the database.
 func (c *Core) QueryByID(ctx context.Context, userID uuid.UUID) (User, error) {
 	user, err := c.storer.QueryByID(ctx, userID)
@@ -173,5 +252,77 @@ func (c *Core) Authenticate(ctx context.Context, email mail.Address, password st
 		return User{}, ErrAuthenticationFailure
 	}
 
+	if !usr.Enabled {
+		return User{}, ErrUserDisabled
+	}
+
 	return usr, nil
 }
+
+// IsEnabled reports whether the specified user is allowed to use the system.
+// This is checked on every authenticated request so the answer is cached
+// for a short time.
+func (c *Core) IsEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	c.mu.RLock()
+	st, exists := c.status[userID]
+	c.mu.RUnlock()
+
+	if exists && time.Now().Before(st.expires) {
+		return st.enabled, nil
+	}
+
+	usr, err := c.storer.QueryByID(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("query: %w", err)
+	}
+
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.swept) >= statusTTL {
+		for id, st := range c.status {
+			if !now.Before(st.expires) {
+				delete(c.status, id)
+			}
+		}
+		c.swept = now
+	}
+
+	c.status[userID] = status{
+		enabled: usr.Enabled,
+		expires: now.Add(statusTTL),
+	}
+
+	return usr.Enabled, nil
+}
+
+// newUserTenant returns the tenant a new user is created in.
+func newUserTenant(ctx context.Context, nu NewUser) (uuid.UUID, error) {
+	scope, err := tenant.Get(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	switch {
+	case scope.All && nu.TenantID == uuid.Nil:
+		return uuid.Nil, fmt.Errorf("tenant must be provided: %w", ErrInvalidTenant)
+
+	case scope.All:
+		return nu.TenantID, nil
+
+	case nu.TenantID != uuid.Nil && nu.TenantID != scope.ID:
+		return uuid.Nil, fmt.Errorf("tenant[%s] not in scope: %w", nu.TenantID, ErrInvalidTenant)
+	}
+
+	return scope.ID, nil
+}
+
+// evictStatus removes the cached enabled status for the specified user.
+func (c *Core) evictStatus(userID uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.status, userID)
+}
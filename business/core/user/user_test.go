@@ -9,11 +9,16 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ardanlabs/service/business/core/role"
 	"github.com/ardanlabs/service/business/core/user"
 	"github.com/ardanlabs/service/business/core/user/stores/userdb"
 	"github.com/ardanlabs/service/business/data/dbtest"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/ardanlabs/service/business/sys/order"
+	"github.com/ardanlabs/service/business/sys/tenant"
 	"github.com/ardanlabs/service/foundation/docker"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/uuid"
 )
 
 var c *docker.Container
@@ -47,7 +52,7 @@ func Test_User(t *testing.T) {
 		testID := 0
 		t.Logf("\tTest %d:\tWhen handling a single User.", testID)
 		{
-			ctx := context.Background()
+			ctx := tenant.Set(context.Background(), tenant.Default)
 
 			email, err := mail.ParseAddress("bill@ardanlabs.com")
 			if err != nil {
@@ -58,7 +63,7 @@ func Test_User(t *testing.T) {
 			nu := user.NewUser{
 				Name:            "Bill Kennedy",
 				Email:           *email,
-				Roles:           []string{user.RoleAdmin},
+				Roles:           []string{role.Admin},
 				Password:        "gophers",
 				PasswordConfirm: "gophers",
 			}
@@ -145,6 +150,30 @@ func Test_User(t *testing.T) {
 				t.Logf("\t%s\tTest %d:\tShould be able to see updates to Email.", dbtest.Success, testID)
 			}
 
+			enabled, err := core.IsEnabled(ctx, saved.ID)
+			if err != nil || !enabled {
+				t.Fatalf("\t%s\tTest %d:\tShould see the user as enabled : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould see the user as enabled.", dbtest.Success, testID)
+
+			disabled := false
+			saved, err = core.Update(ctx, saved, user.UpdateUser{Enabled: &disabled})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to disable user : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to disable user.", dbtest.Success, testID)
+
+			enabled, err = core.IsEnabled(ctx, saved.ID)
+			if err != nil || enabled {
+				t.Fatalf("\t%s\tTest %d:\tShould see the user as disabled : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould see the user as disabled.", dbtest.Success, testID)
+
+			if _, err := core.Authenticate(ctx, saved.Email, *upd.Password); !errors.Is(err, user.ErrUserDisabled) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to authenticate a disabled user : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to authenticate a disabled user.", dbtest.Success, testID)
+
 			if err := core.Delete(ctx, saved); err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to delete user : %s.", dbtest.Failed, testID, err)
 			}
@@ -176,33 +205,35 @@ func Test_PagingUser(t *testing.T) {
 		testID := 0
 		t.Logf("\tTest %d:\tWhen paging through 2 users.", testID)
 		{
-			ctx := context.Background()
+			ctx := tenant.Set(context.Background(), tenant.Default)
+
+			orderBy := order.NewBy(user.OrderByName, order.DESC)
 
 			name := "User Gopher"
-			users1, err := core.Query(ctx, 1, 1)
+			users1, err := core.Query(ctx, user.QueryFilter{}, orderBy, 1, 1)
 			if err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve user %q : %s.", dbtest.Failed, testID, name, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould be able to retrieve user %q.", dbtest.Success, testID, name)
 
-			if len(users1) != 1 && users1[0].Name == name {
+			if len(users1) != 1 || users1[0].Name != name {
 				t.Fatalf("\t%s\tTest %d:\tShould have a single user for %q : %s.", dbtest.Failed, testID, name, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould have a single user.", dbtest.Success, testID)
 
 			name = "Admin Gopher"
-			users2, err := core.Query(ctx, 1, 1)
+			users2, err := core.Query(ctx, user.QueryFilter{}, orderBy, 2, 1)
 			if err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve user %q : %s.", dbtest.Failed, testID, name, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould be able to retrieve users %q.", dbtest.Success, testID, name)
 
-			if len(users2) != 1 && users2[0].Name == name {
+			if len(users2) != 1 || users2[0].Name != name {
 				t.Fatalf("\t%s\tTest %d:\tShould have a single user for %q : %s.", dbtest.Failed, testID, name, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould have a single user.", dbtest.Success, testID)
 
-			users3, err := core.Query(ctx, 1, 2)
+			users3, err := core.Query(ctx, user.QueryFilter{}, orderBy, 1, 2)
 			if err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve 2 users for page 1 : %s.", dbtest.Failed, testID, err)
 			}
@@ -224,3 +255,267 @@ func Test_PagingUser(t *testing.T) {
 		}
 	}
 }
+
+func Test_FilterUser(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testfilter")
+	defer func() {
+		if r := recover(); r != nil {
+			t.Log(r)
+			t.Error(string(debug.Stack()))
+		}
+		teardown()
+	}()
+
+	core := user.NewCore(userdb.NewStore(log, db))
+
+	t.Log("Given the need to filter User records.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen filtering by role.", testID)
+		{
+			ctx := tenant.Set(context.Background(), tenant.Default)
+
+			role := role.Admin
+			filter := user.QueryFilter{
+				Role: &role,
+			}
+
+			users, err := core.Query(ctx, filter, user.DefaultOrderBy, 1, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve users : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve users.", dbtest.Success, testID)
+
+			if len(users) != 1 || users[0].Name != "Admin Gopher" {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, users)
+				t.Fatalf("\t%s\tTest %d:\tShould only get the admin user.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only get the admin user.", dbtest.Success, testID)
+
+			count, err := core.Count(ctx, filter)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to count users : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to count users.", dbtest.Success, testID)
+
+			if count != 1 {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, count)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, 1)
+				t.Fatalf("\t%s\tTest %d:\tShould count the same users the filter returns.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould count the same users the filter returns.", dbtest.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen ordering by an unknown field.", testID)
+		{
+			ctx := tenant.Set(context.Background(), tenant.Default)
+
+			_, err := core.Query(ctx, user.QueryFilter{}, order.NewBy("password", order.ASC), 1, 10)
+			if !errors.Is(err, user.ErrInvalidOrder) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to order by an unknown field : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to order by an unknown field.", dbtest.Success, testID)
+		}
+	}
+}
+
+func Test_CursorUser(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testcursor")
+	defer func() {
+		if r := recover(); r != nil {
+			t.Log(r)
+			t.Error(string(debug.Stack()))
+		}
+		teardown()
+	}()
+
+	core := user.NewCore(userdb.NewStore(log, db))
+
+	t.Log("Given the need to page through User records with a cursor.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen paging through 2 users one at a time.", testID)
+		{
+			ctx := tenant.Set(context.Background(), tenant.Default)
+
+			orderBy := order.NewBy(user.OrderByName, order.ASC)
+
+			users1, next, err := core.QueryAfter(ctx, user.QueryFilter{}, orderBy, "", 1)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the first page : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve the first page.", dbtest.Success, testID)
+
+			if len(users1) != 1 || users1[0].Name != "Admin Gopher" || next == "" {
+				t.Logf("\t\tTest %d:\tGot: %v next[%s]", testID, users1, next)
+				t.Fatalf("\t%s\tTest %d:\tShould get the first user and a next cursor.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the first user and a next cursor.", dbtest.Success, testID)
+
+			desc := order.NewBy(user.OrderByName, order.DESC)
+			if _, _, err := core.QueryAfter(ctx, user.QueryFilter{}, desc, next, 1); !errors.Is(err, database.ErrInvalidCursor) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to use the cursor with another direction : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to use the cursor with another direction.", dbtest.Success, testID)
+
+			users2, next, err := core.QueryAfter(ctx, user.QueryFilter{}, orderBy, next, 1)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the second page : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve the second page.", dbtest.Success, testID)
+
+			if len(users2) != 1 || users2[0].Name != "User Gopher" || next != "" {
+				t.Logf("\t\tTest %d:\tGot: %v next[%s]", testID, users2, next)
+				t.Fatalf("\t%s\tTest %d:\tShould get the last user and no next cursor.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the last user and no next cursor.", dbtest.Success, testID)
+
+			if _, _, err := core.QueryAfter(ctx, user.QueryFilter{}, orderBy, "bad-cursor", 1); !errors.Is(err, database.ErrInvalidCursor) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to use an invalid cursor : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to use an invalid cursor.", dbtest.Success, testID)
+		}
+	}
+}
+
+func Test_TenantUser(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testtenant")
+	defer func() {
+		if r := recover(); r != nil {
+			t.Log(r)
+			t.Error(string(debug.Stack()))
+		}
+		teardown()
+	}()
+
+	core := user.NewCore(userdb.NewStore(log, db))
+
+	t.Log("Given the need to keep the users of tenants apart.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a user in another tenant.", testID)
+		{
+			other := uuid.New()
+
+			email, err := mail.ParseAddress("jill@ardanlabs.com")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to parse email: %s.", dbtest.Failed, testID, err)
+			}
+
+			nu := user.NewUser{
+				TenantID:        other,
+				Name:            "Jill Kennedy",
+				Email:           *email,
+				Roles:           []string{role.Admin},
+				Password:        "gophers",
+				PasswordConfirm: "gophers",
+			}
+
+			if _, err := core.Create(tenant.Set(context.Background(), tenant.Default), nu); !errors.Is(err, user.ErrInvalidTenant) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to create a user in another tenant : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to create a user in another tenant.", dbtest.Success, testID)
+
+			usr, err := core.Create(tenant.SetAll(context.Background()), nu)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a user for any tenant : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a user for any tenant.", dbtest.Success, testID)
+
+			ctx := tenant.Set(context.Background(), tenant.Default)
+
+			if _, err := core.QueryByID(ctx, usr.ID); !errors.Is(err, user.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve the user from another tenant : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve the user from another tenant.", dbtest.Success, testID)
+
+			if err := core.Delete(ctx, usr); !errors.Is(err, user.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to delete the user from another tenant : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to delete the user from another tenant.", dbtest.Success, testID)
+
+			count, err := core.Count(ctx, user.QueryFilter{})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to count users : %s.", dbtest.Failed, testID, err)
+			}
+
+			if count != 2 {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, count)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, 2)
+				t.Fatalf("\t%s\tTest %d:\tShould only count the users of the tenant.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only count the users of the tenant.", dbtest.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen the tenant is missing from the context.", testID)
+		{
+			if _, err := core.Count(context.Background(), user.QueryFilter{}); !errors.Is(err, tenant.ErrMissing) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to query users : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to query users.", dbtest.Success, testID)
+		}
+	}
+}
+
+func Test_RowLevelSecurity(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testrls")
+	defer func() {
+		if r := recover(); r != nil {
+			t.Log(r)
+			t.Error(string(debug.Stack()))
+		}
+		teardown()
+	}()
+
+	core := user.NewCore(userdb.NewStore(log, db))
+
+	t.Log("Given the need for the database to restrict the rows a subject can see.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a user queries every user of the tenant.", testID)
+		{
+			ctx := tenant.Set(context.Background(), tenant.Default)
+			ctx = database.SetSession(ctx, database.Session{
+				UserID: "45b5fbd3-755f-4379-8f07-a58d4a30fa2f",
+				Roles:  []string{role.User},
+			})
+
+			users, err := core.Query(ctx, user.QueryFilter{}, user.DefaultOrderBy, 1, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve users : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve users.", dbtest.Success, testID)
+
+			if len(users) != 1 || users[0].Name != "User Gopher" {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, users)
+				t.Fatalf("\t%s\tTest %d:\tShould only get the user's own row.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only get the user's own row.", dbtest.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen an admin queries every user of the tenant.", testID)
+		{
+			ctx := tenant.Set(context.Background(), tenant.Default)
+			ctx = database.SetSession(ctx, database.Session{
+				UserID:      "5cf37266-3473-4006-984f-9325122678b7",
+				Roles:       []string{role.Admin, role.User},
+				Permissions: []string{role.PermUsersRead},
+			})
+
+			count, err := core.Count(ctx, user.QueryFilter{})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to count users : %s.", dbtest.Failed, testID, err)
+			}
+
+			if count != 2 {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, count)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, 2)
+				t.Fatalf("\t%s\tTest %d:\tShould get every user of the tenant.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get every user of the tenant.", dbtest.Success, testID)
+		}
+	}
+}
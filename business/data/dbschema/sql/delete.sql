@@ -1,3 +1,9 @@
+DELETE FROM audit_log;
+DELETE FROM api_keys;
+DELETE FROM oauth_clients;
+DELETE FROM revoked_users;
+DELETE FROM revoked_tokens;
+DELETE FROM refresh_tokens;
 DELETE FROM sales;
 DELETE FROM products;
 DELETE FROM users;
\ No newline at end of file
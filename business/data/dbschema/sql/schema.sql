@@ -42,3 +42,299 @@ CREATE TABLE sales (
 	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
 	FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
 );
+
+-- Version: 1.04
+-- Description: Create table refresh_tokens
+CREATE TABLE refresh_tokens (
+	token_id     UUID,
+	family_id    UUID,
+	user_id      UUID,
+	token_hash   TEXT UNIQUE,
+	date_created TIMESTAMP,
+	date_expires TIMESTAMP,
+	date_used    TIMESTAMP NULL,
+	date_revoked TIMESTAMP NULL,
+
+	PRIMARY KEY (token_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+-- Version: 1.05
+-- Description: Create table revoked_tokens
+CREATE TABLE revoked_tokens (
+	jti          UUID,
+	user_id      UUID,
+	date_expires TIMESTAMP,
+	date_created TIMESTAMP,
+
+	PRIMARY KEY (jti)
+);
+
+-- Version: 1.06
+-- Description: Add tenant_id to users, products and sales
+ALTER TABLE users ADD COLUMN tenant_id UUID NOT NULL DEFAULT 'c6f8c3a2-3b8e-4f1e-9d0a-1f6b7a2e4d10';
+ALTER TABLE users ALTER COLUMN tenant_id DROP DEFAULT;
+CREATE INDEX users_tenant_id_idx ON users (tenant_id);
+
+ALTER TABLE products ADD COLUMN tenant_id UUID NOT NULL DEFAULT 'c6f8c3a2-3b8e-4f1e-9d0a-1f6b7a2e4d10';
+ALTER TABLE products ALTER COLUMN tenant_id DROP DEFAULT;
+CREATE INDEX products_tenant_id_idx ON products (tenant_id);
+
+ALTER TABLE sales ADD COLUMN tenant_id UUID NOT NULL DEFAULT 'c6f8c3a2-3b8e-4f1e-9d0a-1f6b7a2e4d10';
+ALTER TABLE sales ALTER COLUMN tenant_id DROP DEFAULT;
+CREATE INDEX sales_tenant_id_idx ON sales (tenant_id);
+
+-- Version: 1.07
+-- Description: Create the application role and row level security policies
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'sales_app') THEN
+		CREATE ROLE sales_app NOLOGIN;
+	END IF;
+EXCEPTION
+	WHEN duplicate_object THEN NULL;
+END
+$$;
+
+DO $$
+BEGIN
+	EXECUTE format('GRANT sales_app TO %I', current_user);
+	EXECUTE format('GRANT USAGE ON SCHEMA %I TO sales_app', current_schema());
+	EXECUTE format('GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA %I TO sales_app', current_schema());
+END
+$$;
+
+ALTER DEFAULT PRIVILEGES GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO sales_app;
+
+CREATE FUNCTION app_tenant_allowed(tenant UUID) RETURNS BOOLEAN AS $$
+	SELECT current_setting('app.all_tenants', true) = 'true' OR
+		tenant::TEXT = current_setting('app.current_tenant', true)
+$$ LANGUAGE SQL STABLE;
+
+CREATE FUNCTION app_is_admin() RETURNS BOOLEAN AS $$
+	SELECT string_to_array(current_setting('app.roles', true), ',') && ARRAY['ADMIN', 'SUPER_ADMIN']
+$$ LANGUAGE SQL STABLE;
+
+CREATE FUNCTION app_is_current_user(id UUID) RETURNS BOOLEAN AS $$
+	SELECT id::TEXT = current_setting('app.current_user', true)
+$$ LANGUAGE SQL STABLE;
+
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+CREATE POLICY users_access ON users
+	USING (app_tenant_allowed(tenant_id) AND (app_is_admin() OR app_is_current_user(user_id)));
+
+ALTER TABLE products ENABLE ROW LEVEL SECURITY;
+CREATE POLICY products_select ON products FOR SELECT
+	USING (app_tenant_allowed(tenant_id));
+CREATE POLICY products_insert ON products FOR INSERT
+	WITH CHECK (app_tenant_allowed(tenant_id) AND (app_is_admin() OR app_is_current_user(user_id)));
+CREATE POLICY products_update ON products FOR UPDATE
+	USING (app_tenant_allowed(tenant_id) AND (app_is_admin() OR app_is_current_user(user_id)));
+CREATE POLICY products_delete ON products FOR DELETE
+	USING (app_tenant_allowed(tenant_id) AND (app_is_admin() OR app_is_current_user(user_id)));
+
+ALTER TABLE sales ENABLE ROW LEVEL SECURITY;
+CREATE POLICY sales_access ON sales
+	USING (app_tenant_allowed(tenant_id));
+
+-- Version: 1.08
+-- Description: Create tables for roles and permissions and authorize rows by permission
+CREATE TABLE permissions (
+	name        TEXT,
+	description TEXT,
+
+	PRIMARY KEY (name)
+);
+
+CREATE TABLE roles (
+	name         TEXT,
+	description  TEXT,
+	date_created TIMESTAMP,
+	date_updated TIMESTAMP,
+
+	PRIMARY KEY (name)
+);
+
+CREATE TABLE role_permissions (
+	role_name  TEXT,
+	permission TEXT,
+
+	PRIMARY KEY (role_name, permission),
+	FOREIGN KEY (role_name) REFERENCES roles(name) ON DELETE CASCADE,
+	FOREIGN KEY (permission) REFERENCES permissions(name) ON DELETE CASCADE
+);
+
+INSERT INTO permissions (name, description) VALUES
+	('users:read', 'Read every user of the tenant'),
+	('users:write', 'Create, change and remove every user of the tenant'),
+	('products:write', 'Change and remove every product of the tenant'),
+	('reports:read', 'Read the reports of the tenant'),
+	('roles:read', 'Read the roles and permissions'),
+	('roles:write', 'Create, change and remove roles');
+
+INSERT INTO roles (name, description, date_created, date_updated) VALUES
+	('SUPER_ADMIN', 'Platform operator with access to every tenant', now(), now()),
+	('ADMIN', 'Administrator of a tenant', now(), now()),
+	('USER', 'User of a tenant', now(), now());
+
+INSERT INTO role_permissions (role_name, permission) VALUES
+	('SUPER_ADMIN', 'users:read'),
+	('SUPER_ADMIN', 'users:write'),
+	('SUPER_ADMIN', 'products:write'),
+	('SUPER_ADMIN', 'reports:read'),
+	('SUPER_ADMIN', 'roles:read'),
+	('SUPER_ADMIN', 'roles:write'),
+	('ADMIN', 'users:read'),
+	('ADMIN', 'users:write'),
+	('ADMIN', 'products:write'),
+	('ADMIN', 'reports:read'),
+	('ADMIN', 'roles:read');
+
+CREATE FUNCTION app_has_permission(permission TEXT) RETURNS BOOLEAN AS $$
+	SELECT permission = ANY(string_to_array(current_setting('app.permissions', true), ','))
+$$ LANGUAGE SQL STABLE;
+
+DROP POLICY users_access ON users;
+CREATE POLICY users_select ON users FOR SELECT
+	USING (app_tenant_allowed(tenant_id) AND (app_has_permission('users:read') OR app_is_current_user(user_id)));
+CREATE POLICY users_insert ON users FOR INSERT
+	WITH CHECK (app_tenant_allowed(tenant_id) AND app_has_permission('users:write'));
+CREATE POLICY users_update ON users FOR UPDATE
+	USING (app_tenant_allowed(tenant_id) AND (app_has_permission('users:write') OR app_is_current_user(user_id)));
+CREATE POLICY users_delete ON users FOR DELETE
+	USING (app_tenant_allowed(tenant_id) AND (app_has_permission('users:write') OR app_is_current_user(user_id)));
+
+DROP POLICY products_insert ON products;
+DROP POLICY products_update ON products;
+DROP POLICY products_delete ON products;
+CREATE POLICY products_insert ON products FOR INSERT
+	WITH CHECK (app_tenant_allowed(tenant_id) AND (app_has_permission('products:write') OR app_is_current_user(user_id)));
+CREATE POLICY products_update ON products FOR UPDATE
+	USING (app_tenant_allowed(tenant_id) AND (app_has_permission('products:write') OR app_is_current_user(user_id)));
+CREATE POLICY products_delete ON products FOR DELETE
+	USING (app_tenant_allowed(tenant_id) AND (app_has_permission('products:write') OR app_is_current_user(user_id)));
+
+DROP FUNCTION app_is_admin();
+
+-- Version: 1.09
+-- Description: Create table audit_log and the permissions for impersonation and auditing
+CREATE TABLE audit_log (
+	audit_id     UUID,
+	tenant_id    UUID,
+	trace_id     TEXT,
+	subject      TEXT,
+	actor        TEXT NULL,
+	method       TEXT,
+	route        TEXT,
+	path         TEXT,
+	status_code  INT,
+	date_created TIMESTAMP,
+
+	PRIMARY KEY (audit_id)
+);
+
+CREATE INDEX audit_log_tenant_id_date_created_idx ON audit_log (tenant_id, date_created);
+
+ALTER TABLE audit_log ENABLE ROW LEVEL SECURITY;
+CREATE POLICY audit_log_access ON audit_log
+	USING (app_tenant_allowed(tenant_id));
+
+INSERT INTO permissions (name, description) VALUES
+	('users:impersonate', 'Act as another user of the tenant'),
+	('audit:read', 'Read the audit records of the tenant');
+
+INSERT INTO role_permissions (role_name, permission) VALUES
+	('SUPER_ADMIN', 'users:impersonate'),
+	('SUPER_ADMIN', 'audit:read'),
+	('ADMIN', 'users:impersonate'),
+	('ADMIN', 'audit:read');
+
+-- Version: 1.10
+-- Description: Create table api_keys
+CREATE TABLE api_keys (
+	key_id         UUID,
+	tenant_id      UUID,
+	user_id        UUID,
+	name           TEXT,
+	prefix         TEXT,
+	key_hash       TEXT UNIQUE,
+	permissions    TEXT[],
+	allowed_cidrs  TEXT[],
+	date_created   TIMESTAMP,
+	date_expires   TIMESTAMP NULL,
+	date_last_used TIMESTAMP NULL,
+	date_revoked   TIMESTAMP NULL,
+
+	PRIMARY KEY (key_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX api_keys_user_id_idx ON api_keys (user_id);
+
+ALTER TABLE api_keys ENABLE ROW LEVEL SECURITY;
+CREATE POLICY api_keys_select ON api_keys FOR SELECT
+	USING (app_tenant_allowed(tenant_id) AND (app_has_permission('users:read') OR app_is_current_user(user_id)));
+CREATE POLICY api_keys_insert ON api_keys FOR INSERT
+	WITH CHECK (app_tenant_allowed(tenant_id) AND (app_has_permission('users:write') OR app_is_current_user(user_id)));
+CREATE POLICY api_keys_update ON api_keys FOR UPDATE
+	USING (app_tenant_allowed(tenant_id) AND (app_has_permission('users:write') OR app_is_current_user(user_id)));
+
+-- Version: 1.11
+-- Description: Create table oauth_clients
+CREATE TABLE oauth_clients (
+	client_id    UUID,
+	tenant_id    UUID,
+	user_id      UUID,
+	name         TEXT,
+	secret_hash  TEXT,
+	permissions  TEXT[],
+	date_created TIMESTAMP,
+	date_updated TIMESTAMP,
+
+	PRIMARY KEY (client_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+ALTER TABLE oauth_clients ENABLE ROW LEVEL SECURITY;
+CREATE POLICY oauth_clients_select ON oauth_clients FOR SELECT
+	USING (app_tenant_allowed(tenant_id) AND app_has_permission('users:read'));
+CREATE POLICY oauth_clients_insert ON oauth_clients FOR INSERT
+	WITH CHECK (app_tenant_allowed(tenant_id) AND app_has_permission('users:write'));
+CREATE POLICY oauth_clients_delete ON oauth_clients FOR DELETE
+	USING (app_tenant_allowed(tenant_id) AND app_has_permission('users:write'));
+
+-- Version: 1.12
+-- Description: Index revoked_tokens by expiry so expired tokens can be purged
+CREATE INDEX revoked_tokens_date_expires_idx ON revoked_tokens (date_expires);
+
+-- Version: 1.13
+-- Description: Bind refresh tokens to the OAuth client they were issued to
+ALTER TABLE refresh_tokens ADD COLUMN client_id UUID NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE;
+
+-- Version: 1.14
+-- Description: Create table revoked_users to reject the access tokens of a user issued before a revocation
+CREATE TABLE revoked_users (
+	user_id        UUID,
+	revoked_before TIMESTAMP,
+
+	PRIMARY KEY (user_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Version: 1.15
+-- Description: Let any user of the tenant decrement stock when recording a sale
+CREATE FUNCTION app_sell_stock(product UUID, sold INT, updated TIMESTAMP)
+RETURNS TABLE (product_id UUID, cost INT, quantity INT, date_updated TIMESTAMP) AS $$
+	UPDATE products AS p SET
+		quantity = p.quantity - sold,
+		date_updated = updated
+	WHERE
+		p.product_id = product AND app_tenant_allowed(p.tenant_id) AND p.quantity >= sold
+	RETURNING p.product_id, p.cost, p.quantity, p.date_updated
+$$ LANGUAGE SQL SECURITY DEFINER SET search_path FROM CURRENT;
+
+-- Version: 1.16
+-- Description: Keep the refresh tokens of a deleted client so their sessions stay revoked
+ALTER TABLE refresh_tokens DROP CONSTRAINT refresh_tokens_client_id_fkey;
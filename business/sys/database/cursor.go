@@ -0,0 +1,128 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned when a cursor can't be decoded or doesn't
+// match the query it's being used with.
+var ErrInvalidCursor = errors.New("cursor is not valid")
+
+// Cursor represents the position of the last row returned by a keyset
+// paginated query. Field is the name of the field the results are ordered
+// by and Direction is the direction they are ordered in, Key is the value of
+// that field and ID is the value of the unique column used to break ties
+// between rows with the same Key.
+type Cursor struct {
+	Field     string `json:"f"`
+	Direction string `json:"d"`
+	Key       string `json:"k"`
+	ID        string `json:"i"`
+}
+
+// NewCursor constructs a cursor for the specified row values. Time values are
+// formatted so they can be compared by the database without losing precision.
+func NewCursor(field string, direction string, key any, id string) Cursor {
+	var k string
+	switch v := key.(type) {
+	case time.Time:
+		k = v.UTC().Format(time.RFC3339Nano)
+	default:
+		k = fmt.Sprint(v)
+	}
+
+	return Cursor{
+		Field:     field,
+		Direction: strings.ToUpper(direction),
+		Key:       k,
+		ID:        id,
+	}
+}
+
+// Encode returns the cursor as an opaque string that is safe to use in a URL.
+func (c Cursor) Encode() string {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor converts an opaque string produced by Encode back into a
+// Cursor. An empty string is treated as the start of the collection and
+// returns a zero value Cursor.
+func DecodeCursor(s string) (Cursor, error) {
+	if s == "" {
+		return Cursor{}, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("decoding: %w", ErrInvalidCursor)
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Cursor{}, fmt.Errorf("unmarshal: %w", ErrInvalidCursor)
+	}
+
+	if c.Field == "" || c.ID == "" {
+		return Cursor{}, fmt.Errorf("missing values: %w", ErrInvalidCursor)
+	}
+
+	if c.Direction != "ASC" && c.Direction != "DESC" {
+		return Cursor{}, fmt.Errorf("direction[%s]: %w", c.Direction, ErrInvalidCursor)
+	}
+
+	return c, nil
+}
+
+// Check validates the cursor was issued for a query ordered by the specified
+// field and direction. Using a cursor with a different order would silently
+// return the wrong page. A zero value cursor matches every order.
+func (c Cursor) Check(field string, direction string) error {
+	if c.IsZero() {
+		return nil
+	}
+
+	if c.Field != field || c.Direction != strings.ToUpper(direction) {
+		return fmt.Errorf("issued for %s %s: %w", c.Field, c.Direction, ErrInvalidCursor)
+	}
+
+	return nil
+}
+
+// IsZero reports whether the cursor represents the start of the collection.
+func (c Cursor) IsZero() bool {
+	return c == Cursor{}
+}
+
+// Bind adds the cursor values to the named query data using the parameter
+// names expected by KeysetClause.
+func (c Cursor) Bind(data map[string]any) {
+	data["cursor_key"] = c.Key
+	data["cursor_id"] = c.ID
+}
+
+// KeysetClause returns the predicate that selects the rows after a cursor
+// for a query ordered by column and then idColumn in the specified direction.
+// The cursor values must be bound to the query with Cursor.Bind. The query's
+// ORDER BY must use the same columns and direction for the results to be
+// correct.
+func KeysetClause(column string, idColumn string, direction string) string {
+	op := ">"
+	if strings.EqualFold(direction, "DESC") {
+		op = "<"
+	}
+
+	if column == idColumn {
+		return fmt.Sprintf("%s %s :cursor_id", idColumn, op)
+	}
+
+	return fmt.Sprintf("(%s, %s) %s (:cursor_key, :cursor_id)", column, idColumn, op)
+}
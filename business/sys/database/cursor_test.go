@@ -0,0 +1,74 @@
+package database_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/sys/database"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_Cursor(t *testing.T) {
+	t.Log("Given the need to page through rows with an opaque cursor.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen encoding and decoding a cursor.", testID)
+		{
+			created := time.Date(2026, 10, 17, 4, 33, 38, 123456789, time.UTC)
+			cur := database.NewCursor("dateCreated", "desc", created, "5cf37266-3473-4006-984f-9325122678b7")
+
+			got, err := database.DecodeCursor(cur.Encode())
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to decode the cursor : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to decode the cursor.", success, testID)
+
+			if got != cur || got.Direction != "DESC" || got.Key != "2026-10-17T04:33:38.123456789Z" {
+				t.Logf("\t\tTest %d:\tGot: %+v", testID, got)
+				t.Logf("\t\tTest %d:\tExp: %+v", testID, cur)
+				t.Fatalf("\t%s\tTest %d:\tShould get back the same cursor.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the same cursor.", success, testID)
+
+			if err := got.Check("dateCreated", "DESC"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould match the order it was issued for : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould match the order it was issued for.", success, testID)
+
+			if err := got.Check("dateCreated", "ASC"); !errors.Is(err, database.ErrInvalidCursor) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT match another direction : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT match another direction.", success, testID)
+
+			if err := got.Check("name", "DESC"); !errors.Is(err, database.ErrInvalidCursor) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT match another field : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT match another field.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen decoding cursors that aren't valid.", testID)
+		{
+			noDirection := database.Cursor{Field: "name", Key: "a", ID: "b"}
+
+			for _, s := range []string{"bad-cursor", "e30", noDirection.Encode()} {
+				if _, err := database.DecodeCursor(s); !errors.Is(err, database.ErrInvalidCursor) {
+					t.Fatalf("\t%s\tTest %d:\tShould NOT be able to decode %q : %v.", failed, testID, s, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to decode the cursors.", success, testID)
+
+			cur, err := database.DecodeCursor("")
+			if err != nil || !cur.IsZero() || cur.Check("name", "ASC") != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould treat an empty cursor as the start : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould treat an empty cursor as the start.", success, testID)
+		}
+	}
+}
@@ -106,7 +106,8 @@ func StatusCheck(ctx context.Context, db *sqlx.DB) error {
 	return db.QueryRowContext(ctx, q).Scan(&tmp)
 }
 
-// WithinTran runs passed function and do commit/rollback at the end.
+// WithinTran runs passed function and do commit/rollback at the end. When the
+// context carries a session it's applied at the start of the transaction.
 func WithinTran(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, fn func(*sqlx.Tx) error) error {
 	traceID := web.GetTraceID(ctx)
 
@@ -128,6 +129,12 @@ func WithinTran(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, fn fun
 		log.Infow("rollback tran", "trace_id", traceID)
 	}()
 
+	if s, ok := GetSession(ctx); ok {
+		if err := applySession(ctx, tx, s); err != nil {
+			return err
+		}
+	}
+
 	if err := fn(tx); err != nil {
 		if pqerr, ok := err.(*pq.Error); ok && pqerr.Code == uniqueViolation {
 			return ErrDBDuplicatedEntry
@@ -160,7 +167,12 @@ func NamedExecContext(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtCo
 		log.WithOptions(zap.AddCallerSkip(2)).Infow("database.NamedExecContext", "trace_id", web.GetTraceID(ctx), "query", q)
 	}
 
-	if _, err := sqlx.NamedExecContext(ctx, db, query, data); err != nil {
+	exec := func(db sqlx.ExtContext) error {
+		_, err := sqlx.NamedExecContext(ctx, db, query, data)
+		return err
+	}
+
+	if err := withSession(ctx, db, exec); err != nil {
 		if pqerr, ok := err.(*pq.Error); ok {
 			switch pqerr.Code {
 			case undefinedTable:
@@ -204,49 +216,53 @@ func namedQuerySlice[T any](ctx context.Context, log *zap.SugaredLogger, db sqlx
 		log.WithOptions(zap.AddCallerSkip(2)).Infow("database.NamedQuerySlice", "trace_id", web.GetTraceID(ctx), "query", q)
 	}
 
-	var rows *sqlx.Rows
-	var err error
+	fn := func(db sqlx.ExtContext) error {
+		var rows *sqlx.Rows
+		var err error
 
-	switch withIn {
-	case true:
-		rows, err = func() (*sqlx.Rows, error) {
-			named, args, err := sqlx.Named(query, data)
-			if err != nil {
-				return nil, err
-			}
-
-			query, args, err := sqlx.In(named, args...)
-			if err != nil {
-				return nil, err
-			}
+		switch withIn {
+		case true:
+			rows, err = func() (*sqlx.Rows, error) {
+				named, args, err := sqlx.Named(query, data)
+				if err != nil {
+					return nil, err
+				}
 
-			query = db.Rebind(query)
-			return db.QueryxContext(ctx, query, args...)
-		}()
+				query, args, err := sqlx.In(named, args...)
+				if err != nil {
+					return nil, err
+				}
 
-	default:
-		rows, err = sqlx.NamedQueryContext(ctx, db, query, data)
-	}
+				query = db.Rebind(query)
+				return db.QueryxContext(ctx, query, args...)
+			}()
 
-	if err != nil {
-		if pqerr, ok := err.(*pq.Error); ok && pqerr.Code == undefinedTable {
-			return ErrUndefinedTable
+		default:
+			rows, err = sqlx.NamedQueryContext(ctx, db, query, data)
 		}
-		return err
-	}
-	defer rows.Close()
 
-	var slice []T
-	for rows.Next() {
-		v := new(T)
-		if err := rows.StructScan(v); err != nil {
+		if err != nil {
+			if pqerr, ok := err.(*pq.Error); ok && pqerr.Code == undefinedTable {
+				return ErrUndefinedTable
+			}
 			return err
 		}
-		slice = append(slice, *v)
+		defer rows.Close()
+
+		var slice []T
+		for rows.Next() {
+			v := new(T)
+			if err := rows.StructScan(v); err != nil {
+				return err
+			}
+			slice = append(slice, *v)
+		}
+		*dest = slice
+
+		return nil
 	}
-	*dest = slice
 
-	return nil
+	return withSession(ctx, db, fn)
 }
 
 // QueryStruct is a helper function for executing queries that return a
@@ -277,47 +293,51 @@ func namedQueryStruct(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtCo
 		log.WithOptions(zap.AddCallerSkip(2)).Infow("database.NamedQueryStruct", "trace_id", web.GetTraceID(ctx), "query", q)
 	}
 
-	var rows *sqlx.Rows
-	var err error
+	fn := func(db sqlx.ExtContext) error {
+		var rows *sqlx.Rows
+		var err error
 
-	switch withIn {
-	case true:
-		rows, err = func() (*sqlx.Rows, error) {
-			named, args, err := sqlx.Named(query, data)
-			if err != nil {
-				return nil, err
-			}
+		switch withIn {
+		case true:
+			rows, err = func() (*sqlx.Rows, error) {
+				named, args, err := sqlx.Named(query, data)
+				if err != nil {
+					return nil, err
+				}
 
-			query, args, err := sqlx.In(named, args...)
-			if err != nil {
-				return nil, err
-			}
+				query, args, err := sqlx.In(named, args...)
+				if err != nil {
+					return nil, err
+				}
 
-			query = db.Rebind(query)
-			return db.QueryxContext(ctx, query, args...)
-		}()
+				query = db.Rebind(query)
+				return db.QueryxContext(ctx, query, args...)
+			}()
 
-	default:
-		rows, err = sqlx.NamedQueryContext(ctx, db, query, data)
-	}
+		default:
+			rows, err = sqlx.NamedQueryContext(ctx, db, query, data)
+		}
 
-	if err != nil {
-		if pqerr, ok := err.(*pq.Error); ok && pqerr.Code == undefinedTable {
-			return ErrUndefinedTable
+		if err != nil {
+			if pqerr, ok := err.(*pq.Error); ok && pqerr.Code == undefinedTable {
+				return ErrUndefinedTable
+			}
+			return err
 		}
-		return err
-	}
-	defer rows.Close()
+		defer rows.Close()
 
-	if !rows.Next() {
-		return ErrDBNotFound
-	}
+		if !rows.Next() {
+			return ErrDBNotFound
+		}
 
-	if err := rows.StructScan(dest); err != nil {
-		return err
+		if err := rows.StructScan(dest); err != nil {
+			return err
+		}
+
+		return nil
 	}
 
-	return nil
+	return withSession(ctx, db, fn)
 }
 
 // queryString provides a pretty print version of the query and parameters.
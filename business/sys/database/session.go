@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ardanlabs/service/business/sys/tenant"
+	"github.com/jmoiron/sqlx"
+)
+
+// sessionRole is the role statements run as when the context carries a
+// session. The role isn't the owner of the tables and can't bypass row level
+// security, so the policies defined by the migrations apply.
+const sessionRole = "sales_app"
+
+// Session represents the identity the database enforces row level security
+// for. The tenant comes from the tenant scope in the context.
+type Session struct {
+	UserID      string
+	Roles       []string
+	Permissions []string
+}
+
+// ctxKey represents the type of value for the context key.
+type ctxKey int
+
+// key is used to store/retrieve a Session value from a context.Context.
+const key ctxKey = 1
+
+// SetSession stores the session in the context. Every statement executed
+// with the context runs inside a transaction that carries the session.
+func SetSession(ctx context.Context, s Session) context.Context {
+	return context.WithValue(ctx, key, s)
+}
+
+// GetSession returns the session from the context.
+func GetSession(ctx context.Context) (Session, bool) {
+	s, ok := ctx.Value(key).(Session)
+	return s, ok
+}
+
+// withSession runs the function against a transaction that carries the
+// session from the context. A transaction started by WithinTran already
+// carries the session, as does a context without a session, so the function
+// runs against the provided db.
+func withSession(ctx context.Context, db sqlx.ExtContext, fn func(db sqlx.ExtContext) error) error {
+	s, ok := GetSession(ctx)
+	if !ok {
+		return fn(db)
+	}
+
+	sdb, ok := db.(*sqlx.DB)
+	if !ok {
+		return fn(db)
+	}
+
+	tx, err := sdb.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin session tran: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := applySession(ctx, tx, s); err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit session tran: %w", err)
+	}
+
+	return nil
+}
+
+// applySession sets the session variables the row level security policies
+// use and switches to the role the policies apply to. Both only last until
+// the end of the transaction so nothing leaks to the next use of the
+// connection.
+func applySession(ctx context.Context, tx *sqlx.Tx, s Session) error {
+	var tenantID string
+	var allTenants bool
+
+	if scope, err := tenant.Get(ctx); err == nil {
+		allTenants = scope.All
+		if !scope.All {
+			tenantID = scope.ID.String()
+		}
+	}
+
+	const q = `
+	SELECT
+		set_config('app.current_user', $1, true),
+		set_config('app.roles', $2, true),
+		set_config('app.current_tenant', $3, true),
+		set_config('app.all_tenants', $4, true),
+		set_config('app.permissions', $5, true)`
+
+	args := []any{
+		s.UserID,
+		strings.Join(s.Roles, ","),
+		tenantID,
+		fmt.Sprint(allTenants),
+		strings.Join(s.Permissions, ","),
+	}
+
+	if _, err := tx.ExecContext(ctx, q, args...); err != nil {
+		return fmt.Errorf("setting session: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "SET LOCAL ROLE "+sessionRole); err != nil {
+		return fmt.Errorf("setting session role: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+// Package order provides support for describing the ordering of data.
+package order
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Set of directions for data ordering.
+const (
+	ASC  = "ASC"
+	DESC = "DESC"
+)
+
+var directions = map[string]string{
+	ASC:  "ASC",
+	DESC: "DESC",
+}
+
+// ErrInvalidDirection is returned when an unknown direction is provided.
+var ErrInvalidDirection = errors.New("direction is not valid")
+
+// =============================================================================
+
+// By represents a field used to order by and direction.
+type By struct {
+	Field     string
+	Direction string
+}
+
+// NewBy constructs a new By value with no checks.
+func NewBy(field string, direction string) By {
+	return By{
+		Field:     field,
+		Direction: direction,
+	}
+}
+
+// Parse constructs a By value by parsing a string in the form of
+// "field,direction". The direction is optional and defaults to ASC. If the
+// string is empty, the default order is returned. Checking the field is a
+// valid choice is left to the caller since only it knows the set of fields.
+func Parse(orderBy string, defaultOrder By) (By, error) {
+	if orderBy == "" {
+		return defaultOrder, nil
+	}
+
+	parts := strings.Split(orderBy, ",")
+
+	field := strings.TrimSpace(parts[0])
+	if field == "" {
+		return By{}, fmt.Errorf("missing field in order by [%s]", orderBy)
+	}
+
+	switch len(parts) {
+	case 1:
+		return NewBy(field, ASC), nil
+
+	case 2:
+		dir, exists := directions[strings.ToUpper(strings.TrimSpace(parts[1]))]
+		if !exists {
+			return By{}, fmt.Errorf("unknown direction [%s]: %w", parts[1], ErrInvalidDirection)
+		}
+		return NewBy(field, dir), nil
+
+	default:
+		return By{}, fmt.Errorf("unknown order by format [%s]", orderBy)
+	}
+}
@@ -0,0 +1,57 @@
+// Package tenant provides support for scoping work to the tenant a request
+// is made for.
+package tenant
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// ErrMissing is returned when the context doesn't carry a tenant scope. Work
+// that isn't scoped to a tenant is refused rather than allowed to see every
+// tenant.
+var ErrMissing = errors.New("tenant missing from context")
+
+// Default is the tenant that owns the data that existed before tenants were
+// introduced.
+var Default = uuid.MustParse("c6f8c3a2-3b8e-4f1e-9d0a-1f6b7a2e4d10")
+
+// Scope represents the set of tenants work is allowed to access. Either a
+// single tenant or, for platform operators, every tenant.
+type Scope struct {
+	ID  uuid.UUID
+	All bool
+}
+
+// Allows reports whether data owned by the specified tenant is in scope.
+func (s Scope) Allows(tenantID uuid.UUID) bool {
+	return s.All || s.ID == tenantID
+}
+
+// ctxKey represents the type of value for the context key.
+type ctxKey int
+
+// key is used to store/retrieve a Scope value from a context.Context.
+const key ctxKey = 1
+
+// Set stores a scope for the specified tenant in the context.
+func Set(ctx context.Context, tenantID uuid.UUID) context.Context {
+	return context.WithValue(ctx, key, Scope{ID: tenantID})
+}
+
+// SetAll stores a scope for every tenant in the context. This is only for
+// platform operators and for lookups that happen before the tenant is known.
+func SetAll(ctx context.Context) context.Context {
+	return context.WithValue(ctx, key, Scope{All: true})
+}
+
+// Get returns the scope from the context.
+func Get(ctx context.Context) (Scope, error) {
+	s, ok := ctx.Value(key).(Scope)
+	if !ok {
+		return Scope{}, ErrMissing
+	}
+	return s, nil
+}
@@ -3,10 +3,13 @@ package auth
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/open-policy-agent/opa/rego"
@@ -23,51 +26,264 @@ type KeyLookup interface {
 	PublicKeyPEM(kid string) (pem string, err error)
 }
 
-// Config represents information required to initialize auth.
+// revisioner is implemented by a KeyLookup whose keys can change while the
+// service is running. The revision changes every time the keys change.
+type revisioner interface {
+	Revision() uint64
+}
+
+// Set of engines that can be used to authenticate tokens.
+const (
+	EngineJWT = "jwt"
+	EngineOPA = "opa"
+)
+
+// DefaultTokenLifetime is how long a token is valid when no lifetime is
+// configured.
+const DefaultTokenLifetime = time.Hour
+
+// Config represents information required to initialize auth. Tokens are
+// issued for the Issuer and Audience and only tokens with a matching issuer
+// and at least one matching audience are accepted. Leeway is the amount of
+// clock skew tolerated when checking the time based claims.
 type Config struct {
-	Log       *zap.SugaredLogger
-	KeyLookup KeyLookup
+	Log           *zap.SugaredLogger
+	KeyLookup     KeyLookup
+	Issuer        string
+	Audience      []string
+	TokenLifetime time.Duration
+	Leeway        time.Duration
+
+	// Engine selects how tokens are authenticated, EngineJWT when empty.
+	Engine string
+
+	// PolicySource is a directory of rego files or an OPA bundle tarball
+	// that replaces the embedded policies with the same relative path.
+	PolicySource string
+
+	// DecisionLog configures how the outcome of every policy evaluation
+	// is logged.
+	DecisionLog DecisionLogConfig
 }
 
 // Auth is used to authenticate clients. It can generate a token for a
 // set of user claims and recreate the claims by parsing the token.
 type Auth struct {
-	log       *zap.SugaredLogger
-	keyLookup KeyLookup
-	method    jwt.SigningMethod
-	parser    *jwt.Parser
-	mu        sync.RWMutex
-	cache     map[string]string
+	log           *zap.SugaredLogger
+	keyLookup     KeyLookup
+	issuer        string
+	audience      []string
+	lifetime      time.Duration
+	leeway        time.Duration
+	engine        string
+	parser        *jwt.Parser
+	policies      atomic.Value
+	source        string
+	fingerprintMu sync.Mutex
+	fingerprint   string
+	decisions     DecisionLogConfig
+	redact        map[string]bool
+	mu            sync.RWMutex
+	cache         map[string]publicKey
+	revision      uint64
+}
+
+// publicKey represents a parsed public key along with its PEM encoding, so
+// the key is parsed once and not for every token.
+type publicKey struct {
+	pem string
+	key any
+	alg string
 }
 
 // New creates an Auth to support authentication/authorization.
 func New(cfg Config) (*Auth, error) {
+	if cfg.Issuer == "" {
+		return nil, errors.New("issuer must be provided")
+	}
+
+	engine := cfg.Engine
+	switch engine {
+	case "":
+		engine = EngineJWT
+	case EngineJWT, EngineOPA:
+	default:
+		return nil, fmt.Errorf("unknown authentication engine %q", engine)
+	}
+
+	lifetime := cfg.TokenLifetime
+	if lifetime <= 0 {
+		lifetime = DefaultTokenLifetime
+	}
+
+	fingerprint, err := sourceFingerprint(cfg.PolicySource)
+	if err != nil {
+		return nil, fmt.Errorf("policy source: %w", err)
+	}
+
+	// Every rule is compiled up front so a broken policy stops the service
+	// from starting instead of failing requests.
+	policies, err := loadPolicies(context.Background(), cfg.PolicySource)
+	if err != nil {
+		return nil, fmt.Errorf("loading policies: %w", err)
+	}
+
+	redactFields := cfg.DecisionLog.Redact
+	if redactFields == nil {
+		redactFields = DefaultRedact
+	}
+
+	redact := make(map[string]bool, len(redactFields))
+	for _, field := range redactFields {
+		redact[field] = true
+	}
+
+	// The time based claims are validated by Auth so leeway can be applied,
+	// the jwt package doesn't support leeway.
 	a := Auth{
-		log:       cfg.Log,
-		keyLookup: cfg.KeyLookup,
-		method:    jwt.GetSigningMethod("RS256"),
-		parser:    jwt.NewParser(jwt.WithValidMethods([]string{"RS256"})),
-		cache:     make(map[string]string),
+		log:         cfg.Log,
+		keyLookup:   cfg.KeyLookup,
+		issuer:      cfg.Issuer,
+		audience:    cfg.Audience,
+		lifetime:    lifetime,
+		leeway:      cfg.Leeway,
+		engine:      engine,
+		parser:      jwt.NewParser(jwt.WithValidMethods(algorithms), jwt.WithoutClaimsValidation()),
+		source:      cfg.PolicySource,
+		fingerprint: fingerprint,
+		decisions:   cfg.DecisionLog,
+		redact:      redact,
+		cache:       make(map[string]publicKey),
 	}
+	a.policies.Store(policies)
 
 	return &a, nil
 }
 
-// GenerateToken generates a signed JWT token string representing the user Claims.
-func (a *Auth) GenerateToken(kid string, claims Claims) (string, error) {
-	token := jwt.NewWithClaims(a.method, claims)
-	token.Header["kid"] = kid
+// PolicyStatus returns the source and revision of the policies being enforced.
+func (a *Auth) PolicyStatus() PolicyStatus {
+	return a.policies.Load().(*policySet).status
+}
+
+// ReloadPolicies loads and compiles the policies from the configured source.
+// The new policies replace the current ones in a single step, and only when
+// every rule compiles. On failure the current policies stay in effect.
+func (a *Auth) ReloadPolicies(ctx context.Context) error {
+	policies, err := loadPolicies(ctx, a.source)
+	if err != nil {
+		return err
+	}
+
+	a.policies.Store(policies)
+
+	return nil
+}
+
+// WatchPolicies checks the policy source for changes at the specified
+// interval and reloads the policies when a change is found. Calling the
+// returned function stops the watch.
+func (a *Auth) WatchPolicies(interval time.Duration) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				a.checkPolicies()
+
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// checkPolicies reloads the policies when the files of the source changed.
+// A change that fails to load is only attempted once, the next attempt is
+// made when the files change again.
+func (a *Auth) checkPolicies() {
+	if a.source == "" {
+		return
+	}
+
+	fingerprint, err := sourceFingerprint(a.source)
+	if err != nil {
+		a.log.Errorw("auth", "status", "policy source check failed", "source", a.source, "ERROR", err)
+		return
+	}
+
+	a.fingerprintMu.Lock()
+	defer a.fingerprintMu.Unlock()
+
+	if fingerprint == a.fingerprint {
+		return
+	}
+	a.fingerprint = fingerprint
+
+	if err := a.ReloadPolicies(context.Background()); err != nil {
+		a.log.Errorw("auth", "status", "policy reload rejected", "source", a.source, "ERROR", err)
+		return
+	}
+
+	a.log.Infow("auth", "status", "policies reloaded", "source", a.source, "revision", a.PolicyStatus().Revision)
+}
+
+// Issuer returns the issuer of the tokens.
+func (a *Auth) Issuer() string {
+	return a.issuer
+}
+
+// NewClaims constructs the claims for a token issued to the subject. The
+// issuer, audience and lifetime come from the configuration.
+func (a *Auth) NewClaims(subject string, roles []string) Claims {
+	now := time.Now().UTC()
+
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			Issuer:    a.issuer,
+			Audience:  a.audience,
+			ExpiresAt: jwt.NewNumericDate(now.Add(a.lifetime)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		Roles: roles,
+	}
+
+	return claims
+}
 
+// GenerateToken generates a signed JWT token string representing the user
+// Claims. The signing algorithm follows the type of the key.
+func (a *Auth) GenerateToken(kid string, claims Claims) (string, error) {
 	privateKeyPEM, err := a.keyLookup.PrivateKeyPEM(kid)
 	if err != nil {
 		return "", fmt.Errorf("private key: %w", err)
 	}
 
-	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(privateKeyPEM))
+	privateKey, err := ParsePrivateKeyPEM(privateKeyPEM)
 	if err != nil {
 		return "", fmt.Errorf("parsing private pem: %w", err)
 	}
 
+	method, err := signingMethod(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("signing method: %w", err)
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+
 	str, err := token.SignedString(privateKey)
 	if err != nil {
 		return "", fmt.Errorf("signing token: %w", err)
@@ -76,99 +292,245 @@ func (a *Auth) GenerateToken(kid string, claims Claims) (string, error) {
 	return str, nil
 }
 
-func (a *Auth) OPAAuthenticate(ctx context.Context, bearerToken string) (Claims, error) {
-	parts := strings.Split(bearerToken, " ")
-	if len(parts) != 2 || parts[0] != "Bearer" {
-		return Claims{}, errors.New("expected authorization header format: Bearer <token>")
+// Authenticate processes the token to validate the sender's token is valid
+// using the configured engine. Both engines accept the same tokens, return
+// the same claims and report failures using the same jwt error values.
+func (a *Auth) Authenticate(ctx context.Context, bearerToken string) (Claims, error) {
+	if a.engine == EngineOPA {
+		return a.OPAAuthenticate(ctx, bearerToken)
+	}
+
+	return a.JWTAuthenticate(ctx, bearerToken)
+}
+
+// JWTAuthenticate validates the token using the jwt package.
+func (a *Auth) JWTAuthenticate(ctx context.Context, bearerToken string) (Claims, error) {
+	tokenStr, err := parseBearer(bearerToken)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		publicKey, _, err := a.verificationKey(token.Header)
+		return publicKey, err
 	}
 
 	var claims Claims
-	token, _, err := a.parser.ParseUnverified(parts[1], &claims)
+	if _, err := a.parser.ParseWithClaims(tokenStr, &claims, keyFunc); err != nil {
+		return Claims{}, fmt.Errorf("parse with claims: %w", err)
+	}
+
+	if err := a.validateClaims(claims, time.Now()); err != nil {
+		return Claims{}, fmt.Errorf("validate claims: %w", err)
+	}
+
+	return claims, nil
+}
+
+// OPAAuthenticate validates the token using the authentication policy. Only
+// the header is read before the policy verifies the token, the claims are
+// decoded once the token is accepted. When the policy rejects the token the
+// failure is classified so it's reported the same way as JWTAuthenticate.
+func (a *Auth) OPAAuthenticate(ctx context.Context, bearerToken string) (Claims, error) {
+	tokenStr, err := parseBearer(bearerToken)
 	if err != nil {
-		return Claims{}, fmt.Errorf("error parsing token: %w", err)
+		return Claims{}, err
 	}
 
-	kidRaw, exists := token.Header["kid"]
-	if !exists {
-		return Claims{}, fmt.Errorf("kid missing from header: %w", err)
+	parts := strings.Split(tokenStr, ".")
+	if len(parts) != 3 {
+		return Claims{}, fmt.Errorf("%w: token contains an invalid number of segments", jwt.ErrTokenMalformed)
 	}
 
-	kid, ok := kidRaw.(string)
-	if !ok {
-		return Claims{}, fmt.Errorf("kid malformed: %w", err)
+	header, err := decodeSegment(parts[0])
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: header: %s", jwt.ErrTokenMalformed, err)
 	}
 
-	pem, err := a.publicKeyLookup(kid)
+	alg, _ := header["alg"].(string)
+	if !validAlgorithm(alg) {
+		return Claims{}, fmt.Errorf("%w: signing method %s is invalid", jwt.ErrTokenSignatureInvalid, alg)
+	}
+
+	_, pem, err := a.verificationKey(header)
 	if err != nil {
-		return Claims{}, fmt.Errorf("failed to fetch public key: %w", err)
+		return Claims{}, err
+	}
+
+	audience := a.audience
+	if audience == nil {
+		audience = []string{}
 	}
 
+	// The algorithm in the header has been checked against the key so a
+	// token can't pick the algorithm used to verify it.
 	input := map[string]any{
-		"Key":   pem,
-		"Token": parts[1],
+		"Key":      pem,
+		"Alg":      alg,
+		"Token":    tokenStr,
+		"Issuer":   a.issuer,
+		"Audience": audience,
+		"Leeway":   a.leeway.Nanoseconds(),
 	}
 
-	if err := a.opaPolicyEvaluation(ctx, opaAuthentication, RuleAuthenticate, input); err != nil {
-		return Claims{}, fmt.Errorf("authentication failed : %w", err)
+	if err := a.opaPolicyEvaluation(ctx, RuleAuthenticate, "", input); err != nil {
+		return Claims{}, a.classifyRejection(ctx, input, parts[1], err)
+	}
+
+	var claims Claims
+	if err := decodeClaims(parts[1], &claims); err != nil {
+		return Claims{}, err
 	}
 
 	return claims, nil
 }
 
-// publicKeyLookup performs a lookup for the public pem for the specified kid.
-func (a *Auth) publicKeyLookup(kid string) (string, error) {
-	pem, err := func() (string, error) {
+// classifyRejection finds the reason the authentication policy rejected a
+// token. The signature is checked before the claims, which is the order the
+// jwt package uses.
+func (a *Auth) classifyRejection(ctx context.Context, input map[string]any, payload string, evalErr error) error {
+	if err := a.opaPolicyEvaluation(ctx, RuleSignature, "", input); err != nil {
+		return fmt.Errorf("%w: %s", jwt.ErrTokenSignatureInvalid, evalErr)
+	}
+
+	var claims Claims
+	if err := decodeClaims(payload, &claims); err != nil {
+		return err
+	}
+
+	if err := a.validateClaims(claims, time.Now()); err != nil {
+		return fmt.Errorf("validate claims: %w", err)
+	}
+
+	return fmt.Errorf("%w: authentication failed : %s", jwt.ErrTokenInvalidClaims, evalErr)
+}
+
+// verificationKey returns the public key, and its PEM encoding, identified by
+// the kid in the token header. The token must be signed with the algorithm
+// that matches the key.
+func (a *Auth) verificationKey(header map[string]any) (any, string, error) {
+	kidRaw, exists := header["kid"]
+	if !exists {
+		return nil, "", fmt.Errorf("%w: kid missing from header", jwt.ErrTokenUnverifiable)
+	}
+
+	kid, ok := kidRaw.(string)
+	if !ok {
+		return nil, "", fmt.Errorf("%w: kid malformed", jwt.ErrTokenUnverifiable)
+	}
+
+	pk, err := a.publicKeyLookup(kid)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %s", jwt.ErrTokenUnverifiable, err)
+	}
+
+	if tokenAlg, _ := header["alg"].(string); tokenAlg != pk.alg {
+		return nil, "", fmt.Errorf("%w: signing method %s doesn't match key algorithm %s", jwt.ErrTokenUnverifiable, tokenAlg, pk.alg)
+	}
+
+	return pk.key, pk.pem, nil
+}
+
+// publicKeyLookup performs a lookup for the public key for the specified kid.
+// The key is parsed once and cached, the cache is cleared when the key lookup
+// reports its keys have changed.
+func (a *Auth) publicKeyLookup(kid string) (publicKey, error) {
+	var revision uint64
+	rv, canChange := a.keyLookup.(revisioner)
+	if canChange {
+		revision = rv.Revision()
+	}
+
+	pk, err := func() (publicKey, error) {
 		a.mu.RLock()
 		defer a.mu.RUnlock()
 
-		pem, exists := a.cache[kid]
+		if a.revision != revision {
+			return publicKey{}, errors.New("stale")
+		}
+
+		pk, exists := a.cache[kid]
 		if !exists {
-			return "", errors.New("not found")
+			return publicKey{}, errors.New("not found")
 		}
-		return pem, nil
+		return pk, nil
 	}()
 	if err == nil {
-		return pem, nil
+		return pk, nil
 	}
 
-	pem, err = a.keyLookup.PublicKeyPEM(kid)
+	pem, err := a.keyLookup.PublicKeyPEM(kid)
 	if err != nil {
-		return "", fmt.Errorf("fetching public key: %w", err)
+		return publicKey{}, fmt.Errorf("fetching public key: %w", err)
+	}
+
+	key, err := ParsePublicKeyPEM(pem)
+	if err != nil {
+		return publicKey{}, fmt.Errorf("parsing public pem: %w", err)
+	}
+
+	alg, err := Algorithm(key)
+	if err != nil {
+		return publicKey{}, err
+	}
+
+	pk = publicKey{
+		pem: pem,
+		key: key,
+		alg: alg,
 	}
 
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	a.cache[kid] = pem
 
-	return pem, nil
+	if a.revision != revision {
+		a.cache = make(map[string]publicKey)
+		a.revision = revision
+	}
+	a.cache[kid] = pk
+
+	return pk, nil
 }
 
-// Authorize attempts to authorize the user with the provided input roles, if
-// none of the input roles are within the user's claims, we return an error
-// otherwise the user is authorized.
+// Authorize attempts to authorize the user using the specified rule. The rule
+// is evaluated against the user's claims and the resource stored in the
+// context, if the rule doesn't allow the request an error is returned.
 func (a *Auth) Authorize(ctx context.Context, claims Claims, rule string) error {
-	input := map[string]any{
-		"Roles": claims.Roles,
+	input := authorizationInput(ctx, claims)
+
+	if err := a.opaPolicyEvaluation(ctx, rule, claims.Subject, input); err != nil {
+		return fmt.Errorf("rego evaluation failed : %w", err)
 	}
 
-	if err := a.opaPolicyEvaluation(ctx, opaAuthorization, rule, input); err != nil {
+	return nil
+}
+
+// AuthorizeFields attempts to authorize the user to change the specified set
+// of fields using the provided rule. The fields are identified by the names
+// the client used to provide them.
+func (a *Auth) AuthorizeFields(ctx context.Context, claims Claims, rule string, fields []string) error {
+	input := authorizationInput(ctx, claims)
+	input["Fields"] = fields
+
+	if err := a.opaPolicyEvaluation(ctx, rule, claims.Subject, input); err != nil {
 		return fmt.Errorf("rego evaluation failed : %w", err)
 	}
 
 	return nil
 }
 
-// opaPolicyEvaluation asks opa to evaulate the token against the specified token
-// policy and public key.
-func (a *Auth) opaPolicyEvaluation(ctx context.Context, opaPolicy string, rule string, input any) error {
-	query := fmt.Sprintf("x = data.%s.%s", opaPackage, rule)
+// opaPolicyEvaluation asks opa to evaulate the input against the prepared
+// query for the specified rule. The decision is logged for the subject the
+// input belongs to.
+func (a *Auth) opaPolicyEvaluation(ctx context.Context, rule string, subject string, input map[string]any) (err error) {
+	start := time.Now()
+	defer func() {
+		a.logDecision(ctx, rule, subject, input, start, err)
+	}()
 
-	q, err := rego.New(
-		rego.Query(query),
-		rego.Module("policy.rego", opaPolicy),
-	).PrepareForEval(ctx)
-	if err != nil {
-		return err
+	q, exists := a.policies.Load().(*policySet).queries[rule]
+	if !exists {
+		return fmt.Errorf("rule[%s] not found", rule)
 	}
 
 	results, err := q.Eval(ctx, rego.EvalInput(input))
@@ -188,36 +550,92 @@ func (a *Auth) opaPolicyEvaluation(ctx context.Context, opaPolicy string, rule s
 	return nil
 }
 
-// Authenticate processes the token to validate the sender's token is valid.
-func (a *Auth) Authenticate(ctx context.Context, bearerToken string) (Claims, error) {
+// validateClaims checks the registered claims against the configuration,
+// allowing for the configured amount of clock skew.
+func (a *Auth) validateClaims(claims Claims, now time.Time) error {
+	if claims.ExpiresAt == nil || !now.Before(claims.ExpiresAt.Add(a.leeway)) {
+		return jwt.ErrTokenExpired
+	}
+
+	if claims.NotBefore != nil && now.Add(a.leeway).Before(claims.NotBefore.Time) {
+		return jwt.ErrTokenNotValidYet
+	}
+
+	if claims.IssuedAt != nil && now.Add(a.leeway).Before(claims.IssuedAt.Time) {
+		return jwt.ErrTokenUsedBeforeIssued
+	}
+
+	if claims.Issuer != a.issuer {
+		return jwt.ErrTokenInvalidIssuer
+	}
+
+	if len(a.audience) > 0 && !matchAudience(claims.Audience, a.audience) {
+		return jwt.ErrTokenInvalidAudience
+	}
+
+	return nil
+}
+
+// matchAudience reports whether the token was issued for at least one of the
+// accepted audiences.
+func matchAudience(tokenAud []string, accepted []string) bool {
+	for _, aud := range tokenAud {
+		for _, acc := range accepted {
+			if aud == acc {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// parseBearer returns the token from an authorization header value.
+func parseBearer(bearerToken string) (string, error) {
 	parts := strings.Split(bearerToken, " ")
 	if len(parts) != 2 || parts[0] != "Bearer" {
-		return Claims{}, errors.New("expected authorization header format: Bearer <token>")
+		return "", errors.New("expected authorization header format: Bearer <token>")
 	}
 
-	keyFunc := func(token *jwt.Token) (interface{}, error) {
-		kid, exists := token.Header["kid"]
-		if !exists {
-			return nil, errors.New("kid not in header")
-		}
+	return parts[1], nil
+}
 
-		pem, err := a.keyLookup.PublicKeyPEM(kid.(string))
-		if err != nil {
-			return nil, err
+// validAlgorithm reports whether a token can be signed with the algorithm.
+func validAlgorithm(alg string) bool {
+	for _, valid := range algorithms {
+		if alg == valid {
+			return true
 		}
+	}
 
-		publicKey, err := jwt.ParseRSAPublicKeyFromPEM([]byte(pem))
-		if err != nil {
-			return "", fmt.Errorf("parsing public pem: %w", err)
-		}
+	return false
+}
 
-		return publicKey, nil
+// decodeSegment decodes the JSON object in a segment of a token.
+func decodeSegment(seg string) (map[string]any, error) {
+	data, err := jwt.DecodeSegment(seg)
+	if err != nil {
+		return nil, err
 	}
 
-	var claims Claims
-	if _, err := a.parser.ParseWithClaims(parts[1], &claims, keyFunc); err != nil {
-		return Claims{}, fmt.Errorf("parse with claims: %w", err)
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
 	}
 
-	return claims, nil
+	return m, nil
+}
+
+// decodeClaims decodes the claims segment of a token.
+func decodeClaims(seg string, claims *Claims) error {
+	data, err := jwt.DecodeSegment(seg)
+	if err != nil {
+		return fmt.Errorf("%w: claims: %s", jwt.ErrTokenMalformed, err)
+	}
+
+	if err := json.Unmarshal(data, claims); err != nil {
+		return fmt.Errorf("%w: claims: %s", jwt.ErrTokenMalformed, err)
+	}
+
+	return nil
 }
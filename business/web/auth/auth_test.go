@@ -0,0 +1,899 @@
+package auth
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/open-policy-agent/opa/rego"
+	"go.uber.org/zap"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+const (
+	kid     = "s4sKIjD9kIRjxs2tulPqGLdxSfgPErRN1Mu3Hd9k9NQ"
+	subject = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+)
+
+func Test_PrepareQueries(t *testing.T) {
+	t.Log("Given the need to compile the policies when auth is constructed.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a policy that doesn't compile.", testID)
+		{
+			modules := map[string]string{
+				"broken.rego": "package ardan.rego\n\nallowAny {",
+			}
+
+			if _, err := prepareQueries(context.Background(), modules, []string{RuleAny}); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould fail to prepare the queries.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould fail to prepare the queries.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen handling a rule the policy doesn't define.", testID)
+		{
+			if _, err := prepareQueries(context.Background(), embeddedPolicies, []string{"allowNobody"}); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould fail to prepare the queries.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould fail to prepare the queries.", success, testID)
+		}
+	}
+}
+
+func Test_ReloadPolicies(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "authorization.rego")
+
+	// Replace the embedded policy with one where every subject is allowed.
+	policy := strings.Replace(opaAuthorization, "default allowAny = false", "default allowAny = true", 1)
+	if err := os.WriteFile(file, []byte(policy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := New(Config{
+		Log:          zap.NewNop().Sugar(),
+		KeyLookup:    keyStore{},
+		Issuer:       "service project",
+		PolicySource: dir,
+	})
+	if err != nil {
+		t.Fatalf("Should be able to construct auth : %s", err)
+	}
+
+	ctx := context.Background()
+	user := Claims{}
+
+	t.Log("Given the need to load policies from a directory.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen using the policies from the directory.", testID)
+		{
+			if err := a.Authorize(ctx, user, RuleAny); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould use the policy from the directory : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould use the policy from the directory.", success, testID)
+
+			if status := a.PolicyStatus(); status.Source != dir {
+				t.Fatalf("\t%s\tTest %d:\tShould report the source : got %s.", failed, testID, status.Source)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report the source.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen reloading a policy that doesn't compile.", testID)
+		{
+			revision := a.PolicyStatus().Revision
+
+			if err := os.WriteFile(file, []byte("package ardan.rego\n\nallowAny {"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := a.ReloadPolicies(ctx); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the reload.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the reload.", success, testID)
+
+			if a.PolicyStatus().Revision != revision || a.Authorize(ctx, user, RuleAny) != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the current policies.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the current policies.", success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen the policy is removed from the directory.", testID)
+		{
+			if err := os.Remove(file); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := a.ReloadPolicies(ctx); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to reload : %s.", failed, testID, err)
+			}
+
+			if err := a.Authorize(ctx, user, RuleAny); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould fall back to the embedded policy.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould fall back to the embedded policy.", success, testID)
+		}
+	}
+}
+
+func Test_ReadPolicies(t *testing.T) {
+	t.Log("Given the need to read policy modules from a source.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen reading a directory with modules of the same name in different folders.", testID)
+		{
+			dir := t.TempDir()
+			files := map[string]string{
+				"authorization.rego": "package ardan.rego\n",
+				"a/extra.rego":       "package ardan.a\n",
+				"b/extra.rego":       "package ardan.b\n",
+			}
+			for name, module := range files {
+				file := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(file, []byte(module), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			modules, _, err := readPolicies(dir)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to read the modules : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to read the modules.", success, testID)
+
+			if !reflect.DeepEqual(modules, files) {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, modules)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, files)
+				t.Fatalf("\t%s\tTest %d:\tShould key the modules by their relative path.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould key the modules by their relative path.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen reading a bundle with modules of the same name in different folders.", testID)
+		{
+			file := writeBundle(t, map[string]string{
+				"/a/extra.rego": "package ardan.a\n",
+				"/b/extra.rego": "package ardan.b\n",
+			})
+
+			modules, _, err := readPolicies(file)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to read the modules : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to read the modules.", success, testID)
+
+			exp := map[string]string{
+				"a/extra.rego": "package ardan.a\n",
+				"b/extra.rego": "package ardan.b\n",
+			}
+			if !reflect.DeepEqual(modules, exp) {
+				t.Logf("\t\tTest %d:\tGot: %v", testID, modules)
+				t.Logf("\t\tTest %d:\tExp: %v", testID, exp)
+				t.Fatalf("\t%s\tTest %d:\tShould key the modules by their relative path.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould key the modules by their relative path.", success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen reading a bundle with the same module twice.", testID)
+		{
+			file := writeBundle(t, map[string]string{
+				"/extra.rego":  "package ardan.a\n",
+				"./extra.rego": "package ardan.b\n",
+			})
+
+			if _, _, err := readPolicies(file); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the duplicate module.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the duplicate module.", success, testID)
+		}
+	}
+}
+
+func Test_AuthorizeOwner(t *testing.T) {
+	a, err := New(Config{
+		Log:       zap.NewNop().Sugar(),
+		KeyLookup: keyStore{},
+		Issuer:    "service project",
+	})
+	if err != nil {
+		t.Fatalf("Should be able to construct auth : %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		claims  Claims
+		owner   string
+		allowed bool
+	}{
+		{"owner", Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}, Roles: []string{"USER"}}, subject, true},
+		{"other user", Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "5cf37266-3473-4006-984f-9325122678b7"}, Roles: []string{"USER"}}, subject, false},
+		{"admin", Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "5cf37266-3473-4006-984f-9325122678b7"}, Roles: []string{"ADMIN"}, Permissions: []string{"users:write"}}, subject, true},
+		{"no owner", Claims{Roles: []string{"USER"}}, "", false},
+	}
+
+	t.Log("Given the need to authorize access to a resource with an owner.")
+	{
+		for testID, tt := range tests {
+			t.Logf("\tTest %d:\tWhen handling the %s.", testID, tt.name)
+			{
+				ctx := SetResource(context.Background(), Resource{
+					Method:     "PUT",
+					Route:      "/users/:id",
+					Params:     map[string]string{"id": tt.owner},
+					Owner:      tt.owner,
+					Permission: "users:write",
+				})
+
+				err := a.Authorize(ctx, tt.claims, RulePermissionOrOwner)
+				if (err == nil) != tt.allowed {
+					t.Fatalf("\t%s\tTest %d:\tShould be allowed[%v] : %v.", failed, testID, tt.allowed, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be allowed[%v].", success, testID, tt.allowed)
+			}
+		}
+	}
+}
+
+func Test_AuthorizePermission(t *testing.T) {
+	a, err := New(Config{
+		Log:       zap.NewNop().Sugar(),
+		KeyLookup: keyStore{},
+		Issuer:    "service project",
+	})
+	if err != nil {
+		t.Fatalf("Should be able to construct auth : %s", err)
+	}
+
+	other := "5cf37266-3473-4006-984f-9325122678b7"
+
+	tests := []struct {
+		name    string
+		claims  Claims
+		rule    string
+		owner   string
+		allowed bool
+	}{
+		{"custom role with permission", Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}, Roles: []string{"AUDITOR"}, Permissions: []string{"reports:read"}}, RulePermission, "", true},
+		{"admin without permission", Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}, Roles: []string{"ADMIN"}, Permissions: []string{"users:read"}}, RulePermission, "", false},
+		{"owner without permission", Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}, Roles: []string{"USER"}}, RulePermissionOrOwner, subject, true},
+		{"other user without permission", Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: other}, Roles: []string{"USER"}}, RulePermissionOrOwner, subject, false},
+		{"other user with permission", Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: other}, Roles: []string{"SUPPORT"}, Permissions: []string{"reports:read"}}, RulePermissionOrOwner, subject, true},
+		{"api key with permission", Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}, Permissions: []string{"reports:read"}, APIKeyID: "7a4d9d8c-2d1b-4f3c-9b1e-3c1f5a6b7c8d"}, RulePermission, "", true},
+	}
+
+	t.Log("Given the need to authorize access by the permissions of the roles.")
+	{
+		for testID, tt := range tests {
+			t.Logf("\tTest %d:\tWhen handling the %s.", testID, tt.name)
+			{
+				ctx := SetResource(context.Background(), Resource{
+					Method:     "GET",
+					Route:      "/reports/sales",
+					Owner:      tt.owner,
+					Permission: "reports:read",
+				})
+
+				err := a.Authorize(ctx, tt.claims, tt.rule)
+				if (err == nil) != tt.allowed {
+					t.Fatalf("\t%s\tTest %d:\tShould be allowed[%v] : %v.", failed, testID, tt.allowed, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be allowed[%v].", success, testID, tt.allowed)
+
+				if err := a.Authorize(ctx, tt.claims, RuleAny); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be allowed by any role : %v.", failed, testID, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be allowed by any role.", success, testID)
+			}
+		}
+	}
+}
+
+func Test_AuthorizeFields(t *testing.T) {
+	a, err := New(Config{
+		Log:       zap.NewNop().Sugar(),
+		KeyLookup: keyStore{},
+		Issuer:    "service project",
+	})
+	if err != nil {
+		t.Fatalf("Should be able to construct auth : %s", err)
+	}
+
+	user := Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}, Roles: []string{"USER"}}
+	admin := Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}, Roles: []string{"ADMIN"}, Permissions: []string{"users:read", "users:write"}}
+
+	tests := []struct {
+		name    string
+		claims  Claims
+		fields  []string
+		allowed bool
+	}{
+		{"user changing the name", user, []string{"name"}, true},
+		{"user changing the email", user, []string{"email"}, true},
+		{"user changing the name and email", user, []string{"name", "email"}, true},
+		{"user changing the roles", user, []string{"roles"}, false},
+		{"user changing the enabled status", user, []string{"enabled"}, false},
+		{"user changing the name and roles", user, []string{"name", "roles"}, false},
+		{"admin changing the roles and enabled status", admin, []string{"roles", "enabled"}, true},
+	}
+
+	t.Log("Given the need to restrict the user fields a subject can change.")
+	{
+		for testID, tt := range tests {
+			t.Logf("\tTest %d:\tWhen handling the %s.", testID, tt.name)
+			{
+				err := a.AuthorizeFields(context.Background(), tt.claims, RuleUserFields, tt.fields)
+				if (err == nil) != tt.allowed {
+					t.Fatalf("\t%s\tTest %d:\tShould be allowed[%v] : %v.", failed, testID, tt.allowed, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be allowed[%v].", success, testID, tt.allowed)
+			}
+		}
+	}
+}
+
+func Test_ImpersonatedFields(t *testing.T) {
+	a, err := New(Config{
+		Log:       zap.NewNop().Sugar(),
+		KeyLookup: keyStore{},
+		Issuer:    "service project",
+	})
+	if err != nil {
+		t.Fatalf("Should be able to construct auth : %s", err)
+	}
+
+	admin := "5cf37266-3473-4006-984f-9325122678b7"
+
+	user := Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}, Roles: []string{"USER"}}
+	impersonated := user
+	impersonated.Act = &Actor{Subject: admin}
+
+	tests := []struct {
+		name    string
+		claims  Claims
+		fields  []string
+		allowed bool
+	}{
+		{"user changing the password", user, []string{"name", "password"}, true},
+		{"impersonated changing the name", impersonated, []string{"name", "email"}, true},
+		{"impersonated changing the password", impersonated, []string{"name", "password"}, false},
+		{"impersonated changing the roles", impersonated, []string{"roles"}, false},
+	}
+
+	t.Log("Given the need to restrict the fields an impersonated token can change.")
+	{
+		for testID, tt := range tests {
+			t.Logf("\tTest %d:\tWhen handling the %s.", testID, tt.name)
+			{
+				err := a.AuthorizeFields(context.Background(), tt.claims, RuleUserFields, tt.fields)
+				if (err == nil) != tt.allowed {
+					t.Fatalf("\t%s\tTest %d:\tShould be allowed[%v] : %v.", failed, testID, tt.allowed, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be allowed[%v].", success, testID, tt.allowed)
+			}
+		}
+
+		testID := len(tests)
+		t.Logf("\tTest %d:\tWhen handling the identities of an impersonated token.", testID)
+		{
+			if !impersonated.Impersonated() || impersonated.Subject != subject || impersonated.Actor() != admin {
+				t.Fatalf("\t%s\tTest %d:\tShould expose the subject and the actor : %v.", failed, testID, impersonated)
+			}
+			t.Logf("\t%s\tTest %d:\tShould expose the subject and the actor.", success, testID)
+
+			if user.Impersonated() || user.Actor() != subject {
+				t.Fatalf("\t%s\tTest %d:\tShould use the subject as the actor without impersonation : %v.", failed, testID, user)
+			}
+			t.Logf("\t%s\tTest %d:\tShould use the subject as the actor without impersonation.", success, testID)
+		}
+	}
+}
+
+func Test_DecisionLog(t *testing.T) {
+	var sink decisionSink
+
+	a, err := New(Config{
+		Log:       zap.NewNop().Sugar(),
+		KeyLookup: keyStore{},
+		Issuer:    "service project",
+		DecisionLog: DecisionLogConfig{
+			Sink:   &sink,
+			Redact: []string{"Params"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Should be able to construct auth : %s", err)
+	}
+
+	ctx := SetResource(context.Background(), Resource{
+		Method: "GET",
+		Route:  "/users/:id",
+		Params: map[string]string{"id": "5cf37266-3473-4006-984f-9325122678b7"},
+	})
+
+	user := Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}, Roles: []string{"USER"}}
+
+	t.Log("Given the need to log authorization decisions.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a request is allowed and allowed decisions aren't sampled.", testID)
+		{
+			if err := a.Authorize(ctx, user, RuleAny); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be allowed : %s.", failed, testID, err)
+			}
+
+			if len(sink.decisions) != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould not log the decision : got %d.", failed, testID, len(sink.decisions))
+			}
+			t.Logf("\t%s\tTest %d:\tShould not log the decision.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen a request is denied.", testID)
+		{
+			if err := a.Authorize(ctx, user, RulePermission); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be denied.", failed, testID)
+			}
+
+			if len(sink.decisions) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould log the decision : got %d.", failed, testID, len(sink.decisions))
+			}
+			t.Logf("\t%s\tTest %d:\tShould log the decision.", success, testID)
+
+			d := sink.decisions[0]
+			if d.Result || d.Subject != subject || d.Rule != RulePermission || d.InputDigest == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould describe the decision : %+v.", failed, testID, d)
+			}
+			t.Logf("\t%s\tTest %d:\tShould describe the decision.", success, testID)
+
+			if _, exists := d.Input["Params"]; exists || len(d.Erased) != 1 || d.Erased[0] != "/input/Params" {
+				t.Fatalf("\t%s\tTest %d:\tShould redact the input : %+v.", failed, testID, d)
+			}
+			t.Logf("\t%s\tTest %d:\tShould redact the input.", success, testID)
+		}
+	}
+}
+
+func Test_AuthenticateConformance(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		kid    string
+		claims func(a *Auth) Claims
+		tamper bool
+		err    error
+	}{
+		{
+			name:   "valid",
+			kid:    kid,
+			claims: func(a *Auth) Claims { return a.NewClaims(subject, []string{"USER"}) },
+		},
+		{
+			name: "expired",
+			kid:  kid,
+			claims: func(a *Auth) Claims {
+				claims := a.NewClaims(subject, []string{"USER"})
+				claims.IssuedAt = jwt.NewNumericDate(now.Add(-2 * time.Hour))
+				claims.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Hour))
+				return claims
+			},
+			err: jwt.ErrTokenExpired,
+		},
+		{
+			name: "wrong issuer",
+			kid:  kid,
+			claims: func(a *Auth) Claims {
+				claims := a.NewClaims(subject, []string{"USER"})
+				claims.Issuer = "someone else"
+				return claims
+			},
+			err: jwt.ErrTokenInvalidIssuer,
+		},
+		{
+			name: "wrong audience",
+			kid:  kid,
+			claims: func(a *Auth) Claims {
+				claims := a.NewClaims(subject, []string{"USER"})
+				claims.Audience = jwt.ClaimStrings{"another-api"}
+				return claims
+			},
+			err: jwt.ErrTokenInvalidAudience,
+		},
+		{
+			name:   "wrong kid",
+			kid:    "unknown",
+			claims: func(a *Auth) Claims { return a.NewClaims(subject, []string{"USER"}) },
+			err:    jwt.ErrTokenUnverifiable,
+		},
+		{
+			name:   "tampered",
+			kid:    kid,
+			claims: func(a *Auth) Claims { return a.NewClaims(subject, []string{"USER"}) },
+			tamper: true,
+			err:    jwt.ErrTokenSignatureInvalid,
+		},
+	}
+
+	engines := []string{EngineJWT, EngineOPA}
+
+	t.Log("Given the need for both authentication engines to behave the same.")
+	{
+		testID := 0
+		for _, alg := range algorithms {
+			privatePEM := generateKey(t, alg)
+
+			for _, tt := range tests {
+				t.Logf("\tTest %d:\tWhen handling a %s %s token.", testID, tt.name, alg)
+				{
+					var results []Claims
+					var bearer string
+
+					for _, engine := range engines {
+						a, err := New(Config{
+							Log:       zap.NewNop().Sugar(),
+							KeyLookup: keyStore{privatePEM: privatePEM},
+							Issuer:    "service project",
+							Audience:  []string{"sales-api"},
+							Engine:    engine,
+						})
+						if err != nil {
+							t.Fatalf("Should be able to construct auth : %s", err)
+						}
+
+						// Both engines authenticate the same token so the time
+						// based claims are equal.
+						if bearer == "" {
+							bearer = newToken(t, a, tt.kid, tt.claims(a), tt.tamper)
+						}
+
+						claims, err := a.Authenticate(context.Background(), bearer)
+						switch {
+						case tt.err == nil && err != nil:
+							t.Fatalf("\t%s\tTest %d:\tShould authenticate using %s : %s.", failed, testID, engine, err)
+
+						case tt.err != nil && !errors.Is(err, tt.err):
+							t.Fatalf("\t%s\tTest %d:\tShould fail with %q using %s : %v.", failed, testID, tt.err, engine, err)
+						}
+						t.Logf("\t%s\tTest %d:\tShould report the same result using %s.", success, testID, engine)
+
+						results = append(results, claims)
+					}
+
+					if !reflect.DeepEqual(results[0], results[1]) {
+						t.Fatalf("\t%s\tTest %d:\tShould produce the same claims : %+v != %+v.", failed, testID, results[0], results[1])
+					}
+					t.Logf("\t%s\tTest %d:\tShould produce the same claims.", success, testID)
+				}
+				testID++
+			}
+		}
+	}
+}
+
+// =============================================================================
+
+// BenchmarkAuthorize measures authorization using the query prepared when
+// auth was constructed.
+func BenchmarkAuthorize(b *testing.B) {
+	a := newBenchAuth(b)
+	claims := Claims{Roles: []string{"ADMIN"}}
+	ctx := context.Background()
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if err := a.Authorize(ctx, claims, RuleAny); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkAuthorizeCompile measures authorization when the policy is
+// compiled on every call, which is how it used to be done.
+func BenchmarkAuthorizeCompile(b *testing.B) {
+	input := map[string]any{
+		"Roles": []string{"ADMIN"},
+	}
+	ctx := context.Background()
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if err := compileAndEval(ctx, opaAuthorization, RuleAny, input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkAuthorizeParallel measures authorization using the prepared query
+// from many goroutines.
+func BenchmarkAuthorizeParallel(b *testing.B) {
+	a := newBenchAuth(b)
+	claims := Claims{Roles: []string{"ADMIN"}}
+	ctx := context.Background()
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := a.Authorize(ctx, claims, RuleAny); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+// BenchmarkOPAAuthenticate measures token authentication using the query
+// prepared when auth was constructed.
+func BenchmarkOPAAuthenticate(b *testing.B) {
+	a := newBenchAuth(b)
+	bearer := newBenchToken(b, a)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := a.OPAAuthenticate(ctx, bearer); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkOPAAuthenticateCompile measures token authentication when the
+// policy is compiled on every call.
+func BenchmarkOPAAuthenticateCompile(b *testing.B) {
+	a := newBenchAuth(b)
+	bearer := newBenchToken(b, a)
+	ctx := context.Background()
+
+	pem, err := a.keyLookup.PublicKeyPEM(kid)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	input := map[string]any{
+		"Key":      pem,
+		"Alg":      AlgRS256,
+		"Token":    bearer[len("Bearer "):],
+		"Issuer":   a.issuer,
+		"Audience": []string{},
+		"Leeway":   a.leeway.Nanoseconds(),
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if err := compileAndEval(ctx, opaAuthentication, RuleAuthenticate, input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// =============================================================================
+
+// compileAndEval compiles the policy and evaluates the rule in one step.
+func compileAndEval(ctx context.Context, module string, rule string, input any) error {
+	q, err := rego.New(
+		rego.Query(fmt.Sprintf("x = data.%s.%s", opaPackage, rule)),
+		rego.Module("policy.rego", module),
+		verifyEdDSA,
+	).PrepareForEval(ctx)
+	if err != nil {
+		return err
+	}
+
+	results, err := q.Eval(ctx, rego.EvalInput(input))
+	if err != nil {
+		return err
+	}
+
+	if len(results) == 0 {
+		return errors.New("no results")
+	}
+
+	if result, ok := results[0].Bindings["x"].(bool); !ok || !result {
+		return errors.New("denied")
+	}
+
+	return nil
+}
+
+func newBenchAuth(b *testing.B) *Auth {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	privatePEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	})
+
+	a, err := New(Config{
+		Log:       zap.NewNop().Sugar(),
+		KeyLookup: keyStore{privatePEM: string(privatePEM)},
+		Issuer:    "service project",
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return a
+}
+
+// newToken generates a bearer token for the claims. A tampered token has its
+// claims changed after it's signed.
+func newToken(t *testing.T, a *Auth, kid string, claims Claims, tamper bool) string {
+	// Tokens are signed with the only key, the kid only ends up in the header.
+	token, err := a.GenerateToken(kid, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tamper {
+		claims.Roles = []string{"ADMIN"}
+
+		data, err := json.Marshal(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parts := strings.Split(token, ".")
+		parts[1] = base64.RawURLEncoding.EncodeToString(data)
+		token = strings.Join(parts, ".")
+	}
+
+	return "Bearer " + token
+}
+
+// generateKey generates a PEM encoded private key for the algorithm.
+func generateKey(t *testing.T, alg string) string {
+	var block pem.Block
+
+	switch alg {
+	case AlgRS256:
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)}
+
+	case AlgES256:
+		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.MarshalECPrivateKey(privateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+
+	case AlgEdDSA:
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+
+	return string(pem.EncodeToMemory(&block))
+}
+
+func newBenchToken(b *testing.B, a *Auth) string {
+	token, err := a.GenerateToken(kid, a.NewClaims(subject, []string{"USER"}))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return "Bearer " + token
+}
+
+// decisionSink captures the decisions that are logged.
+type decisionSink struct {
+	decisions []Decision
+}
+
+func (ds *decisionSink) Log(d Decision) {
+	ds.decisions = append(ds.decisions, d)
+}
+
+// keyStore provides a single key for the tests.
+type keyStore struct {
+	privatePEM string
+}
+
+// PrivateKeyPEM returns the key for any kid so tokens can be generated with
+// a kid that can't be verified.
+func (ks keyStore) PrivateKeyPEM(keyID string) (string, error) {
+	return ks.privatePEM, nil
+}
+
+func (ks keyStore) PublicKeyPEM(keyID string) (string, error) {
+	if keyID != kid {
+		return "", errors.New("kid not found")
+	}
+	return PublicKeyPEM(ks.privatePEM)
+}
+
+// writeBundle writes a bundle tarball with the specified files and returns
+// its path.
+func writeBundle(t *testing.T, files map[string]string) string {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		hdr := tar.Header{Name: name, Mode: 0600, Size: int64(len(files[name]))}
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := os.WriteFile(file, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
@@ -6,10 +6,68 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 )
 
-// Claims represents the authorization claims transmitted via a JWT.
+// Claims represents the authorization claims transmitted via a JWT. The
+// SessionID identifies the login session the token was issued for so the
+// token can be rejected once the session is revoked. The TenantID identifies
+// the tenant the subject belongs to. The Permissions are the ones carried by
+// the roles when the token was issued. Act is set when the token was issued
+// to an admin impersonating the subject. ClientID is set when the token was
+// issued to a registered client that acts for the subject. APIKeyID is set when the request
+// was authenticated with an API key instead of a token and is never part of
+// a token.
 type Claims struct {
 	jwt.RegisteredClaims
-	Roles []string `json:"roles"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions,omitempty"`
+	SessionID   string   `json:"sid,omitempty"`
+	TenantID    string   `json:"tenant_id,omitempty"`
+	Act         *Actor   `json:"act,omitempty"`
+	ClientID    string   `json:"client_id,omitempty"`
+	APIKeyID    string   `json:"-"`
+}
+
+// Actor identifies the subject that is really acting when a token is used to
+// impersonate another subject. It follows the act claim of RFC 8693.
+type Actor struct {
+	Subject string `json:"sub"`
+}
+
+// Impersonated reports whether the token was issued to impersonate the
+// subject.
+func (c Claims) Impersonated() bool {
+	return c.Act != nil
+}
+
+// Actor returns the subject that is really making the request. That's the
+// admin for an impersonated token and the subject otherwise.
+func (c Claims) Actor() string {
+	if c.Act != nil {
+		return c.Act.Subject
+	}
+
+	return c.Subject
+}
+
+// HasRole reports whether the claims carry the specified role.
+func (c Claims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasPermission reports whether the claims carry the specified permission.
+func (c Claims) HasPermission(permission string) bool {
+	for _, p := range c.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
 }
 
 // =============================================================================
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/ardanlabs/service/foundation/web"
+	"github.com/google/uuid"
+)
+
+// Decision represents the outcome of a policy evaluation. The fields follow
+// the OPA decision log format so the decisions can be shipped to any service
+// that accepts OPA decision logs.
+type Decision struct {
+	DecisionID  string                    `json:"decision_id"`
+	TraceID     string                    `json:"trace_id"`
+	Path        string                    `json:"path"`
+	Subject     string                    `json:"subject,omitempty"`
+	Rule        string                    `json:"rule"`
+	Input       map[string]any            `json:"input"`
+	InputDigest string                    `json:"input_digest"`
+	Result      bool                      `json:"result"`
+	Error       string                    `json:"error,omitempty"`
+	Erased      []string                  `json:"erased,omitempty"`
+	Bundles     map[string]DecisionBundle `json:"bundles,omitempty"`
+	Metrics     map[string]int64          `json:"metrics"`
+	Timestamp   time.Time                 `json:"timestamp"`
+}
+
+// DecisionBundle identifies the revision of the policies that made a decision.
+type DecisionBundle struct {
+	Revision string `json:"revision"`
+}
+
+// Latency returns how long the evaluation of the decision took.
+func (d Decision) Latency() time.Duration {
+	return time.Duration(d.Metrics[metricEval])
+}
+
+// DecisionSink declares the behavior for storing policy decisions. Log is
+// called on the request path and should not block.
+type DecisionSink interface {
+	Log(d Decision)
+}
+
+// DefaultRedact is the set of input fields that are erased from decisions
+// when no set is configured.
+var DefaultRedact = []string{"Token"}
+
+// DecisionLogConfig represents the information required to log decisions.
+// Denied decisions and failed evaluations are always logged. SampleRate is
+// the fraction, between 0 and 1, of allowed decisions that are logged. The
+// input fields in Redact are erased before a decision is logged, the digest
+// is calculated over the complete input so equal inputs can still be
+// correlated. Without a sink decisions are written to the service log.
+type DecisionLogConfig struct {
+	Sink       DecisionSink
+	SampleRate float64
+	Redact     []string
+}
+
+// metricEval is the name OPA uses for the time spent evaluating a query.
+const metricEval = "timer_rego_query_eval_ns"
+
+// logDecision records the outcome of a policy evaluation.
+func (a *Auth) logDecision(ctx context.Context, rule string, subject string, input map[string]any, start time.Time, evalErr error) {
+	allowed := evalErr == nil
+	if allowed && (a.decisions.SampleRate <= 0 || rand.Float64() >= a.decisions.SampleRate) {
+		return
+	}
+
+	latency := time.Since(start)
+
+	var digest string
+	if data, err := json.Marshal(input); err == nil {
+		sum := sha256.Sum256(data)
+		digest = hex.EncodeToString(sum[:])
+	}
+
+	redacted := make(map[string]any, len(input))
+	var erased []string
+	for field, value := range input {
+		if a.redact[field] {
+			erased = append(erased, "/input/"+field)
+			continue
+		}
+		redacted[field] = value
+	}
+
+	d := Decision{
+		DecisionID:  uuid.NewString(),
+		TraceID:     web.GetTraceID(ctx),
+		Path:        strings.ReplaceAll(opaPackage, ".", "/") + "/" + rule,
+		Subject:     subject,
+		Rule:        rule,
+		Input:       redacted,
+		InputDigest: digest,
+		Result:      allowed,
+		Erased:      erased,
+		Bundles: map[string]DecisionBundle{
+			"policies": {Revision: a.PolicyStatus().Revision},
+		},
+		Metrics: map[string]int64{
+			metricEval: latency.Nanoseconds(),
+		},
+		Timestamp: start.UTC(),
+	}
+	if evalErr != nil {
+		d.Error = evalErr.Error()
+	}
+
+	if a.decisions.Sink != nil {
+		a.decisions.Sink.Log(d)
+		return
+	}
+
+	a.log.Infow("decision", "trace_id", d.TraceID, "decision_id", d.DecisionID, "subject", d.Subject, "rule", d.Rule,
+		"input_digest", d.InputDigest, "result", d.Result, "latency", latency, "ERROR", d.Error)
+}
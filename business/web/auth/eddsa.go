@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"strings"
+	"sync"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/types"
+)
+
+// verifyEdDSA adds the ardan.verify_eddsa built-in to the policies. The
+// io.jwt built-ins in OPA don't support Ed25519 keys, so the signature of
+// EdDSA tokens is verified by this function instead.
+var verifyEdDSA = rego.Function2(
+	&rego.Function{
+		Name: "ardan.verify_eddsa",
+		Decl: types.NewFunction(types.Args(types.S, types.S), types.B),
+	},
+	func(_ rego.BuiltinContext, token *ast.Term, key *ast.Term) (*ast.Term, error) {
+		tkn, ok := token.Value.(ast.String)
+		if !ok {
+			return ast.BooleanTerm(false), nil
+		}
+
+		pem, ok := key.Value.(ast.String)
+		if !ok {
+			return ast.BooleanTerm(false), nil
+		}
+
+		return ast.BooleanTerm(verifyEd25519(string(tkn), string(pem))), nil
+	},
+)
+
+// maxEdKeys limits the number of parsed keys kept by edKeys. The keys come
+// from the key lookup, so the limit is only reached when keys are rotated
+// many times while the service is running.
+const maxEdKeys = 100
+
+// edKeys caches the Ed25519 keys by their PEM encoding, so a key is parsed
+// once and not for every token.
+var edKeys = struct {
+	mu   sync.RWMutex
+	keys map[string]ed25519.PublicKey
+}{
+	keys: make(map[string]ed25519.PublicKey),
+}
+
+// verifyEd25519 reports whether the token was signed by the Ed25519 key.
+func verifyEd25519(token string, publicPEM string) bool {
+	edKey, ok := parseEd25519(publicPEM)
+	if !ok {
+		return false
+	}
+
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return false
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil {
+		return false
+	}
+
+	return ed25519.Verify(edKey, []byte(token[:i]), sig)
+}
+
+// parseEd25519 returns the Ed25519 key encoded in the PEM.
+func parseEd25519(publicPEM string) (ed25519.PublicKey, bool) {
+	edKeys.mu.RLock()
+	edKey, exists := edKeys.keys[publicPEM]
+	edKeys.mu.RUnlock()
+
+	if exists {
+		return edKey, true
+	}
+
+	publicKey, err := ParsePublicKeyPEM(publicPEM)
+	if err != nil {
+		return nil, false
+	}
+
+	edKey, ok := publicKey.(ed25519.PublicKey)
+	if !ok {
+		return nil, false
+	}
+
+	edKeys.mu.Lock()
+	defer edKeys.mu.Unlock()
+
+	if len(edKeys.keys) >= maxEdKeys {
+		edKeys.keys = make(map[string]ed25519.PublicKey)
+	}
+	edKeys.keys[publicPEM] = edKey
+
+	return edKey, true
+}
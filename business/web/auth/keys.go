@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Set of signing algorithms that are supported. The algorithm used for a
+// token follows the type of the key that signs it.
+const (
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+// algorithms is the set of algorithms a token can be signed with.
+var algorithms = []string{AlgRS256, AlgES256, AlgEdDSA}
+
+// Algorithm returns the signing algorithm for the specified private or public
+// key. RSA, ECDSA P-256 and Ed25519 keys are supported.
+func Algorithm(key any) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		return AlgRS256, nil
+
+	case *ecdsa.PrivateKey:
+		return ecdsaAlgorithm(k.Curve)
+
+	case *ecdsa.PublicKey:
+		return ecdsaAlgorithm(k.Curve)
+
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return AlgEdDSA, nil
+	}
+
+	return "", fmt.Errorf("unsupported key type %T", key)
+}
+
+// ParsePrivateKeyPEM parses a PEM encoded RSA, ECDSA or Ed25519 private key.
+func ParsePrivateKeyPEM(privatePEM string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(privatePEM))
+	if block == nil {
+		return nil, errors.New("invalid key: key must be a PEM encoded PKCS1, PKCS8 or EC key")
+	}
+
+	var parsedKey any
+	var err error
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsedKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+
+	case "EC PRIVATE KEY":
+		parsedKey, err = x509.ParseECPrivateKey(block.Bytes)
+
+	default:
+		parsedKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			if rsaKey, rsaErr := x509.ParsePKCS1PrivateKey(block.Bytes); rsaErr == nil {
+				parsedKey, err = rsaKey, nil
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+
+	signer, ok := parsedKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", parsedKey)
+	}
+
+	if _, err := Algorithm(signer); err != nil {
+		return nil, err
+	}
+
+	return signer, nil
+}
+
+// ParsePublicKeyPEM parses a PEM encoded RSA, ECDSA or Ed25519 public key.
+func ParsePublicKeyPEM(publicPEM string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicPEM))
+	if block == nil {
+		return nil, errors.New("invalid key: key must be a PEM encoded public key")
+	}
+
+	parsedKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing public key: %w", err)
+	}
+
+	if _, err := Algorithm(parsedKey); err != nil {
+		return nil, err
+	}
+
+	return parsedKey, nil
+}
+
+// PublicKeyPEM returns the PEM encoded public key for the specified PEM
+// encoded private key.
+func PublicKeyPEM(privatePEM string) (string, error) {
+	privateKey, err := ParsePrivateKeyPEM(privatePEM)
+	if err != nil {
+		return "", err
+	}
+
+	asn1Bytes, err := x509.MarshalPKIXPublicKey(privateKey.Public())
+	if err != nil {
+		return "", fmt.Errorf("marshaling public key: %w", err)
+	}
+
+	publicBlock := pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: asn1Bytes,
+	}
+
+	var b bytes.Buffer
+	if err := pem.Encode(&b, &publicBlock); err != nil {
+		return "", fmt.Errorf("encoding to public PEM: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+// =============================================================================
+
+func ecdsaAlgorithm(curve elliptic.Curve) (string, error) {
+	if curve != elliptic.P256() {
+		return "", fmt.Errorf("unsupported curve %s", curve.Params().Name)
+	}
+
+	return AlgES256, nil
+}
+
+// signingMethod returns the signing method for the specified key.
+func signingMethod(key any) (jwt.SigningMethod, error) {
+	alg, err := Algorithm(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return jwt.GetSigningMethod(alg), nil
+}
@@ -0,0 +1,275 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/bundle"
+	"github.com/open-policy-agent/opa/rego"
+)
+
+// PolicyStatus describes the set of policies being enforced.
+type PolicyStatus struct {
+	Source   string    `json:"source"`
+	Revision string    `json:"revision"`
+	Loaded   time.Time `json:"loaded"`
+}
+
+// policySet represents a compiled set of policies. A policy set is never
+// changed once it's built, a reload replaces it as a whole.
+type policySet struct {
+	status  PolicyStatus
+	queries map[string]rego.PreparedEvalQuery
+}
+
+// loadPolicies builds a policy set from the configured source. Policies are
+// read from a directory of rego files or from an OPA bundle tarball. A file
+// replaces the embedded policy with the same name and embedded policies that
+// aren't replaced stay in effect. Without a source only the embedded
+// policies are used.
+func loadPolicies(ctx context.Context, source string) (*policySet, error) {
+	modules := make(map[string]string, len(embeddedPolicies))
+	for name, module := range embeddedPolicies {
+		modules[name] = module
+	}
+
+	status := PolicyStatus{
+		Source: "embedded",
+	}
+
+	if source != "" {
+		loaded, revision, err := readPolicies(source)
+		if err != nil {
+			return nil, err
+		}
+
+		for name, module := range loaded {
+			modules[name] = module
+		}
+
+		status.Source = source
+		status.Revision = revision
+	}
+
+	if status.Revision == "" {
+		status.Revision = hashModules(modules)
+	}
+
+	queries, err := prepareQueries(ctx, modules, ruleNames)
+	if err != nil {
+		return nil, err
+	}
+
+	status.Loaded = time.Now()
+
+	ps := policySet{
+		status:  status,
+		queries: queries,
+	}
+
+	return &ps, nil
+}
+
+// readPolicies reads the rego modules from a directory or bundle tarball.
+// Modules are keyed by their path relative to the root of the source, so a
+// module at the root replaces the embedded policy with the same file name.
+// The revision is only provided by a
+// bundle that has one in its manifest.
+func readPolicies(source string) (map[string]string, string, error) {
+	fi, err := os.Stat(source)
+	if err != nil {
+		return nil, "", fmt.Errorf("policy source: %w", err)
+	}
+
+	if fi.IsDir() {
+		modules, err := readPolicyDir(source)
+		return modules, "", err
+	}
+
+	return readPolicyBundle(source)
+}
+
+func readPolicyDir(dir string) (map[string]string, error) {
+	modules := make(map[string]string)
+
+	walk := func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || filepath.Ext(p) != ".rego" || strings.HasSuffix(p, "_test.rego") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		return addModule(modules, filepath.ToSlash(rel), string(b))
+	}
+
+	if err := filepath.WalkDir(dir, walk); err != nil {
+		return nil, fmt.Errorf("reading policy directory: %w", err)
+	}
+
+	return modules, nil
+}
+
+func readPolicyBundle(file string) (map[string]string, string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, "", fmt.Errorf("opening bundle: %w", err)
+	}
+	defer f.Close()
+
+	b, err := bundle.NewReader(f).Read()
+	if err != nil {
+		return nil, "", fmt.Errorf("reading bundle: %w", err)
+	}
+
+	modules := make(map[string]string, len(b.Modules))
+	for _, m := range b.Modules {
+		name := strings.TrimPrefix(path.Clean("/"+m.Path), "/")
+		if err := addModule(modules, name, string(m.Raw)); err != nil {
+			return nil, "", fmt.Errorf("reading bundle: %w", err)
+		}
+	}
+
+	return modules, b.Manifest.Revision, nil
+}
+
+// addModule adds the module under the specified name. Two modules with the
+// same name would silently replace each other, so that is an error.
+func addModule(modules map[string]string, name string, module string) error {
+	if _, exists := modules[name]; exists {
+		return fmt.Errorf("duplicate policy module %q", name)
+	}
+	modules[name] = module
+
+	return nil
+}
+
+// sourceFingerprint returns a value that changes when the files of the
+// source change.
+func sourceFingerprint(source string) (string, error) {
+	if source == "" {
+		return "", nil
+	}
+
+	var b strings.Builder
+
+	walk := func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", p, fi.Size(), fi.ModTime().UnixNano())
+
+		return nil
+	}
+
+	if err := filepath.WalkDir(source, walk); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// hashModules returns a revision for a set of modules based on their content.
+func hashModules(modules map[string]string) string {
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s\x00%s\x00", name, modules[name])
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// prepareQueries compiles a query for every rule against the set of modules.
+// A prepared query is safe for concurrent use, so compiling only happens once
+// instead of on every evaluation.
+func prepareQueries(ctx context.Context, modules map[string]string, rules []string) (map[string]rego.PreparedEvalQuery, error) {
+	parsed := make([]*ast.Module, 0, len(modules))
+	options := []func(*rego.Rego){verifyEdDSA}
+
+	for name, module := range modules {
+		m, err := ast.ParseModule(name, module)
+		if err != nil {
+			return nil, fmt.Errorf("parsing policy[%s]: %w", name, err)
+		}
+		parsed = append(parsed, m)
+		options = append(options, rego.Module(name, module))
+	}
+
+	queries := make(map[string]rego.PreparedEvalQuery, len(rules))
+
+	for _, rule := range rules {
+
+		// A query for a rule the policies don't define compiles without
+		// error and is always denied, so check the rule exists.
+		if !definesRule(parsed, rule) {
+			return nil, fmt.Errorf("rule[%s] not defined in the policies", rule)
+		}
+
+		query := fmt.Sprintf("x = data.%s.%s", opaPackage, rule)
+
+		q, err := rego.New(append(options, rego.Query(query))...).PrepareForEval(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("preparing rule[%s]: %w", rule, err)
+		}
+
+		queries[rule] = q
+	}
+
+	return queries, nil
+}
+
+// definesRule reports whether one of the modules in the package used for
+// queries defines the specified rule.
+func definesRule(modules []*ast.Module, rule string) bool {
+	pkg := "data." + opaPackage
+
+	for _, m := range modules {
+		if m.Package.Path.String() != pkg {
+			continue
+		}
+
+		for _, r := range m.Rules {
+			if r.Head.Name.String() == rule {
+				return true
+			}
+		}
+	}
+
+	return false
+}
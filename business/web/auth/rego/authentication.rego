@@ -1,20 +1,76 @@
 package ardan.rego
 
 default auth = false
+default signature = false
 
-# This function decodes and verifies the JWT, it also makes sure that it hasn't expired etc. 
+# This function verifies the signature of the JWT and checks the claims. The
+# claims are checked by hand, rather than by io.jwt.decode_verify, so leeway
+# can be applied to the time based claims.
 auth {
 	jwt_valid
 }
 
-jwt_valid := valid {
-	[valid, header, payload] := decoded_jwt
+# This rule only verifies the signature. It's used to explain why a token
+# was rejected.
+signature {
+	signature_valid
 }
 
-decoded_jwt := [valid, header, payload] {
-	[valid, header, payload] := io.jwt.decode_verify(input.Token, {
-		"cert": input.Key,
-		"alg": "RS256",
-        "iss": "service project",
-	})
+jwt_valid {
+	signature_valid
+	[header, payload, _] := io.jwt.decode(input.Token)
+	header.alg == input.Alg
+	claims_valid(payload)
+}
+
+# The algorithm is provided by the caller based on the type of the key, the
+# token doesn't get to choose how it's verified.
+signature_valid {
+	input.Alg == "RS256"
+	io.jwt.verify_rs256(input.Token, input.Key)
+}
+
+signature_valid {
+	input.Alg == "ES256"
+	io.jwt.verify_es256(input.Token, input.Key)
+}
+
+# The io.jwt built-ins don't support Ed25519 keys. The signature is verified
+# by a built-in provided by the auth package.
+signature_valid {
+	input.Alg == "EdDSA"
+	ardan.verify_eddsa(input.Token, input.Key)
+}
+
+claims_valid(payload) {
+	payload.iss == input.Issuer
+	audience_valid(payload)
+	now := time.now_ns()
+	now < (payload.exp * 1000000000) + input.Leeway
+	not not_yet_valid(payload, now)
+	not issued_in_future(payload, now)
+}
+
+not_yet_valid(payload, now) {
+	now + input.Leeway < payload.nbf * 1000000000
+}
+
+issued_in_future(payload, now) {
+	now + input.Leeway < payload.iat * 1000000000
+}
+
+# No audience configured means the audience isn't checked. Otherwise the
+# token must be issued for at least one of the configured audiences.
+audience_valid(payload) {
+	count(input.Audience) == 0
+}
+
+audience_valid(payload) {
+	is_string(payload.aud)
+	payload.aud == input.Audience[_]
+}
+
+audience_valid(payload) {
+	is_array(payload.aud)
+	payload.aud[_] == input.Audience[_]
 }
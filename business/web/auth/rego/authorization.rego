@@ -1,27 +1,59 @@
 package ardan.rego
 
 default allowAny = false
-default allowOnlyUser = false
-default allowOnlyAdmin = false
+default allowUserFields = false
+default allowPermission = false
+default allowPermissionOrOwner = false
 
-roleUser := "USER"
-roleAdmin := "ADMIN"
-roleAll := {roleAdmin, roleUser}
+# Roles are managed in the database, any role is enough to be allowed.
+allowAny {
+	count(input.Roles) > 0
+}
 
+# API keys don't carry roles, any permission is enough to be allowed.
 allowAny {
-	roles_from_claims := {role | role := input.Roles[_]}
-	input_role_is_in_claim := roleAll & roles_from_claims
-	count(input_role_is_in_claim) > 0
+	count(input.Permissions) > 0
+}
+
+# The permission required by the resource is provided by the caller. The
+# permissions come from the roles granted to the subject.
+allowPermission {
+	input.Permission != ""
+	input.Permission == input.Permissions[_]
+}
+
+allowPermissionOrOwner {
+	allowPermission
+}
+
+allowPermissionOrOwner {
+	input.Owner != ""
+	input.Owner == input.Subject
+}
+
+# These are the user fields only a subject that can manage users is allowed
+# to change.
+restrictedUserFields := {"roles", "enabled"}
+
+# These are the user fields that can't be changed with an impersonated token,
+# whoever the subject is.
+impersonationRestrictedUserFields := {"password", "roles"}
+
+allowUserFields {
+	not impersonation_restricted
+	input.Permissions[_] == "users:write"
 }
 
-allowOnlyUser {
-	roles_from_claims := {role | role := input.Roles[_]}
-	input_role_is_in_claim := {roleUser} & roles_from_claims
-	count(input_role_is_in_claim) > 0
+allowUserFields {
+	not impersonation_restricted
+	fields_from_input := {field | field := input.Fields[_]}
+	input_field_is_restricted := restrictedUserFields & fields_from_input
+	count(input_field_is_restricted) == 0
 }
 
-allowOnlyAdmin {
-	roles_from_claims := {role | role := input.Roles[_]}
-	input_role_is_in_claim := {roleAdmin} & roles_from_claims
-	count(input_role_is_in_claim) > 0
+impersonation_restricted {
+	input.Actor != ""
+	fields_from_input := {field | field := input.Fields[_]}
+	input_field_is_restricted := impersonationRestrictedUserFields & fields_from_input
+	count(input_field_is_restricted) > 0
 }
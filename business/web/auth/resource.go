@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"context"
+)
+
+// Resource represents the request being authorized. Owner is the subject
+// that owns the resource being accessed and is empty when the resource
+// doesn't have an owner. Permission is the permission required to access the
+// resource and is empty when the rule doesn't check one.
+type Resource struct {
+	Method     string
+	Route      string
+	Params     map[string]string
+	Owner      string
+	Permission string
+}
+
+// resourceKey is used to store/retrieve a Resource value from a context.Context.
+const resourceKey ctxKey = 2
+
+// SetResource stores the resource in the context.
+func SetResource(ctx context.Context, res Resource) context.Context {
+	return context.WithValue(ctx, resourceKey, res)
+}
+
+// GetResource returns the resource from the context.
+func GetResource(ctx context.Context) Resource {
+	v, ok := ctx.Value(resourceKey).(Resource)
+	if !ok {
+		return Resource{}
+	}
+	return v
+}
+
+// authorizationInput builds the input for an authorization rule from the
+// claims and the resource stored in the context.
+func authorizationInput(ctx context.Context, claims Claims) map[string]any {
+	res := GetResource(ctx)
+
+	params := res.Params
+	if params == nil {
+		params = map[string]string{}
+	}
+
+	permissions := claims.Permissions
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	var actor string
+	if claims.Impersonated() {
+		actor = claims.Actor()
+	}
+
+	input := map[string]any{
+		"Subject":     claims.Subject,
+		"Actor":       actor,
+		"Tenant":      claims.TenantID,
+		"Roles":       claims.Roles,
+		"Permissions": permissions,
+		"Method":      res.Method,
+		"Route":       res.Route,
+		"Params":      params,
+		"Owner":       res.Owner,
+		"Permission":  res.Permission,
+	}
+
+	return input
+}
@@ -7,9 +7,12 @@ import (
 // These the current set of rules we have for auth.
 const (
 	RuleAuthenticate = "auth"
+	RuleSignature    = "signature"
 	RuleAny          = "allowAny"
-	RuleAdminOnly    = "allowOnlyAdmin"
-	RuleUserOnly     = "allowOnlyUser"
+	RuleUserFields   = "allowUserFields"
+
+	RulePermission        = "allowPermission"
+	RulePermissionOrOwner = "allowPermissionOrOwner"
 )
 
 // Package name of our rego code.
@@ -25,3 +28,21 @@ var (
 	//go:embed rego/authorization.rego
 	opaAuthorization string
 )
+
+// embeddedPolicies are the policies compiled into the binary, keyed by the
+// file name that can be used to replace them.
+var embeddedPolicies = map[string]string{
+	"authentication.rego": opaAuthentication,
+	"authorization.rego":  opaAuthorization,
+}
+
+// ruleNames is the set of rules a query is prepared for. Every rule must be
+// defined by the policies.
+var ruleNames = []string{
+	RuleAuthenticate,
+	RuleSignature,
+	RuleAny,
+	RuleUserFields,
+	RulePermission,
+	RulePermissionOrOwner,
+}
@@ -0,0 +1,261 @@
+package decisionlog_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/web/auth"
+	"github.com/ardanlabs/service/business/web/decisionlog"
+	"go.uber.org/zap"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decisions.log")
+
+	sink, err := decisionlog.NewFile(zap.NewNop().Sugar(), path)
+	if err != nil {
+		t.Fatalf("Should be able to construct the sink : %s", err)
+	}
+
+	t.Log("Given the need to write decisions to a file.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen logging decisions.", testID)
+		{
+			sink.Log(auth.Decision{DecisionID: "1"})
+			sink.Log(auth.Decision{DecisionID: "2"})
+
+			if err := sink.Close(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to close the file : %s.", failed, testID, err)
+			}
+
+			ids := readFile(t, path)
+			if len(ids) != 2 || ids[0] != "1" || ids[1] != "2" {
+				t.Fatalf("\t%s\tTest %d:\tShould write one decision per line : %v.", failed, testID, ids)
+			}
+			t.Logf("\t%s\tTest %d:\tShould write one decision per line.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen logging decisions once the file is closed.", testID)
+		{
+			sink.Log(auth.Decision{DecisionID: "3"})
+
+			if err := sink.Close(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to close the file again : %s.", failed, testID, err)
+			}
+
+			if ids := readFile(t, path); len(ids) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould discard the decision : %v.", failed, testID, ids)
+			}
+			t.Logf("\t%s\tTest %d:\tShould discard the decision.", success, testID)
+		}
+	}
+}
+
+func Test_HTTP(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]auth.Decision
+	var encodings []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		batch, err := readBatch(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		batches = append(batches, batch)
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	sink := decisionlog.NewHTTP(decisionlog.HTTPConfig{
+		Log:           zap.NewNop().Sugar(),
+		URL:           srv.URL,
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	})
+
+	t.Log("Given the need to send decisions to a decision log service.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen logging more decisions than fit in a batch.", testID)
+		{
+			for i := 0; i < 5; i++ {
+				sink.Log(auth.Decision{DecisionID: fmt.Sprint(i)})
+			}
+
+			if err := sink.Close(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to close the sink : %s.", failed, testID, err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			sizes := make([]int, len(batches))
+			for i, batch := range batches {
+				sizes[i] = len(batch)
+			}
+			if fmt.Sprint(sizes) != "[2 2 1]" {
+				t.Fatalf("\t%s\tTest %d:\tShould send full batches and the rest on close : %v.", failed, testID, sizes)
+			}
+			t.Logf("\t%s\tTest %d:\tShould send full batches and the rest on close.", success, testID)
+
+			for _, encoding := range encodings {
+				if encoding != "gzip" {
+					t.Fatalf("\t%s\tTest %d:\tShould compress the batches : %q.", failed, testID, encoding)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould compress the batches.", success, testID)
+
+			var n int
+			for _, batch := range batches {
+				for _, d := range batch {
+					if d.DecisionID != fmt.Sprint(n) {
+						t.Fatalf("\t%s\tTest %d:\tShould send the decisions in order : got %s, exp %d.", failed, testID, d.DecisionID, n)
+					}
+					n++
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould send the decisions in order.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen logging decisions once the sink is closed.", testID)
+		{
+			sink.Log(auth.Decision{DecisionID: "late"})
+
+			if err := sink.Close(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to close the sink again : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould discard the decision.", success, testID)
+		}
+	}
+}
+
+func Test_HTTPBufferFull(t *testing.T) {
+	received := make(chan []auth.Decision, 10)
+	release := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		batch, err := readBatch(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received <- batch
+		<-release
+	}))
+	defer srv.Close()
+
+	sink := decisionlog.NewHTTP(decisionlog.HTTPConfig{
+		Log:           zap.NewNop().Sugar(),
+		URL:           srv.URL,
+		BatchSize:     1,
+		BufferSize:    1,
+		FlushInterval: time.Hour,
+	})
+
+	t.Log("Given the need to never block a request on the decision log.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the decision log service is slow.", testID)
+		{
+			sink.Log(auth.Decision{DecisionID: "sending"})
+
+			select {
+			case <-received:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("\t%s\tTest %d:\tShould send the first decision.", failed, testID)
+			}
+
+			logged := make(chan struct{})
+			go func() {
+				sink.Log(auth.Decision{DecisionID: "buffered"})
+				sink.Log(auth.Decision{DecisionID: "dropped"})
+				close(logged)
+			}()
+
+			select {
+			case <-logged:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("\t%s\tTest %d:\tShould not block when the buffer is full.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not block when the buffer is full.", success, testID)
+
+			close(release)
+			if err := sink.Close(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to close the sink : %s.", failed, testID, err)
+			}
+			close(received)
+
+			var ids []string
+			for batch := range received {
+				for _, d := range batch {
+					ids = append(ids, d.DecisionID)
+				}
+			}
+			if fmt.Sprint(ids) != "[buffered]" {
+				t.Fatalf("\t%s\tTest %d:\tShould drop the decision that didn't fit : %v.", failed, testID, ids)
+			}
+			t.Logf("\t%s\tTest %d:\tShould drop the decision that didn't fit.", success, testID)
+		}
+	}
+}
+
+// =============================================================================
+
+// readFile returns the ids of the decisions written to the file.
+func readFile(t *testing.T, path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Should be able to open the file : %s", err)
+	}
+	defer f.Close()
+
+	var ids []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var d auth.Decision
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			t.Fatalf("Should be able to unmarshal the decision : %s", err)
+		}
+		ids = append(ids, d.DecisionID)
+	}
+
+	return ids
+}
+
+// readBatch decodes the gzip compressed batch of decisions in the request.
+func readBatch(r *http.Request) ([]auth.Decision, error) {
+	zr, err := gzip.NewReader(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var batch []auth.Decision
+	if err := json.NewDecoder(zr).Decode(&batch); err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
@@ -0,0 +1,68 @@
+// Package decisionlog provides sinks that store the policy decisions made by
+// the auth package outside of the service log.
+package decisionlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/ardanlabs/service/business/web/auth"
+	"go.uber.org/zap"
+)
+
+// File writes decisions to a file as one JSON document per line.
+type File struct {
+	log *zap.SugaredLogger
+
+	mu     sync.Mutex
+	file   *os.File
+	enc    *json.Encoder
+	closed bool
+}
+
+// NewFile constructs a sink that appends decisions to the specified file.
+// The file is created when it doesn't exist.
+func NewFile(log *zap.SugaredLogger, path string) (*File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("opening decision log: %w", err)
+	}
+
+	f := File{
+		log:  log,
+		file: file,
+		enc:  json.NewEncoder(file),
+	}
+
+	return &f, nil
+}
+
+// Log writes the decision to the file. Decisions made while the service is
+// shutting down are discarded once the file is closed.
+func (f *File) Log(d auth.Decision) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return
+	}
+
+	if err := f.enc.Encode(d); err != nil {
+		f.log.Errorw("decisionlog", "status", "write failed", "decision_id", d.DecisionID, "ERROR", err)
+	}
+}
+
+// Close closes the file.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+
+	return f.file.Close()
+}
@@ -0,0 +1,197 @@
+package decisionlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ardanlabs/service/business/web/auth"
+	"go.uber.org/zap"
+)
+
+// Set of default values used when none are configured.
+const (
+	DefaultBatchSize     = 100
+	DefaultBufferSize    = 10000
+	DefaultFlushInterval = 5 * time.Second
+)
+
+// HTTPConfig represents the information required to send decisions to a
+// service that accepts OPA decision logs.
+type HTTPConfig struct {
+	Log           *zap.SugaredLogger
+	URL           string
+	Client        *http.Client
+	BatchSize     int
+	BufferSize    int
+	FlushInterval time.Duration
+}
+
+// HTTP sends decisions in batches to an endpoint that accepts OPA decision
+// logs. A batch is a gzip compressed JSON array of decisions that is sent
+// when it's full or the flush interval passes. Decisions are buffered so
+// logging never blocks a request, when the buffer is full decisions are
+// dropped.
+type HTTP struct {
+	log           *zap.SugaredLogger
+	url           string
+	client        *http.Client
+	batchSize     int
+	flushInterval time.Duration
+	decisions     chan auth.Decision
+	shutdown      chan struct{}
+	done          chan struct{}
+	once          sync.Once
+}
+
+// NewHTTP constructs a sink that sends decisions to the configured URL and
+// starts sending in the background.
+func NewHTTP(cfg HTTPConfig) *HTTP {
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	bufferSize := cfg.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+
+	flushInterval := cfg.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = DefaultFlushInterval
+	}
+
+	h := HTTP{
+		log:           cfg.Log,
+		url:           cfg.URL,
+		client:        client,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		decisions:     make(chan auth.Decision, bufferSize),
+		shutdown:      make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	go h.run()
+
+	return &h
+}
+
+// Log queues the decision to be sent. Decisions made while the service is
+// shutting down are discarded once Close is called.
+func (h *HTTP) Log(d auth.Decision) {
+	select {
+	case <-h.shutdown:
+		return
+	default:
+	}
+
+	select {
+	case h.decisions <- d:
+	default:
+		h.log.Errorw("decisionlog", "status", "buffer full, decision dropped", "decision_id", d.DecisionID)
+	}
+}
+
+// Close sends the decisions that are queued and stops the sink. The channel
+// of decisions is never closed since requests can still be logging.
+func (h *HTTP) Close() error {
+	h.once.Do(func() {
+		close(h.shutdown)
+	})
+	<-h.done
+
+	return nil
+}
+
+// =============================================================================
+
+func (h *HTTP) run() {
+	defer close(h.done)
+
+	ticker := time.NewTicker(h.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]auth.Decision, 0, h.batchSize)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		if err := h.send(batch); err != nil {
+			h.log.Errorw("decisionlog", "status", "send failed", "url", h.url, "decisions", len(batch), "ERROR", err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case d := <-h.decisions:
+			batch = append(batch, d)
+			if len(batch) >= h.batchSize {
+				flush()
+			}
+
+		case <-ticker.C:
+			flush()
+
+		case <-h.shutdown:
+			for {
+				select {
+				case d := <-h.decisions:
+					batch = append(batch, d)
+					if len(batch) >= h.batchSize {
+						flush()
+					}
+
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send posts the batch of decisions to the endpoint.
+func (h *HTTP) send(batch []auth.Decision) error {
+	var body bytes.Buffer
+
+	zw := gzip.NewWriter(&body)
+	if err := json.NewEncoder(zw).Encode(batch); err != nil {
+		return fmt.Errorf("encoding: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("compressing: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, h.url, &body)
+	if err != nil {
+		return fmt.Errorf("request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return nil
+}
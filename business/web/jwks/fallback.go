@@ -0,0 +1,59 @@
+package jwks
+
+// KeyLookup declares the behavior needed to look up the keys used to sign
+// and verify tokens.
+type KeyLookup interface {
+	PrivateKeyPEM(kid string) (pem string, err error)
+	PublicKeyPEM(kid string) (pem string, err error)
+}
+
+// revisioner is implemented by a KeyLookup whose keys can change while the
+// service is running.
+type revisioner interface {
+	Revision() uint64
+}
+
+// Fallback implements the auth.KeyLookup interface for a service that signs
+// tokens with its own keys and also trusts the tokens issued by another
+// service. Public keys are looked up locally first and in the remote key set
+// when the kid isn't known locally. Private keys only come from the local
+// lookup.
+type Fallback struct {
+	local  KeyLookup
+	remote *Remote
+}
+
+// NewFallback constructs a key lookup that falls back to the remote key set.
+func NewFallback(local KeyLookup, remote *Remote) *Fallback {
+	return &Fallback{
+		local:  local,
+		remote: remote,
+	}
+}
+
+// PrivateKeyPEM returns the local private key for the specified kid.
+func (f *Fallback) PrivateKeyPEM(kid string) (string, error) {
+	return f.local.PrivateKeyPEM(kid)
+}
+
+// PublicKeyPEM returns the public key for the specified kid from the local
+// lookup or, when it isn't known locally, from the remote key set.
+func (f *Fallback) PublicKeyPEM(kid string) (string, error) {
+	if pem, err := f.local.PublicKeyPEM(kid); err == nil {
+		return pem, nil
+	}
+
+	return f.remote.PublicKeyPEM(kid)
+}
+
+// Revision returns a value that changes every time the local or the remote
+// keys change. Both revisions only ever increase, so their sum changes when
+// either of them does.
+func (f *Fallback) Revision() uint64 {
+	var revision uint64
+	if rv, ok := f.local.(revisioner); ok {
+		revision = rv.Revision()
+	}
+
+	return revision + f.remote.Revision()
+}
@@ -0,0 +1,226 @@
+// Package jwks provides support for publishing public keys as a JSON Web Key
+// Set and for trusting the keys published by another service.
+package jwks
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// PublicKeys declares the behavior needed to publish a set of public keys.
+type PublicKeys interface {
+	KIDs() []string
+	PublicKeyPEM(kid string) (pem string, err error)
+}
+
+// Key represents a single public key as defined by RFC 7517.
+type Key struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// Set represents a JSON Web Key Set.
+type Set struct {
+	Keys []Key `json:"keys"`
+}
+
+// NewSet constructs a key set from the public keys.
+func NewSet(keys PublicKeys) (Set, error) {
+	set := Set{
+		Keys: []Key{},
+	}
+
+	for _, kid := range keys.KIDs() {
+		pem, err := keys.PublicKeyPEM(kid)
+		if err != nil {
+			return Set{}, fmt.Errorf("public key: kid[%s]: %w", kid, err)
+		}
+
+		key, err := FromPEM(kid, pem)
+		if err != nil {
+			return Set{}, fmt.Errorf("kid[%s]: %w", kid, err)
+		}
+
+		set.Keys = append(set.Keys, key)
+	}
+
+	return set, nil
+}
+
+// Key returns the key with the specified kid.
+func (s Set) Key(kid string) (Key, bool) {
+	for _, key := range s.Keys {
+		if key.KeyID == kid {
+			return key, true
+		}
+	}
+
+	return Key{}, false
+}
+
+// FromPEM converts a PEM encoded public key into a key used for verifying
+// signatures.
+func FromPEM(kid string, publicPEM string) (Key, error) {
+	block, _ := pem.Decode([]byte(publicPEM))
+	if block == nil {
+		return Key{}, errors.New("invalid key: key must be a PEM encoded public key")
+	}
+
+	parsedKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return Key{}, fmt.Errorf("parsing public key: %w", err)
+	}
+
+	switch pub := parsedKey.(type) {
+	case *rsa.PublicKey:
+		key := Key{
+			KeyType:   "RSA",
+			KeyID:     kid,
+			Algorithm: "RS256",
+			Use:       "sig",
+			N:         encode(pub.N.Bytes()),
+			E:         encode(big.NewInt(int64(pub.E)).Bytes()),
+		}
+		return key, nil
+
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return Key{}, fmt.Errorf("unsupported curve %s", pub.Curve.Params().Name)
+		}
+
+		// The coordinates are padded to the size of the curve.
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		key := Key{
+			KeyType:   "EC",
+			KeyID:     kid,
+			Algorithm: "ES256",
+			Use:       "sig",
+			Curve:     "P-256",
+			X:         encode(pub.X.FillBytes(make([]byte, size))),
+			Y:         encode(pub.Y.FillBytes(make([]byte, size))),
+		}
+		return key, nil
+
+	case ed25519.PublicKey:
+		key := Key{
+			KeyType:   "OKP",
+			KeyID:     kid,
+			Algorithm: "EdDSA",
+			Use:       "sig",
+			Curve:     "Ed25519",
+			X:         encode(pub),
+		}
+		return key, nil
+	}
+
+	return Key{}, fmt.Errorf("unsupported key type %T", parsedKey)
+}
+
+// PEM converts the key into a PEM encoded public key.
+func (k Key) PEM() (string, error) {
+	var pub any
+
+	switch k.KeyType {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return "", fmt.Errorf("decoding modulus: %w", err)
+		}
+
+		e, err := decode(k.E)
+		if err != nil {
+			return "", fmt.Errorf("decoding exponent: %w", err)
+		}
+
+		pub = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+
+	case "EC":
+		if k.Curve != "P-256" {
+			return "", fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+
+		x, err := decode(k.X)
+		if err != nil {
+			return "", fmt.Errorf("decoding x coordinate: %w", err)
+		}
+
+		y, err := decode(k.Y)
+		if err != nil {
+			return "", fmt.Errorf("decoding y coordinate: %w", err)
+		}
+
+		ecKey := ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !ecKey.Curve.IsOnCurve(ecKey.X, ecKey.Y) {
+			return "", errors.New("invalid key: point is not on the curve")
+		}
+		pub = &ecKey
+
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return "", fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+
+		x, err := decode(k.X)
+		if err != nil {
+			return "", fmt.Errorf("decoding public key: %w", err)
+		}
+
+		if len(x) != ed25519.PublicKeySize {
+			return "", errors.New("invalid key: wrong size for an Ed25519 key")
+		}
+		pub = ed25519.PublicKey(x)
+
+	default:
+		return "", fmt.Errorf("unsupported key type %q", k.KeyType)
+	}
+
+	asn1Bytes, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("marshaling public key: %w", err)
+	}
+
+	publicBlock := pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: asn1Bytes,
+	}
+
+	var buf bytes.Buffer
+	if err := pem.Encode(&buf, &publicBlock); err != nil {
+		return "", fmt.Errorf("encoding to public PEM: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// =============================================================================
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,340 @@
+package jwks_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/web/jwks"
+	"go.uber.org/zap"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_RoundTrip(t *testing.T) {
+	keys := publicKeys{
+		"rsa":     publicPEM(t, rsaKey(t)),
+		"ecdsa":   publicPEM(t, ecdsaKey(t)),
+		"ed25519": publicPEM(t, ed25519Key(t)),
+	}
+
+	algs := map[string]string{
+		"rsa":     "RS256",
+		"ecdsa":   "ES256",
+		"ed25519": "EdDSA",
+	}
+
+	t.Log("Given the need to publish public keys as a JSON Web Key Set.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen building a key set from PEM encoded keys.", testID)
+		{
+			set, err := jwks.NewSet(keys)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to build the key set : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to build the key set.", success, testID)
+
+			data, err := json.Marshal(set)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to marshal the key set : %s.", failed, testID, err)
+			}
+
+			var got jwks.Set
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the key set : %s.", failed, testID, err)
+			}
+
+			for kid, want := range keys {
+				key, exists := got.Key(kid)
+				if !exists {
+					t.Fatalf("\t%s\tTest %d:\tShould find the %s key in the set.", failed, testID, kid)
+				}
+
+				if key.Algorithm != algs[kid] || key.Use != "sig" {
+					t.Fatalf("\t%s\tTest %d:\tShould publish the %s key with alg %s and use sig : %+v.", failed, testID, kid, algs[kid], key)
+				}
+
+				pem, err := key.PEM()
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to convert the %s key back to PEM : %s.", failed, testID, kid, err)
+				}
+
+				if pem != want {
+					t.Logf("\t\tTest %d:\tGot: %s", testID, pem)
+					t.Logf("\t\tTest %d:\tExp: %s", testID, want)
+					t.Fatalf("\t%s\tTest %d:\tShould get back the same %s key.", failed, testID, kid)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the same keys with the right alg and use.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen converting keys that aren't supported.", testID)
+		{
+			if _, err := jwks.FromPEM("bad", "not a pem"); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to convert a value that isn't PEM.", failed, testID)
+			}
+
+			p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+			if err != nil {
+				t.Fatalf("Should be able to generate a key : %s", err)
+			}
+
+			if _, err := jwks.FromPEM("p384", publicPEM(t, p384)); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to convert a P-384 key.", failed, testID)
+			}
+
+			key := jwks.Key{KeyType: "EC", Curve: "P-256", X: "AQ", Y: "AQ"}
+			if _, err := key.PEM(); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to convert a point that isn't on the curve.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the keys.", success, testID)
+		}
+	}
+}
+
+func Test_Remote(t *testing.T) {
+	current := publicKeys{"first": publicPEM(t, ed25519Key(t))}
+
+	var mu sync.Mutex
+	var fetches int32
+	var release chan struct{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+
+		mu.Lock()
+		keys := current
+		wait := release
+		mu.Unlock()
+
+		if wait != nil {
+			<-wait
+		}
+
+		set, err := jwks.NewSet(keys)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(set)
+	}))
+	defer srv.Close()
+
+	remote := jwks.NewRemote(jwks.RemoteConfig{
+		Log:        zap.NewNop().Sugar(),
+		URL:        srv.URL,
+		MinRefresh: time.Millisecond,
+	})
+
+	t.Log("Given the need to trust the keys published by another service.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen looking up a published key.", testID)
+		{
+			pem, err := remote.PublicKeyPEM("first")
+			if err != nil || pem != current["first"] {
+				t.Fatalf("\t%s\tTest %d:\tShould get the published key : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the published key.", success, testID)
+
+			if _, err := remote.PublicKeyPEM("first"); err != nil || atomic.LoadInt32(&fetches) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould use the cached key set : fetches[%d] : %v.", failed, testID, fetches, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould use the cached key set.", success, testID)
+
+			if _, err := remote.PrivateKeyPEM("first"); !errors.Is(err, jwks.ErrNoPrivateKey) {
+				t.Fatalf("\t%s\tTest %d:\tShould not provide private keys : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not provide private keys.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen the other service adds a key.", testID)
+		{
+			revision := remote.Revision()
+
+			mu.Lock()
+			current = publicKeys{"first": current["first"], "second": publicPEM(t, ecdsaKey(t))}
+			mu.Unlock()
+
+			time.Sleep(2 * time.Millisecond)
+
+			pem, err := remote.PublicKeyPEM("second")
+			if err != nil || pem != current["second"] {
+				t.Fatalf("\t%s\tTest %d:\tShould discover the new key : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould discover the new key.", success, testID)
+
+			if remote.Revision() == revision {
+				t.Fatalf("\t%s\tTest %d:\tShould change the revision.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould change the revision.", success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen the other service is slow.", testID)
+		{
+			mu.Lock()
+			release = make(chan struct{})
+			mu.Unlock()
+
+			time.Sleep(2 * time.Millisecond)
+
+			done := make(chan error, 1)
+			go func() {
+				_, err := remote.PublicKeyPEM("unknown")
+				done <- err
+			}()
+
+			// Wait for the fetch of the unknown kid to be in flight.
+			for atomic.LoadInt32(&fetches) < 3 {
+				time.Sleep(time.Millisecond)
+			}
+
+			lookup := make(chan error, 1)
+			go func() {
+				_, err := remote.PublicKeyPEM("first")
+				lookup <- err
+			}()
+
+			select {
+			case err := <-lookup:
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould get the known key : %s.", failed, testID, err)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("\t%s\tTest %d:\tShould not block the lookup of a known key.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not block the lookup of a known key.", success, testID)
+
+			mu.Lock()
+			close(release)
+			release = nil
+			mu.Unlock()
+
+			if err := <-done; err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not find an unknown key.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not find an unknown key.", success, testID)
+		}
+	}
+}
+
+func Test_Fallback(t *testing.T) {
+	remoteKeys := publicKeys{"remote": publicPEM(t, ed25519Key(t))}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		set, _ := jwks.NewSet(remoteKeys)
+		json.NewEncoder(w).Encode(set)
+	}))
+	defer srv.Close()
+
+	local := publicKeys{"local": publicPEM(t, ed25519Key(t))}
+	remote := jwks.NewRemote(jwks.RemoteConfig{Log: zap.NewNop().Sugar(), URL: srv.URL})
+	lookup := jwks.NewFallback(local, remote)
+
+	t.Log("Given the need to trust local keys and the keys of another service.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen looking up keys.", testID)
+		{
+			if pem, err := lookup.PublicKeyPEM("local"); err != nil || pem != local["local"] {
+				t.Fatalf("\t%s\tTest %d:\tShould get the local key : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the local key.", success, testID)
+
+			if pem, err := lookup.PublicKeyPEM("remote"); err != nil || pem != remoteKeys["remote"] {
+				t.Fatalf("\t%s\tTest %d:\tShould get the remote key : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the remote key.", success, testID)
+
+			if _, err := lookup.PrivateKeyPEM("remote"); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not get a private key for a remote kid.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not get a private key for a remote kid.", success, testID)
+		}
+	}
+}
+
+// =============================================================================
+
+// publicKeys is a set of public keys by kid.
+type publicKeys map[string]string
+
+func (pk publicKeys) KIDs() []string {
+	kids := make([]string, 0, len(pk))
+	for kid := range pk {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+	return kids
+}
+
+func (pk publicKeys) PublicKeyPEM(kid string) (string, error) {
+	pem, exists := pk[kid]
+	if !exists {
+		return "", fmt.Errorf("kid[%s] not found", kid)
+	}
+	return pem, nil
+}
+
+func (pk publicKeys) PrivateKeyPEM(kid string) (string, error) {
+	return "", errors.New("no private keys")
+}
+
+func rsaKey(t *testing.T) any {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Should be able to generate a key : %s", err)
+	}
+	return &key.PublicKey
+}
+
+func ecdsaKey(t *testing.T) any {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Should be able to generate a key : %s", err)
+	}
+	return &key.PublicKey
+}
+
+func ed25519Key(t *testing.T) any {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Should be able to generate a key : %s", err)
+	}
+	return pub
+}
+
+func publicPEM(t *testing.T, key any) string {
+	if priv, ok := key.(*ecdsa.PrivateKey); ok {
+		key = &priv.PublicKey
+	}
+
+	asn1Bytes, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("Should be able to marshal the public key : %s", err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: asn1Bytes}))
+}
@@ -0,0 +1,237 @@
+package jwks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrNoPrivateKey is returned when a private key is requested from a key
+// lookup that only has access to public keys.
+var ErrNoPrivateKey = errors.New("private keys are not available from a remote key set")
+
+// Default values for fetching a remote key set.
+const (
+	DefaultTTL        = 5 * time.Minute
+	DefaultMinRefresh = 10 * time.Second
+)
+
+// RemoteConfig represents the information required to trust the keys
+// published by another service.
+type RemoteConfig struct {
+	Log *zap.SugaredLogger
+	URL string
+
+	// Client is used to fetch the key set. A client with a short timeout is
+	// used when none is provided.
+	Client *http.Client
+
+	// TTL is how long a fetched key set is trusted before it is fetched again.
+	TTL time.Duration
+
+	// MinRefresh limits how often the key set is fetched when a token is
+	// presented with a kid that isn't in the set.
+	MinRefresh time.Duration
+}
+
+// Remote implements the auth.KeyLookup interface using the JSON Web Key Set
+// published by another service. It can only be used to verify tokens. The
+// key set is fetched without holding the lock that protects the keys, so a
+// slow service doesn't block the lookup of keys that are already known.
+type Remote struct {
+	log        *zap.SugaredLogger
+	url        string
+	client     *http.Client
+	ttl        time.Duration
+	minRefresh time.Duration
+
+	fetchMu sync.Mutex
+
+	mu       sync.RWMutex
+	keys     map[string]string
+	fetched  time.Time
+	revision uint64
+}
+
+// NewRemote constructs a key lookup for the key set at the configured URL.
+// The key set is fetched the first time a key is looked up.
+func NewRemote(cfg RemoteConfig) *Remote {
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{
+			Timeout: 5 * time.Second,
+		}
+	}
+
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	minRefresh := cfg.MinRefresh
+	if minRefresh <= 0 {
+		minRefresh = DefaultMinRefresh
+	}
+
+	return &Remote{
+		log:        cfg.Log,
+		url:        cfg.URL,
+		client:     client,
+		ttl:        ttl,
+		minRefresh: minRefresh,
+		keys:       make(map[string]string),
+	}
+}
+
+// PrivateKeyPEM always fails since a remote key set only provides public keys.
+func (r *Remote) PrivateKeyPEM(kid string) (string, error) {
+	return "", ErrNoPrivateKey
+}
+
+// PublicKeyPEM returns the public key for the specified kid. The key set is
+// fetched again when the cached set has expired or when the kid is unknown,
+// which is how keys added by the remote service are discovered.
+func (r *Remote) PublicKeyPEM(kid string) (string, error) {
+	r.mu.RLock()
+	pem, exists := r.keys[kid]
+	fetched := r.fetched
+	r.mu.RUnlock()
+
+	age := time.Since(fetched)
+
+	switch {
+	case exists && age < r.ttl:
+		return pem, nil
+
+	case !exists && age < r.minRefresh:
+		return "", fmt.Errorf("kid[%s] not found", kid)
+	}
+
+	// A known key that has expired keeps being used while another goroutine
+	// fetches the key set, so only callers that need a key we don't have
+	// wait for the remote service.
+	if exists {
+		if !r.fetchMu.TryLock() {
+			return pem, nil
+		}
+	} else {
+		r.fetchMu.Lock()
+	}
+	defer r.fetchMu.Unlock()
+
+	if err := r.refresh(); err != nil {
+
+		// Keep using the keys we have when the remote service can't be
+		// reached so a short outage doesn't fail every request.
+		if exists {
+			r.log.Errorw("jwks", "status", "refresh failed, using cached key", "url", r.url, "ERROR", err)
+			return pem, nil
+		}
+		return "", err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	pem, exists = r.keys[kid]
+	if !exists {
+		return "", fmt.Errorf("kid[%s] not found", kid)
+	}
+
+	return pem, nil
+}
+
+// Revision returns a value that changes every time the fetched set of keys
+// changes. It allows caches of the keys to know when they need to be cleared.
+func (r *Remote) Revision() uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.revision
+}
+
+// refresh fetches the key set and replaces the cached keys. The caller must
+// hold fetchMu so only one fetch happens at a time.
+func (r *Remote) refresh() error {
+
+	// Another goroutine may have refreshed the keys while we waited. The
+	// attempt is recorded even on failure so an unavailable service isn't
+	// called on every request.
+	r.mu.Lock()
+	if time.Since(r.fetched) < r.minRefresh {
+		r.mu.Unlock()
+		return nil
+	}
+	r.fetched = time.Now()
+	r.mu.Unlock()
+
+	keys, err := r.fetch()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !equalKeys(r.keys, keys) {
+		r.keys = keys
+		r.revision++
+	}
+
+	return nil
+}
+
+// fetch retrieves the key set from the remote service.
+func (r *Remote) fetch() (map[string]string, error) {
+	resp, err := r.client.Get(r.url)
+	if err != nil {
+		return nil, fmt.Errorf("fetching key set: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching key set: status %d", resp.StatusCode)
+	}
+
+	var set Set
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1024*1024)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decoding key set: %w", err)
+	}
+
+	keys := make(map[string]string, len(set.Keys))
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		pem, err := key.PEM()
+		if err != nil {
+			r.log.Errorw("jwks", "status", "skipping key", "kid", key.KeyID, "ERROR", err)
+			continue
+		}
+		keys[key.KeyID] = pem
+	}
+
+	return keys, nil
+}
+
+// equalKeys reports whether both sets hold the same keys.
+func equalKeys(a map[string]string, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for kid, pem := range a {
+		if b[kid] != pem {
+			return false
+		}
+	}
+
+	return true
+}
@@ -1,82 +1,388 @@
-// Package keystore provides a simple in-memory key store.
+// Package keystore provides an in-memory key store backed by a directory of
+// PEM encoded private keys.
+//
+// Every PEM file in the directory is loaded using the file name, without the
+// extension, as the kid. One key is active and is the only key that can be
+// used to sign tokens. Every other key is retired and can still be used to
+// verify tokens for a period of time, so tokens signed before a rotation stay
+// valid until they expire.
 package keystore
 
 import (
-	"bytes"
-	"crypto/x509"
-	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/golang-jwt/jwt/v4"
+	"github.com/ardanlabs/service/business/web/auth"
+	"go.uber.org/zap"
 )
 
-const keyFile = "zarf/keys/54bb2165-71e1-41a6-af3e-7da4a0e1e2c1.pem"
+// Set of error variables for key lookups.
+var (
+	ErrKeyNotFound = errors.New("kid not found")
+	ErrKeyRetired  = errors.New("kid is retired and can't be used for signing")
+)
+
+// DefaultRetireTTL is how long a retired key can verify tokens when no value
+// is configured. It should be at least as long as the token lifetime.
+const DefaultRetireTTL = time.Hour
+
+// Config represents the information required to construct a key store.
+type Config struct {
+	Log *zap.SugaredLogger
+	Dir string
+
+	// ActiveKID is the kid of the key used for signing. When empty, the most
+	// recently modified key in the directory is the active key, so a rotation
+	// only needs a new key file. Setting it pins the key until a restart.
+	ActiveKID string
+
+	// RetireTTL is how long a key can verify tokens once it's no longer the
+	// active key or its file has been removed.
+	RetireTTL time.Duration
+}
 
+// key represents a private key and when it was retired.
+type key struct {
+	pem     string
+	modTime time.Time
+	retired time.Time
+	evicted bool
+}
+
+// KeyStore represents an in memory store implementation of the KeyLookup
+// interface for use with the auth package.
 type KeyStore struct {
-	privatePEM map[string]string
+	log       *zap.SugaredLogger
+	dir       string
+	activeKID string
+	retireTTL time.Duration
+
+	mu       sync.RWMutex
+	keys     map[string]key
+	active   string
+	files    string
+	revision uint64
 }
 
-func New() (*KeyStore, error) {
-	file, err := os.Open(keyFile)
-	if err != nil {
-		return nil, fmt.Errorf("opening key file: %w", err)
+// New constructs a key store and loads the keys from the configured directory.
+func New(cfg Config) (*KeyStore, error) {
+	retireTTL := cfg.RetireTTL
+	if retireTTL <= 0 {
+		retireTTL = DefaultRetireTTL
 	}
-	defer file.Close()
 
-	privatePEM, err := io.ReadAll(io.LimitReader(file, 1024*1024))
-	if err != nil {
-		return nil, fmt.Errorf("reading auth private key: %w", err)
+	ks := KeyStore{
+		log:       cfg.Log,
+		dir:       cfg.Dir,
+		activeKID: cfg.ActiveKID,
+		retireTTL: retireTTL,
+		keys:      make(map[string]key),
 	}
 
-	ks := KeyStore{
-		privatePEM: map[string]string{
-			"54bb2165-71e1-41a6-af3e-7da4a0e1e2c1": string(privatePEM),
-		},
+	if err := ks.Reload(); err != nil {
+		return nil, err
 	}
 
 	return &ks, nil
 }
 
-func (k *KeyStore) PrivateKeyPEM(kid string) (pem string, err error) {
-	pem, exist := k.privatePEM[kid]
-	if !exist {
-		return "", errors.New("kid not found")
+// Reload reads the keys from the directory. The reload is rejected when a key
+// can't be parsed or the directory doesn't provide a key that can be used for
+// signing, leaving the current keys in place.
+func (ks *KeyStore) Reload() error {
+	files, err := ks.pemFiles()
+	if err != nil {
+		return err
+	}
+
+	loaded := make(map[string]key)
+	for kid, fi := range files {
+		privatePEM, err := readPEM(filepath.Join(ks.dir, fi.Name()))
+		if err != nil {
+			return fmt.Errorf("kid[%s]: %w", kid, err)
+		}
+
+		if _, err := auth.ParsePrivateKeyPEM(privatePEM); err != nil {
+			return fmt.Errorf("kid[%s]: parsing private pem: %w", kid, err)
+		}
+
+		loaded[kid] = key{
+			pem:     privatePEM,
+			modTime: fi.ModTime(),
+		}
+	}
+
+	active := ks.activeKID
+	if active == "" {
+		for kid, k := range loaded {
+			if active == "" || newer(kid, k, active, loaded[active]) {
+				active = kid
+			}
+		}
+	}
+
+	if _, exists := loaded[active]; !exists {
+		return fmt.Errorf("active kid[%s] not found in %s", active, ks.dir)
 	}
 
-	return pem, nil
+	now := time.Now()
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	for kid, k := range loaded {
+		if kid == active {
+			continue
+		}
+
+		switch prev, exists := ks.keys[kid]; {
+		case exists && !prev.retired.IsZero():
+			k.retired = prev.retired
+			k.evicted = prev.evicted
+		default:
+			k.retired = now
+		}
+		loaded[kid] = k
+	}
+
+	// Keys that have been removed from the directory can still verify tokens
+	// until they expire.
+	for kid, prev := range ks.keys {
+		if _, exists := loaded[kid]; exists {
+			continue
+		}
+
+		if prev.retired.IsZero() {
+			prev.retired = now
+		}
+		if now.Sub(prev.retired) < ks.retireTTL {
+			loaded[kid] = prev
+		}
+	}
+
+	ks.keys = loaded
+	ks.active = active
+	ks.files = signature(files)
+	ks.revision++
+
+	return nil
 }
 
-func (k *KeyStore) PublicKeyPEM(kid string) (string, error) {
-	pemStr, exist := k.privatePEM[kid]
-	if !exist {
-		return "", errors.New("kid not found")
+// Watch checks the directory for changes at the specified interval and
+// reloads the keys when a change is found. Caches are also told about retired
+// keys that have expired.
+// Calling the returned function stops the watch.
+func (ks *KeyStore) Watch(interval time.Duration) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := ks.check(); err != nil {
+					ks.log.Errorw("keystore", "status", "reload failed", "dir", ks.dir, "ERROR", err)
+				}
+
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
 	}
+}
+
+// Revision returns a value that changes every time the set of keys changes.
+// It allows caches of the keys to know when they need to be cleared.
+func (ks *KeyStore) Revision() uint64 {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return ks.revision
+}
 
-	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(pemStr))
+// ActiveKID returns the kid of the key used for signing.
+func (ks *KeyStore) ActiveKID() string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return ks.active
+}
+
+// KIDs returns the set of key ids that can be used to verify tokens.
+func (ks *KeyStore) KIDs() []string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	kids := make([]string, 0, len(ks.keys))
+	for kid, k := range ks.keys {
+		if ks.expired(k) {
+			continue
+		}
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	return kids
+}
+
+// PrivateKeyPEM searches the key store for the given kid and returns the
+// private key. Only the active key can be used for signing.
+func (ks *KeyStore) PrivateKeyPEM(kid string) (string, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	k, exists := ks.keys[kid]
+	if !exists || ks.expired(k) {
+		return "", ErrKeyNotFound
+	}
+
+	if kid != ks.active {
+		return "", ErrKeyRetired
+	}
+
+	return k.pem, nil
+}
+
+// PublicKeyPEM searches the key store for the given kid and returns the
+// public key. Retired keys are available until they expire. RSA, ECDSA P-256
+// and Ed25519 keys are supported.
+func (ks *KeyStore) PublicKeyPEM(kid string) (string, error) {
+	ks.mu.RLock()
+	k, exists := ks.keys[kid]
+	expired := ks.expired(k)
+	ks.mu.RUnlock()
+
+	if !exists || expired {
+		return "", ErrKeyNotFound
+	}
+
+	return auth.PublicKeyPEM(k.pem)
+}
+
+// =============================================================================
+
+// check reloads the keys when the files in the directory have changed or a
+// retired key has expired.
+func (ks *KeyStore) check() error {
+	files, err := ks.pemFiles()
 	if err != nil {
-		return "", err
+		return err
+	}
+
+	ks.mu.Lock()
+	changed := signature(files) != ks.files
+
+	// A retired key that expires changes the set of keys that can verify
+	// tokens, so the revision needs to change for caches to notice.
+	for kid, k := range ks.keys {
+		if ks.expired(k) && !k.evicted {
+			k.evicted = true
+			ks.keys[kid] = k
+			ks.revision++
+		}
+	}
+	ks.mu.Unlock()
+
+	if !changed {
+		return nil
+	}
+
+	if err := ks.Reload(); err != nil {
+		return err
 	}
 
-	asn1Bytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	ks.log.Infow("keystore", "status", "keys reloaded", "dir", ks.dir, "active", ks.ActiveKID())
+
+	return nil
+}
+
+// expired reports whether a retired key can no longer be used to verify
+// tokens. The caller must hold the lock.
+func (ks *KeyStore) expired(k key) bool {
+	return !k.retired.IsZero() && time.Since(k.retired) >= ks.retireTTL
+}
+
+// pemFiles returns the set of PEM files in the directory keyed by kid.
+func (ks *KeyStore) pemFiles() (map[string]os.FileInfo, error) {
+	entries, err := os.ReadDir(ks.dir)
 	if err != nil {
-		return "", fmt.Errorf("marshaling public key: %w", err)
+		return nil, fmt.Errorf("reading key directory: %w", err)
 	}
 
-	// Construct a PEM block for the public key.
-	publicBlock := pem.Block{
-		Type:  "PUBLIC KEY",
-		Bytes: asn1Bytes,
+	files := make(map[string]os.FileInfo)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".pem" {
+			continue
+		}
+
+		fi, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("file info: %w", err)
+		}
+
+		kid := strings.TrimSuffix(entry.Name(), ".pem")
+		files[kid] = fi
 	}
 
-	var b bytes.Buffer
+	return files, nil
+}
+
+// newer reports whether key a is more recent than key b. Keys with the same
+// modification time are ordered by kid so the choice doesn't depend on map
+// iteration order.
+func newer(aKID string, a key, bKID string, b key) bool {
+	if !a.modTime.Equal(b.modTime) {
+		return a.modTime.After(b.modTime)
+	}
+	return aKID > bKID
+}
 
-	// Write the public key to the public key file.
-	if err := pem.Encode(&b, &publicBlock); err != nil {
-		return "", fmt.Errorf("encoding to public file: %w", err)
+// signature returns a value that changes when any of the files change.
+func signature(files map[string]os.FileInfo) string {
+	kids := make([]string, 0, len(files))
+	for kid := range files {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	var b strings.Builder
+	for _, kid := range kids {
+		fi := files[kid]
+		fmt.Fprintf(&b, "%s:%d:%d;", kid, fi.Size(), fi.ModTime().UnixNano())
+	}
+
+	return b.String()
+}
+
+// readPEM reads the private key from the specified file.
+func readPEM(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("opening key file: %w", err)
+	}
+	defer file.Close()
+
+	privatePEM, err := io.ReadAll(io.LimitReader(file, 1024*1024))
+	if err != nil {
+		return "", fmt.Errorf("reading auth private key: %w", err)
 	}
 
-	return b.String(), nil
+	return string(privatePEM), nil
 }
@@ -0,0 +1,232 @@
+package keystore_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/service/business/web/keystore"
+	"go.uber.org/zap"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_ActiveKey(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	writeKey(t, dir, "old", now.Add(-time.Hour))
+	writeKey(t, dir, "new", now)
+
+	t.Log("Given the need to choose the key used for signing.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen no active kid is configured.", testID)
+		{
+			ks := newKeyStore(t, keystore.Config{Dir: dir})
+
+			if kid := ks.ActiveKID(); kid != "new" {
+				t.Fatalf("\t%s\tTest %d:\tShould use the newest key : got %q.", failed, testID, kid)
+			}
+			t.Logf("\t%s\tTest %d:\tShould use the newest key.", success, testID)
+
+			if _, err := ks.PrivateKeyPEM("old"); !errors.Is(err, keystore.ErrKeyRetired) {
+				t.Fatalf("\t%s\tTest %d:\tShould not sign with the older key : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not sign with the older key.", success, testID)
+
+			if _, err := ks.PublicKeyPEM("old"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould verify with the older key : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould verify with the older key.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen an active kid is configured.", testID)
+		{
+			ks := newKeyStore(t, keystore.Config{Dir: dir, ActiveKID: "old"})
+
+			if kid := ks.ActiveKID(); kid != "old" {
+				t.Fatalf("\t%s\tTest %d:\tShould use the configured key : got %q.", failed, testID, kid)
+			}
+			t.Logf("\t%s\tTest %d:\tShould use the configured key.", success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen the configured kid doesn't exist.", testID)
+		{
+			_, err := keystore.New(keystore.Config{Log: zap.NewNop().Sugar(), Dir: dir, ActiveKID: "missing"})
+			if err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to construct the key store.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to construct the key store.", success, testID)
+		}
+	}
+}
+
+func Test_Reload(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	writeKey(t, dir, "first", now.Add(-time.Hour))
+
+	ks := newKeyStore(t, keystore.Config{Dir: dir})
+
+	t.Log("Given the need to rotate keys without a restart.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a new key is added to the directory.", testID)
+		{
+			revision := ks.Revision()
+			writeKey(t, dir, "second", now)
+
+			if err := ks.Reload(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to reload the keys : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to reload the keys.", success, testID)
+
+			if kid := ks.ActiveKID(); kid != "second" {
+				t.Fatalf("\t%s\tTest %d:\tShould sign with the new key : got %q.", failed, testID, kid)
+			}
+			t.Logf("\t%s\tTest %d:\tShould sign with the new key.", success, testID)
+
+			if ks.Revision() == revision {
+				t.Fatalf("\t%s\tTest %d:\tShould change the revision.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould change the revision.", success, testID)
+
+			if kids := ks.KIDs(); len(kids) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould verify with both keys : %v.", failed, testID, kids)
+			}
+			t.Logf("\t%s\tTest %d:\tShould verify with both keys.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen a key that can't be parsed is added to the directory.", testID)
+		{
+			if err := os.WriteFile(filepath.Join(dir, "bad.pem"), []byte("not a key"), 0600); err != nil {
+				t.Fatalf("Should be able to write the key file : %s", err)
+			}
+
+			if err := ks.Reload(); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the reload.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the reload.", success, testID)
+
+			if kid := ks.ActiveKID(); kid != "second" {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the current keys : got %q.", failed, testID, kid)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the current keys.", success, testID)
+		}
+	}
+}
+
+func Test_Retire(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	writeKey(t, dir, "first", now.Add(-time.Hour))
+	writeKey(t, dir, "second", now)
+
+	const ttl = 100 * time.Millisecond
+
+	ks := newKeyStore(t, keystore.Config{Dir: dir, RetireTTL: ttl})
+
+	t.Log("Given the need to stop trusting retired keys.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a retired key is removed from the directory.", testID)
+		{
+			if err := os.Remove(filepath.Join(dir, "first.pem")); err != nil {
+				t.Fatalf("Should be able to remove the key file : %s", err)
+			}
+
+			if err := ks.Reload(); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to reload the keys : %s.", failed, testID, err)
+			}
+
+			if _, err := ks.PublicKeyPEM("first"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould still verify with the removed key : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould still verify with the removed key.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen the retired key expires.", testID)
+		{
+			revision := ks.Revision()
+
+			stop := ks.Watch(10 * time.Millisecond)
+			time.Sleep(ttl + 50*time.Millisecond)
+			stop()
+
+			if _, err := ks.PublicKeyPEM("first"); !errors.Is(err, keystore.ErrKeyNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould no longer verify with the expired key : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould no longer verify with the expired key.", success, testID)
+
+			if kids := ks.KIDs(); len(kids) != 1 || kids[0] != "second" {
+				t.Fatalf("\t%s\tTest %d:\tShould only list the active key : %v.", failed, testID, kids)
+			}
+			t.Logf("\t%s\tTest %d:\tShould only list the active key.", success, testID)
+
+			if ks.Revision() == revision {
+				t.Fatalf("\t%s\tTest %d:\tShould change the revision so caches evict the key.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould change the revision so caches evict the key.", success, testID)
+
+			if _, err := ks.PrivateKeyPEM("second"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould still sign with the active key : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould still sign with the active key.", success, testID)
+		}
+	}
+}
+
+// =============================================================================
+
+func newKeyStore(t *testing.T, cfg keystore.Config) *keystore.KeyStore {
+	cfg.Log = zap.NewNop().Sugar()
+
+	ks, err := keystore.New(cfg)
+	if err != nil {
+		t.Fatalf("Should be able to construct the key store : %s", err)
+	}
+
+	return ks
+}
+
+// writeKey generates a private key and writes it to the directory using the
+// kid as the file name with the specified modification time.
+func writeKey(t *testing.T, dir string, kid string, modTime time.Time) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Should be able to generate a key : %s", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("Should be able to marshal the key : %s", err)
+	}
+
+	path := filepath.Join(dir, kid+".pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Should be able to write the key file : %s", err)
+	}
+
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Should be able to set the key file time : %s", err)
+	}
+}
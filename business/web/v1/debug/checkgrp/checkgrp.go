@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/ardanlabs/service/business/web/auth"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
@@ -18,6 +19,7 @@ type Handlers struct {
 	Build string
 	Log   *zap.SugaredLogger
 	DB    *sqlx.DB
+	Auth  *auth.Auth
 }
 
 // Readiness checks if the database is ready and if not will return a 500 status.
@@ -85,6 +87,17 @@ func (h Handlers) Liveness(w http.ResponseWriter, r *http.Request) {
 	h.Log.Infow("liveness", "statusCode", statusCode, "method", r.Method, "path", r.URL.Path, "remoteaddr", r.RemoteAddr)
 }
 
+// Policy returns the source and revision of the authorization policies that
+// are being enforced.
+func (h Handlers) Policy(w http.ResponseWriter, r *http.Request) {
+	statusCode := http.StatusOK
+	if err := response(w, statusCode, h.Auth.PolicyStatus()); err != nil {
+		h.Log.Errorw("policy", "ERROR", err)
+	}
+
+	h.Log.Infow("policy", "statusCode", statusCode, "method", r.Method, "path", r.URL.Path, "remoteaddr", r.RemoteAddr)
+}
+
 func response(w http.ResponseWriter, statusCode int, data any) error {
 	jsonData, err := json.Marshal(data)
 	if err != nil {
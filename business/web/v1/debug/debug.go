@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/pprof"
 
+	"github.com/ardanlabs/service/business/web/auth"
 	"github.com/ardanlabs/service/business/web/v1/debug/checkgrp"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
@@ -32,16 +33,18 @@ func StandardLibraryMux() *http.ServeMux {
 // debug application routes for the service. This bypassing the use of the
 // DefaultServerMux. Using the DefaultServerMux would be a security risk since
 // a dependency could inject a handler into our service without us knowing it.
-func Mux(build string, log *zap.SugaredLogger, db *sqlx.DB) http.Handler {
+func Mux(build string, log *zap.SugaredLogger, db *sqlx.DB, a *auth.Auth) http.Handler {
 	mux := StandardLibraryMux()
 
 	cgh := checkgrp.Handlers{
 		Build: build,
 		Log:   log,
 		DB:    db,
+		Auth:  a,
 	}
 	mux.HandleFunc("/debug/readiness", cgh.Readiness)
 	mux.HandleFunc("/debug/liveness", cgh.Liveness)
+	mux.HandleFunc("/debug/policy", cgh.Policy)
 
 	return mux
 }
@@ -2,24 +2,81 @@ package mid
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/ardanlabs/service/business/core/apikey"
+	"github.com/ardanlabs/service/business/core/audit"
+	"github.com/ardanlabs/service/business/core/role"
+	"github.com/ardanlabs/service/business/core/token"
+	"github.com/ardanlabs/service/business/core/user"
+	"github.com/ardanlabs/service/business/sys/database"
+	"github.com/ardanlabs/service/business/sys/tenant"
 	"github.com/ardanlabs/service/business/web/auth"
 	"github.com/ardanlabs/service/foundation/web"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
-// Authenticate validates a JWT from the `Authorization` header.
-func Authenticate(a *auth.Auth) web.Middleware {
+// AuthenticateConfig contains the systems required to authenticate requests.
+type AuthenticateConfig struct {
+	Log    *zap.SugaredLogger
+	Auth   *auth.Auth
+	User   *user.Core
+	Role   *role.Core
+	Token  *token.Core
+	APIKey *apikey.Core
+	Audit  *audit.Core
+}
+
+// apiKeyScheme is the authorization scheme machine clients use to present an
+// API key instead of a JWT.
+const apiKeyScheme = "ApiKey"
+
+// Authenticate validates a JWT or an API key from the `Authorization` header.
+// The subject must still be an enabled user so disabling a user locks them
+// out even if they hold a token that hasn't expired. A token is also
+// rejected once it or the session it was issued for has been revoked. The
+// request is scoped to the tenant of the subject and database access is
+// restricted to the rows the subject can see. Every successful write is
+// recorded in the audit log along with the admin that made it when the token
+// is impersonated.
+func Authenticate(cfg AuthenticateConfig) web.Middleware {
 	m := func(handler web.Handler) web.Handler {
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-			claims, err := a.Authenticate(ctx, r.Header.Get("authorization"))
+			var claims auth.Claims
+			var err error
+
+			authorization := r.Header.Get("authorization")
+			parts := strings.SplitN(authorization, " ", 2)
+
+			switch {
+			case len(parts) == 2 && strings.EqualFold(parts[0], apiKeyScheme):
+				ctx, claims, err = authenticateAPIKey(ctx, cfg, parts[1], r.RemoteAddr)
+			default:
+				ctx, claims, err = authenticateToken(ctx, cfg, authorization)
+			}
+
 			if err != nil {
-				return auth.NewAuthError("authenticate: failed: %s", err)
+				return err
 			}
 
 			ctx = auth.SetClaims(ctx, claims)
 
-			return handler(ctx, w, r)
+			if err := handler(ctx, w, r); err != nil {
+				return err
+			}
+
+			if r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions {
+				recordWrite(ctx, cfg.Log, cfg.Audit, claims, r)
+			}
+
+			return nil
 		}
 
 		return h
@@ -28,9 +85,161 @@ func Authenticate(a *auth.Auth) web.Middleware {
 	return m
 }
 
-// Authorize validates that an authenticated user has at least one role from a
-// specified list. This method constructs the actual function that is used.
+// authenticateToken validates the JWT and returns the context scoped to the
+// subject of the token.
+func authenticateToken(ctx context.Context, cfg AuthenticateConfig, bearerToken string) (context.Context, auth.Claims, error) {
+	claims, err := cfg.Auth.Authenticate(ctx, bearerToken)
+	if err != nil {
+		return ctx, auth.Claims{}, auth.NewAuthError("authenticate: failed: %s", err)
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return ctx, auth.Claims{}, auth.NewAuthError("authenticate: invalid subject[%s]", claims.Subject)
+	}
+
+	ctx, err = sessionScope(ctx, claims)
+	if err != nil {
+		return ctx, auth.Claims{}, auth.NewAuthError("authenticate: %s", err)
+	}
+
+	enabled, err := cfg.User.IsEnabled(ctx, userID)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return ctx, auth.Claims{}, auth.NewAuthError("authenticate: user[%s] not found", userID)
+		}
+		return ctx, auth.Claims{}, fmt.Errorf("authenticate: user[%s] status: %w", userID, err)
+	}
+
+	if !enabled {
+		return ctx, auth.Claims{}, auth.NewAuthError("authenticate: user[%s] is disabled", userID)
+	}
+
+	jti, familyID, err := tokenIDs(claims)
+	if err != nil {
+		return ctx, auth.Claims{}, auth.NewAuthError("authenticate: %s", err)
+	}
+
+	// A token without an issue time is treated as issued at the start of
+	// time, so it's rejected once any revocation of the user happened.
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+
+	revoked, err := cfg.Token.IsRevoked(ctx, jti, familyID, userID, issuedAt)
+	if err != nil {
+		return ctx, auth.Claims{}, fmt.Errorf("authenticate: token[%s] status: %w", jti, err)
+	}
+
+	if revoked {
+		return ctx, auth.Claims{}, auth.NewAuthError("authenticate: token[%s] has been revoked", jti)
+	}
+
+	return ctx, claims, nil
+}
+
+// authenticateAPIKey validates the API key presented from the remote address
+// and returns the context scoped to the user the key belongs to. The key
+// carries the permissions it was created with that the roles of the user
+// still carry, so the key never outgrows the user.
+func authenticateAPIKey(ctx context.Context, cfg AuthenticateConfig, token string, remoteAddr string) (context.Context, auth.Claims, error) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	key, err := cfg.APIKey.Authenticate(ctx, token, net.ParseIP(host))
+	if err != nil {
+		switch {
+		case errors.Is(err, apikey.ErrInvalidKey), errors.Is(err, apikey.ErrAddressDenied):
+			return ctx, auth.Claims{}, auth.NewAuthError("authenticate: failed: %s", err)
+		default:
+			return ctx, auth.Claims{}, fmt.Errorf("authenticate: api key: %w", err)
+		}
+	}
+
+	usr, err := cfg.User.QueryByID(tenant.Set(ctx, key.TenantID), key.UserID)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return ctx, auth.Claims{}, auth.NewAuthError("authenticate: user[%s] not found", key.UserID)
+		}
+		return ctx, auth.Claims{}, fmt.Errorf("authenticate: user[%s]: %w", key.UserID, err)
+	}
+
+	if !usr.Enabled {
+		return ctx, auth.Claims{}, auth.NewAuthError("authenticate: user[%s] is disabled", usr.ID)
+	}
+
+	perms, err := cfg.Role.Permissions(ctx, usr.Roles)
+	if err != nil {
+		return ctx, auth.Claims{}, fmt.Errorf("authenticate: user[%s] permissions: %w", usr.ID, err)
+	}
+
+	// A key is authorized by its permissions alone. The roles of the user
+	// aren't carried, so a key can't be allowed by a rule that checks roles.
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject: usr.ID.String(),
+		},
+		Permissions: key.EffectivePermissions(perms),
+		TenantID:    usr.TenantID.String(),
+		APIKeyID:    key.ID.String(),
+	}
+
+	ctx, err = sessionScope(ctx, claims)
+	if err != nil {
+		return ctx, auth.Claims{}, auth.NewAuthError("authenticate: %s", err)
+	}
+
+	return ctx, claims, nil
+}
+
+// Authorize validates that an authenticated user is allowed to access the
+// requested resource using the specified rule. This method constructs the
+// actual function that is used.
 func Authorize(a *auth.Auth, rule string) web.Middleware {
+	return AuthorizeOwner(a, rule, nil)
+}
+
+// OwnerFunc returns the subject that owns the resource being requested. An
+// empty subject means the resource has no owner.
+type OwnerFunc func(ctx context.Context, r *http.Request) (string, error)
+
+// ParamOwner returns an OwnerFunc for resources whose owner is the value of
+// the specified path parameter, like a user accessing their own record.
+func ParamOwner(param string) OwnerFunc {
+	f := func(ctx context.Context, r *http.Request) (string, error) {
+		return web.Param(r, param), nil
+	}
+
+	return f
+}
+
+// AuthorizeOwner validates that an authenticated user is allowed to access
+// the requested resource using the specified rule. The owner of the resource
+// is looked up so the rule can decide based on ownership. An error from the
+// lookup is returned as is, allowing it to report a resource that doesn't
+// exist.
+func AuthorizeOwner(a *auth.Auth, rule string, owner OwnerFunc) web.Middleware {
+	return authorize(a, rule, "", owner)
+}
+
+// AuthorizePermission validates that an authenticated user holds the
+// specified permission through one of their roles.
+func AuthorizePermission(a *auth.Auth, permission string) web.Middleware {
+	return authorize(a, auth.RulePermission, permission, nil)
+}
+
+// AuthorizePermissionOwner validates that an authenticated user holds the
+// specified permission or owns the requested resource.
+func AuthorizePermissionOwner(a *auth.Auth, permission string, owner OwnerFunc) web.Middleware {
+	return authorize(a, auth.RulePermissionOrOwner, permission, owner)
+}
+
+// authorize constructs the middleware that evaluates the rule for the
+// requested resource.
+func authorize(a *auth.Auth, rule string, permission string, owner OwnerFunc) web.Middleware {
 	m := func(handler web.Handler) web.Handler {
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 			claims := auth.GetClaims(ctx)
@@ -38,6 +247,22 @@ func Authorize(a *auth.Auth, rule string) web.Middleware {
 				return auth.NewAuthError("authorize: you are not authorized for that action, no claims")
 			}
 
+			res := auth.Resource{
+				Method:     r.Method,
+				Route:      web.GetRoute(ctx),
+				Params:     web.Params(r),
+				Permission: permission,
+			}
+
+			if owner != nil {
+				var err error
+				if res.Owner, err = owner(ctx, r); err != nil {
+					return err
+				}
+			}
+
+			ctx = auth.SetResource(ctx, res)
+
 			if err := a.Authorize(ctx, claims, rule); err != nil {
 				return auth.NewAuthError("authorize: you are not authorized for that action, claims[%v] rule[%v]: %s", claims.Roles, rule, err)
 			}
@@ -50,3 +275,129 @@ func Authorize(a *auth.Auth, rule string) web.Middleware {
 
 	return m
 }
+
+// recordWrite records the change made by the request in the audit log. The
+// response has already been sent, so a failure to record is only logged.
+func recordWrite(ctx context.Context, log *zap.SugaredLogger, audCore *audit.Core, claims auth.Claims, r *http.Request) {
+	v := web.GetValues(ctx)
+
+	var actor string
+	if claims.Impersonated() {
+		actor = claims.Actor()
+		log.Infow("impersonation", "trace_id", v.TraceID, "subject", claims.Subject, "actor", actor,
+			"method", r.Method, "path", r.URL.Path, "statuscode", v.StatusCode)
+	}
+
+	tenantID, _ := uuid.Parse(claims.TenantID)
+
+	nr := audit.NewRecord{
+		TenantID:   tenantID,
+		TraceID:    v.TraceID,
+		Subject:    claims.Subject,
+		Actor:      actor,
+		Method:     r.Method,
+		Route:      v.Route,
+		Path:       r.URL.Path,
+		StatusCode: v.StatusCode,
+	}
+
+	if _, err := audCore.Create(ctx, nr); err != nil {
+		log.Errorw("audit", "trace_id", v.TraceID, "subject", claims.Subject, "actor", actor, "path", r.URL.Path, "ERROR", err)
+	}
+}
+
+// DenyImpersonation rejects requests made with an impersonated token. It's
+// used for the routes that change who can do what, so an admin can't use the
+// identity of another user to change roles.
+func DenyImpersonation() web.Middleware {
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			claims := auth.GetClaims(ctx)
+			if claims.Impersonated() {
+				return auth.NewAuthError("authorize: not allowed with an impersonated token, actor[%s]", claims.Actor())
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// DenyAPIKey rejects requests authenticated with an API key. It's used for
+// the routes that manage API keys, so a leaked key can't be used to create
+// more keys.
+func DenyAPIKey() web.Middleware {
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			claims := auth.GetClaims(ctx)
+			if claims.APIKeyID != "" {
+				return auth.NewAuthError("authorize: not allowed with an api key, key[%s]", claims.APIKeyID)
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// sessionScope scopes the context to the tenant of the claims and the
+// database session of the subject. The database enforces row level security
+// for the subject, so a query that isn't scoped properly can't reach other
+// rows.
+func sessionScope(ctx context.Context, claims auth.Claims) (context.Context, error) {
+	ctx, err := tenantScope(ctx, claims)
+	if err != nil {
+		return ctx, err
+	}
+
+	ctx = database.SetSession(ctx, database.Session{
+		UserID:      claims.Subject,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+	})
+
+	return ctx, nil
+}
+
+// tenantScope scopes the context to the tenant in the claims. Super admins
+// are platform operators and can access every tenant.
+func tenantScope(ctx context.Context, claims auth.Claims) (context.Context, error) {
+	if claims.HasRole(role.SuperAdmin) {
+		return tenant.SetAll(ctx), nil
+	}
+
+	tenantID, err := uuid.Parse(claims.TenantID)
+	if err != nil {
+		return ctx, fmt.Errorf("invalid tenant[%s]", claims.TenantID)
+	}
+
+	return tenant.Set(ctx, tenantID), nil
+}
+
+// tokenIDs returns the id of the token and the session it was issued for.
+// Tokens that don't carry these claims can only be revoked by expiring.
+func tokenIDs(claims auth.Claims) (uuid.UUID, uuid.UUID, error) {
+	var jti, familyID uuid.UUID
+
+	if claims.ID != "" {
+		var err error
+		if jti, err = uuid.Parse(claims.ID); err != nil {
+			return uuid.Nil, uuid.Nil, fmt.Errorf("invalid token id[%s]", claims.ID)
+		}
+	}
+
+	if claims.SessionID != "" {
+		var err error
+		if familyID, err = uuid.Parse(claims.SessionID); err != nil {
+			return uuid.Nil, uuid.Nil, fmt.Errorf("invalid session[%s]", claims.SessionID)
+		}
+	}
+
+	return jti, familyID, nil
+}
@@ -2,8 +2,10 @@ package mid
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 
+	"github.com/ardanlabs/service/business/sys/validate"
 	"github.com/ardanlabs/service/business/web/auth"
 	v1Web "github.com/ardanlabs/service/business/web/v1"
 	"github.com/ardanlabs/service/foundation/web"
@@ -12,7 +14,8 @@ import (
 
 // Errors handles errors coming out of the call chain. It detects normal
 // application errors which are used to respond to the client in a uniform way.
-// Unexpected errors (status >= 500) are logged.
+// Validation failures and payloads that can't be decoded are reported back to
+// the client as a 400. Unexpected errors (status >= 500) are logged.
 func Errors(log *zap.SugaredLogger) web.Middleware {
 	m := func(handler web.Handler) web.Handler {
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
@@ -28,8 +31,28 @@ func Errors(log *zap.SugaredLogger) web.Middleware {
 					er = v1Web.ErrorResponse{
 						Error: reqErr.Error(),
 					}
+					if fe := validate.GetFieldErrors(reqErr.Err); fe != nil {
+						er = v1Web.ErrorResponse{
+							Error:  "data validation error",
+							Fields: fe.Fields(),
+						}
+					}
 					status = reqErr.Status
 
+				case validate.IsFieldErrors(err):
+					fe := validate.GetFieldErrors(err)
+					er = v1Web.ErrorResponse{
+						Error:  "data validation error",
+						Fields: fe.Fields(),
+					}
+					status = http.StatusBadRequest
+
+				case web.IsDecodeError(err):
+					er = v1Web.ErrorResponse{
+						Error: fmt.Sprintf("unable to decode payload: %s", web.GetDecodeError(err)),
+					}
+					status = http.StatusBadRequest
+
 				case auth.IsAuthError(err):
 					er = v1Web.ErrorResponse{
 						Error: http.StatusText(http.StatusUnauthorized),
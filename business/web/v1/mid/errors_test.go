@@ -0,0 +1,115 @@
+package mid_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ardanlabs/service/business/sys/validate"
+	"github.com/ardanlabs/service/business/web/auth"
+	v1Web "github.com/ardanlabs/service/business/web/v1"
+	"github.com/ardanlabs/service/business/web/v1/mid"
+	"github.com/ardanlabs/service/foundation/web"
+	"go.uber.org/zap"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func Test_Errors(t *testing.T) {
+	decode := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var payload struct {
+			Name string `json:"name" validate:"required"`
+		}
+		if err := web.Decode(r, &payload); err != nil {
+			return fmt.Errorf("unable to decode payload: %w", err)
+		}
+
+		if err := validate.Check(payload); err != nil {
+			return fmt.Errorf("validating data: %w", err)
+		}
+
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+
+	fail := func(err error) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			return err
+		}
+		return h
+	}
+
+	tt := []struct {
+		name    string
+		handler web.Handler
+		body    string
+		status  int
+		message string
+		field   string
+	}{
+		{"malformed", decode, `{"name":`, http.StatusBadRequest, "unable to decode payload", ""},
+		{"unknownfield", decode, `{"name":"a","age":1}`, http.StatusBadRequest, "unable to decode payload", ""},
+		{"typemismatch", decode, `{"name":5}`, http.StatusBadRequest, "unable to decode payload", ""},
+		{"validation", decode, `{}`, http.StatusBadRequest, "data validation error", "name"},
+		{"valid", decode, `{"name":"a"}`, http.StatusNoContent, "", ""},
+		{"request", fail(v1Web.NewRequestError(errors.New("product not found"), http.StatusNotFound)), "", http.StatusNotFound, "product not found", ""},
+		{"auth", fail(auth.NewAuthError("no claims")), "", http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), ""},
+		{"unexpected", fail(errors.New("db is down")), "", http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), ""},
+	}
+
+	h := func(handler web.Handler) web.Handler {
+		return mid.Errors(zap.NewNop().Sugar())(handler)
+	}
+
+	t.Log("Given the need to report errors to the client in a uniform way.")
+	{
+		for testID, tst := range tt {
+			t.Logf("\tTest %d:\tWhen handling a %s error.", testID, tst.name)
+			{
+				r := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(tst.body))
+				w := httptest.NewRecorder()
+
+				if err := h(tst.handler)(context.Background(), w, r); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould handle the error : %s.", failed, testID, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould handle the error.", success, testID)
+
+				if w.Code != tst.status {
+					t.Fatalf("\t%s\tTest %d:\tShould respond with status %d : got %d.", failed, testID, tst.status, w.Code)
+				}
+				t.Logf("\t%s\tTest %d:\tShould respond with status %d.", success, testID, tst.status)
+
+				if tst.message == "" {
+					continue
+				}
+
+				var er v1Web.ErrorResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &er); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %s.", failed, testID, err)
+				}
+
+				if !strings.HasPrefix(er.Error, tst.message) {
+					t.Logf("\t\tTest %d:\tGot: %s", testID, er.Error)
+					t.Logf("\t\tTest %d:\tExp: %s", testID, tst.message)
+					t.Fatalf("\t%s\tTest %d:\tShould respond with the expected message.", failed, testID)
+				}
+				t.Logf("\t%s\tTest %d:\tShould respond with the expected message.", success, testID)
+
+				if tst.field != "" {
+					if _, exists := er.Fields[tst.field]; !exists {
+						t.Fatalf("\t%s\tTest %d:\tShould report the field %q : %v.", failed, testID, tst.field, er.Fields)
+					}
+					t.Logf("\t%s\tTest %d:\tShould report the field %q.", success, testID, tst.field)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Set of default values used when paging is not specified by the client.
+const (
+	DefaultPage        = 1
+	DefaultRowsPerPage = 10
+)
+
+// PageRequest represents the paging information provided by the client.
+type PageRequest struct {
+	Number      int
+	RowsPerPage int
+}
+
+// ParsePageRequest reads the page and rows query parameters from the request.
+// Missing parameters take their default values.
+func ParsePageRequest(r *http.Request) (PageRequest, error) {
+	values := r.URL.Query()
+
+	pr := PageRequest{
+		Number:      DefaultPage,
+		RowsPerPage: DefaultRowsPerPage,
+	}
+
+	if page := values.Get("page"); page != "" {
+		number, err := strconv.Atoi(page)
+		if err != nil || number < 1 {
+			return PageRequest{}, NewRequestError(fmt.Errorf("invalid page format [%s]", page), http.StatusBadRequest)
+		}
+		pr.Number = number
+	}
+
+	if rows := values.Get("rows"); rows != "" {
+		rowsPerPage, err := strconv.Atoi(rows)
+		if err != nil || rowsPerPage < 1 {
+			return PageRequest{}, NewRequestError(fmt.Errorf("invalid rows format [%s]", rows), http.StatusBadRequest)
+		}
+		pr.RowsPerPage = rowsPerPage
+	}
+
+	return pr, nil
+}
+
+// PageDocument is the form used for API responses that return a page of a
+// collection. Total is the number of items in the whole collection that
+// match the query, not the number of items in this page. Next is only set
+// when keyset pagination is being used.
+type PageDocument[T any] struct {
+	Items       []T    `json:"items"`
+	Total       int    `json:"total"`
+	Page        int    `json:"page"`
+	RowsPerPage int    `json:"rowsPerPage"`
+	HasMore     bool   `json:"hasMore"`
+	Next        string `json:"next,omitempty"`
+}
+
+// NewPageDocument constructs a response value for a page of a collection
+// that was retrieved by page number.
+func NewPageDocument[T any](items []T, total int, page int, rowsPerPage int) PageDocument[T] {
+	if items == nil {
+		items = []T{}
+	}
+
+	return PageDocument[T]{
+		Items:       items,
+		Total:       total,
+		Page:        page,
+		RowsPerPage: rowsPerPage,
+		HasMore:     page*rowsPerPage < total,
+	}
+}
+
+// NewCursorDocument constructs a response value for a page of a collection
+// that was retrieved using a keyset cursor. There is no page number when
+// using a cursor so Page is always zero.
+func NewCursorDocument[T any](items []T, total int, rowsPerPage int, next string) PageDocument[T] {
+	if items == nil {
+		items = []T{}
+	}
+
+	return PageDocument[T]{
+		Items:       items,
+		Total:       total,
+		RowsPerPage: rowsPerPage,
+		HasMore:     next != "",
+		Next:        next,
+	}
+}
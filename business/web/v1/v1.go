@@ -28,6 +28,11 @@ func (re *RequestError) Error() string {
 	return re.Err.Error()
 }
 
+// Unwrap returns the wrapped error so it can be inspected further.
+func (re *RequestError) Unwrap() error {
+	return re.Err
+}
+
 // IsRequestError checks if an error of type RequestError exists.
 func IsRequestError(err error) bool {
 	var re *RequestError
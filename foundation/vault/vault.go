@@ -5,6 +5,8 @@ package vault
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/json"
@@ -529,8 +531,8 @@ func (v *Vault) listMounts(ctx context.Context) (map[string]interface{}, error)
 // =============================================================================
 
 // toPublicPEM was taken from the JWT package to reduce the dependency. It
-// accepts a PEM encoding of a RSA private key and converts to a PEM encoded
-// public key.
+// accepts a PEM encoding of a RSA, ECDSA or Ed25519 private key and converts
+// to a PEM encoded public key.
 func toPublicPEM(privateKeyPEM string) (string, error) {
 	var block *pem.Block
 	if block, _ = pem.Decode([]byte(privateKeyPEM)); block == nil {
@@ -538,20 +540,38 @@ func toPublicPEM(privateKeyPEM string) (string, error) {
 	}
 
 	var parsedKey interface{}
-	parsedKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
-	if err != nil {
-		parsedKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	var err error
+
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		parsedKey, err = x509.ParseECPrivateKey(block.Bytes)
 		if err != nil {
 			return "", err
 		}
+
+	default:
+		parsedKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			parsedKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return "", err
+			}
+		}
 	}
 
-	privateKey, ok := parsedKey.(*rsa.PrivateKey)
-	if !ok {
-		return "", errors.New("key is not a valid RSA private key")
+	var publicKey interface{}
+	switch privateKey := parsedKey.(type) {
+	case *rsa.PrivateKey:
+		publicKey = &privateKey.PublicKey
+	case *ecdsa.PrivateKey:
+		publicKey = &privateKey.PublicKey
+	case ed25519.PrivateKey:
+		publicKey = privateKey.Public()
+	default:
+		return "", errors.New("key is not a valid RSA, ECDSA or Ed25519 private key")
 	}
 
-	asn1Bytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	asn1Bytes, err := x509.MarshalPKIXPublicKey(publicKey)
 	if err != nil {
 		return "", fmt.Errorf("marshaling public key: %w", err)
 	}
@@ -13,6 +13,7 @@ const key ctxKey = 1
 type Values struct {
 	TraceID    string
 	Now        time.Time
+	Route      string
 	StatusCode int
 }
 
@@ -56,3 +57,12 @@ func SetStatusCode(ctx context.Context, statusCode int) {
 
 	v.StatusCode = statusCode
 }
+
+// GetRoute returns the route pattern that matched the request.
+func GetRoute(ctx context.Context) string {
+	v, ok := ctx.Value(key).(*Values)
+	if !ok {
+		return ""
+	}
+	return v.Route
+}
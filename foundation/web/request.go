@@ -2,6 +2,7 @@ package web
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/dimfeld/httptreemux/v5"
@@ -13,6 +14,11 @@ func Param(r *http.Request, key string) string {
 	return m[key]
 }
 
+// Params returns all of the web call parameters from the request.
+func Params(r *http.Request) map[string]string {
+	return httptreemux.ContextParams(r.Context())
+}
+
 // Decode reads the body of an HTTP request looking for a JSON document. The
 // body is decoded into the provided value.
 //
@@ -21,8 +27,43 @@ func Decode(r *http.Request, val any) error {
 	decoder := json.NewDecoder(r.Body)
 	decoder.DisallowUnknownFields()
 	if err := decoder.Decode(val); err != nil {
-		return err
+		return &decodeError{err}
 	}
 
 	return nil
 }
+
+// =============================================================================
+
+// decodeError is used to indicate the body of a request could not be decoded.
+// This is a problem with what the client sent and not with the service.
+type decodeError struct {
+	err error
+}
+
+// Error is the implementation of the error interface.
+func (de *decodeError) Error() string {
+	return de.err.Error()
+}
+
+// Unwrap returns the error from the JSON decoder.
+func (de *decodeError) Unwrap() error {
+	return de.err
+}
+
+// IsDecodeError checks to see if a decode error is contained in the
+// specified error value.
+func IsDecodeError(err error) bool {
+	var de *decodeError
+	return errors.As(err, &de)
+}
+
+// GetDecodeError returns the decode error contained in the specified error
+// value or nil if there isn't one.
+func GetDecodeError(err error) error {
+	var de *decodeError
+	if !errors.As(err, &de) {
+		return nil
+	}
+	return de
+}
@@ -51,6 +51,7 @@ func (a *App) Handle(method string, path string, handler Handler, mw ...Middlewa
 		v := Values{
 			TraceID: uuid.NewString(),
 			Now:     time.Now().UTC(),
+			Route:   path,
 		}
 		ctx := context.WithValue(r.Context(), key, &v)
 